@@ -0,0 +1,248 @@
+// Package console connects to the remote consoles returned by InstancesService.GetConsole.
+//
+// Serial consoles are exposed as a raw byte stream that can be bridged to a local terminal or any
+// io.ReadWriter, or captured to a file. noVNC consoles are exposed as the raw RFB stream of the
+// websockify proxy, which can be bridged to a local TCP connection of a VNC viewer.
+package console
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	TypeSerial = "serial"
+	TypeNoVNC  = "novnc"
+
+	ProtocolSerial = "serial"
+	ProtocolVNC    = "vnc"
+
+	// websocketSubprotocol is the subprotocol used by the serial and websockify proxies for binary data.
+	websocketSubprotocol = "binary"
+	websockifyPath       = "websockify"
+)
+
+var (
+	ErrUnsupportedConsole = errors.New("unsupported console type")
+	ErrInvalidConsoleURL  = errors.New("invalid console URL")
+)
+
+// DialOptions specifies the optional parameters to Dial.
+type DialOptions struct {
+	// Origin is sent in the Origin header of the websocket handshake. Defaults to the console URL origin.
+	Origin string
+	// TLSConfig is used for wss:// endpoints.
+	TLSConfig *tls.Config
+}
+
+// Conn is a connection to a remote console. Reads and writes transfer raw console bytes.
+type Conn struct {
+	ws      *websocket.Conn
+	console edgecloud.RemoteConsole
+}
+
+var _ io.ReadWriteCloser = &Conn{}
+
+// WebsocketURL returns the websocket endpoint of the remote console. Serial console URLs are returned as is,
+// noVNC page URLs are converted to the websockify endpoint of the same proxy with the same token.
+func WebsocketURL(rc *edgecloud.RemoteConsole) (*url.URL, error) {
+	u, err := url.Parse(rc.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConsoleURL, err)
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return nil, fmt.Errorf("%w: scheme %q", ErrInvalidConsoleURL, u.Scheme)
+	}
+
+	switch {
+	case rc.Type == TypeSerial || rc.Protocol == ProtocolSerial:
+		return u, nil
+	case rc.Type == TypeNoVNC || rc.Protocol == ProtocolVNC:
+		if path.Ext(u.Path) == ".html" {
+			u.Path = path.Join(path.Dir(u.Path), websockifyPath)
+		}
+		return u, nil
+	default:
+		return nil, fmt.Errorf("%w: type %q, protocol %q", ErrUnsupportedConsole, rc.Type, rc.Protocol)
+	}
+}
+
+// Dial connects to the websocket endpoint of the remote console.
+func Dial(ctx context.Context, rc *edgecloud.RemoteConsole, opts *DialOptions) (*Conn, error) {
+	if rc == nil {
+		return nil, edgecloud.NewArgError("rc", "cannot be nil")
+	}
+
+	if opts == nil {
+		opts = &DialOptions{}
+	}
+
+	wsURL, err := WebsocketURL(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	origin := opts.Origin
+	if origin == "" {
+		scheme := "http"
+		if wsURL.Scheme == "wss" {
+			scheme = "https"
+		}
+		origin = fmt.Sprintf("%s://%s", scheme, wsURL.Host)
+	}
+
+	config, err := websocket.NewConfig(wsURL.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = []string{websocketSubprotocol}
+	config.TlsConfig = opts.TLSConfig
+
+	ws, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+
+	return &Conn{ws: ws, console: *rc}, nil
+}
+
+// Open gets the console of the instance and connects to it.
+func Open(ctx context.Context, client *edgecloud.Client, instanceID string, opts *DialOptions) (*Conn, error) {
+	rc, _, err := client.Instances.GetConsole(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return Dial(ctx, rc, opts)
+}
+
+// Console returns the remote console the connection was opened for.
+func (c *Conn) Console() edgecloud.RemoteConsole {
+	return c.console
+}
+
+// Read reads console output.
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.ws.Read(p)
+}
+
+// Write sends console input.
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.ws.Write(p)
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
+
+// readDeadliner is implemented by the readers whose pending Read can be interrupted, such as net.Conn,
+// os.Pipe and Terminal.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// Bridge copies console output to rw and input from rw to the console until either side is closed
+// or the context is canceled. To use a local terminal, pass a Terminal from MakeRawTerminal. The connection
+// is closed when Bridge returns. If rw supports read deadlines, like Terminal, net.Conn and os.Pipe, its
+// pending read is interrupted and Bridge waits for it, otherwise the input copy ends with the next read of rw.
+func (c *Conn) Bridge(ctx context.Context, rw io.ReadWriter) error {
+	errCh := make(chan error, 2)
+
+	go func() {
+		_, err := io.Copy(rw, c)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(c, rw)
+		errCh <- err
+	}()
+
+	var err error
+	running := 2
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case err = <-errCh:
+		running--
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+
+	// closing the connection ends the output copy and the input copy at its next write
+	_ = c.Close()
+
+	if d, ok := rw.(readDeadliner); ok && d.SetReadDeadline(time.Now()) == nil {
+		for ; running > 0; running-- {
+			<-errCh
+		}
+		_ = d.SetReadDeadline(time.Time{})
+	}
+
+	return err
+}
+
+// Capture writes console output to w until the console is closed or the context is canceled.
+// A canceled context is not reported as an error, so a deadline can be used to limit the capture time.
+func (c *Conn) Capture(ctx context.Context, w io.Writer) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, c)
+		done <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = c.Close()
+		<-done
+		return nil
+	case err := <-done:
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+}
+
+// CaptureBootLog connects to the serial console of the instance and appends its output to the file
+// until the console is closed or the context is canceled.
+func CaptureBootLog(ctx context.Context, client *edgecloud.Client, instanceID, filename string, opts *DialOptions) error {
+	conn, err := Open(ctx, client, instanceID, opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if conn.console.Type != TypeSerial && conn.console.Protocol != ProtocolSerial {
+		return fmt.Errorf("%w: boot log requires a serial console, got %q", ErrUnsupportedConsole, conn.console.Type)
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = conn.Capture(ctx, f)
+
+	return errors.Join(err, f.Close())
+}
@@ -0,0 +1,209 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	testInstanceID = "f0d19cec-5c3f-4853-886e-304915960ff6"
+	projectID      = 2750
+	regionID       = 8
+	bootLog        = "[    0.000000] Linux version 5.15.0\nlogin: "
+)
+
+func wsURL(serverURL, p string) string {
+	return strings.Replace(serverURL, "http://", "ws://", 1) + p
+}
+
+func TestWebsocketURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		console  edgecloud.RemoteConsole
+		expected string
+		err      error
+	}{
+		{
+			name:     "serial",
+			console:  edgecloud.RemoteConsole{URL: "ws://console.example.com:6083/?token=abc", Type: TypeSerial, Protocol: ProtocolSerial},
+			expected: "ws://console.example.com:6083/?token=abc",
+		},
+		{
+			name:     "novnc",
+			console:  edgecloud.RemoteConsole{URL: "https://console.example.com/novnc/vnc_auto.html?token=abc", Type: TypeNoVNC, Protocol: ProtocolVNC},
+			expected: "wss://console.example.com/novnc/websockify?token=abc",
+		},
+		{
+			name:    "unsupported",
+			console: edgecloud.RemoteConsole{URL: "https://console.example.com/spice_auto.html", Type: "spice-html5", Protocol: "spice"},
+			err:     ErrUnsupportedConsole,
+		},
+		{
+			name:    "invalid scheme",
+			console: edgecloud.RemoteConsole{URL: "ftp://console.example.com/", Type: TypeSerial},
+			err:     ErrInvalidConsoleURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := WebsocketURL(&tt.console)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, u.String())
+		})
+	}
+}
+
+func TestConn_Bridge(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		// echo the input back in upper case like a remote shell would
+		buf := make([]byte, 64)
+		n, err := ws.Read(buf)
+		if err != nil {
+			return
+		}
+		_, _ = ws.Write(bytes.ToUpper(buf[:n]))
+	}))
+	defer server.Close()
+
+	conn, err := Dial(context.Background(), &edgecloud.RemoteConsole{URL: wsURL(server.URL, "/"), Type: TypeSerial, Protocol: ProtocolSerial}, nil)
+	require.NoError(t, err)
+
+	in, inWriter := io.Pipe()
+	var out bytes.Buffer
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{in, &out}
+
+	go func() {
+		_, _ = inWriter.Write([]byte("uname\n"))
+	}()
+
+	err = conn.Bridge(context.Background(), rw)
+	require.NoError(t, err)
+	assert.Equal(t, "UNAME\n", out.String())
+}
+
+func TestConn_BridgeCanceled(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		// keep the console open until the client closes it
+		_, _ = io.Copy(io.Discard, ws)
+	}))
+	defer server.Close()
+
+	conn, err := Dial(context.Background(), &edgecloud.RemoteConsole{URL: wsURL(server.URL, "/"), Type: TypeSerial, Protocol: ProtocolSerial}, nil)
+	require.NoError(t, err)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = conn.Bridge(ctx, local)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// no read of the local side is left pending, so nothing takes the write
+	require.NoError(t, remote.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = remote.Write([]byte("ls\n"))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// the local side can be read again
+	require.NoError(t, remote.SetWriteDeadline(time.Time{}))
+	go func() { _, _ = remote.Write([]byte("ls\n")) }()
+	buf := make([]byte, 8)
+	n, err := local.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ls\n", string(buf[:n]))
+}
+
+func TestCaptureBootLog(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.Handle("/serial", websocket.Handler(func(ws *websocket.Conn) {
+		assert.Equal(t, "token=abc", ws.Request().URL.RawQuery)
+		ws.PayloadType = websocket.BinaryFrame
+		_, _ = ws.Write([]byte(bootLog))
+	}))
+
+	URL := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testInstanceID, "get_console")
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := json.Marshal(edgecloud.RemoteConsole{URL: wsURL(server.URL, "/serial?token=abc"), Type: TypeSerial, Protocol: ProtocolSerial})
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	})
+
+	client := edgecloud.NewClient(nil)
+	baseURL, _ := url.Parse(server.URL)
+	client.BaseURL = baseURL
+	client.Project = projectID
+	client.Region = regionID
+
+	filename := filepath.Join(t.TempDir(), "boot.log")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := CaptureBootLog(ctx, client, testInstanceID, filename, nil)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, bootLog, string(content))
+}
+
+func TestCaptureBootLog_NotSerial(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.Handle("/websockify", websocket.Handler(func(ws *websocket.Conn) {}))
+
+	URL := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testInstanceID, "get_console")
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := json.Marshal(edgecloud.RemoteConsole{URL: server.URL + "/vnc_auto.html?token=abc", Type: TypeNoVNC, Protocol: ProtocolVNC})
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	})
+
+	client := edgecloud.NewClient(nil)
+	baseURL, _ := url.Parse(server.URL)
+	client.BaseURL = baseURL
+	client.Project = projectID
+	client.Region = regionID
+
+	err := CaptureBootLog(context.Background(), client, testInstanceID, filepath.Join(t.TempDir(), "boot.log"), nil)
+	assert.ErrorIs(t, err, ErrUnsupportedConsole)
+}
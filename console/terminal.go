@@ -0,0 +1,128 @@
+package console
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+var ErrNotTerminal = errors.New("not a terminal")
+
+// Terminal is a local terminal in raw mode that can be passed to Conn.Bridge. Keys are sent to the remote
+// console as typed, including Ctrl+C. Reads of the terminal can be interrupted with SetReadDeadline, so
+// Bridge returns without leaving a read of the terminal pending and the terminal can be bridged again.
+type Terminal struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+
+	chunks chan []byte
+	// err is the error that ended the reads of in, set before chunks is closed.
+	err     error
+	pending []byte
+
+	mu      sync.Mutex
+	expired chan struct{}
+	timer   *time.Timer
+}
+
+// MakeRawTerminal puts the terminal of in into raw mode and returns it, out is the terminal output,
+// usually os.Stdin and os.Stdout. Restore must be called to put the terminal back into its previous mode.
+func MakeRawTerminal(in, out *os.File) (*Terminal, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, ErrNotTerminal
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	t := newTerminal(in, out)
+	t.state = state
+
+	return t, nil
+}
+
+// newTerminal starts reading in. The reads are done by a single goroutine for the lifetime of the terminal,
+// so an interrupted Read does not lose the input read afterwards.
+func newTerminal(in, out *os.File) *Terminal {
+	t := &Terminal{in: in, out: out, chunks: make(chan []byte), expired: make(chan struct{})}
+
+	go func() {
+		for {
+			buf := make([]byte, 1024)
+			n, err := t.in.Read(buf)
+			if n > 0 {
+				t.chunks <- buf[:n]
+			}
+			if err != nil {
+				t.err = err
+				close(t.chunks)
+				return
+			}
+		}
+	}()
+
+	return t
+}
+
+// Read reads the keys typed into the terminal.
+func (t *Terminal) Read(p []byte) (int, error) {
+	if len(t.pending) == 0 {
+		t.mu.Lock()
+		expired := t.expired
+		t.mu.Unlock()
+
+		select {
+		case chunk, ok := <-t.chunks:
+			if !ok {
+				return 0, t.err
+			}
+			t.pending = chunk
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+
+	return n, nil
+}
+
+// Write writes to the terminal output.
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+// SetReadDeadline sets the deadline of the pending and future Read calls. A zero value means no deadline.
+func (t *Terminal) SetReadDeadline(deadline time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	expired := make(chan struct{})
+	t.expired = expired
+	if !deadline.IsZero() {
+		t.timer = time.AfterFunc(time.Until(deadline), func() { close(expired) })
+	}
+
+	return nil
+}
+
+// Restore puts the terminal back into the mode it was in before MakeRawTerminal.
+func (t *Terminal) Restore() error {
+	if t.state == nil {
+		return nil
+	}
+
+	return term.Restore(int(t.in.Fd()), t.state)
+}
@@ -0,0 +1,49 @@
+package console
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminal_ReadDeadline(t *testing.T) {
+	in, inWriter, err := os.Pipe()
+	require.NoError(t, err)
+	defer in.Close()
+
+	terminal := newTerminal(in, os.Stdout)
+
+	require.NoError(t, terminal.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = terminal.Read(make([]byte, 8))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// the input typed after the interrupted read is not lost
+	require.NoError(t, terminal.SetReadDeadline(time.Time{}))
+	_, err = inWriter.Write([]byte("top\n"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 2)
+	n, err := terminal.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "to", string(buf[:n]))
+	n, err = terminal.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "p\n", string(buf[:n]))
+
+	require.NoError(t, inWriter.Close())
+	_, err = terminal.Read(buf)
+	assert.Error(t, err)
+	assert.NoError(t, terminal.Restore())
+}
+
+func TestMakeRawTerminal_NotTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "input")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = MakeRawTerminal(f, f)
+	assert.ErrorIs(t, err, ErrNotTerminal)
+}
@@ -0,0 +1,302 @@
+// Package cron parses crontab-like schedules and computes their fire times.
+//
+// Standard five field expressions ("minute hour day-of-month month day-of-week") and the @hourly, @daily,
+// @weekly, @monthly and @yearly macros are supported. Every field accepts "*", numbers, names of months
+// and days of week, ranges, lists and steps, for example "*/15", "8-18", "mon-fri" or "1,15". Schedules
+// can also be built field by field with Spec, which covers the APScheduler conventions used by lifecycle
+// policies: Monday-first days of week, ISO week numbers, and day fields that must all match.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchYears limits the search of the next fire time of schedules that never fire, such as "0 0 30 2 *".
+const searchYears = 5
+
+var ErrInvalidSchedule = errors.New("invalid cron schedule")
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	sundayFirstNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+	mondayFirstNames = map[string]int{"mon": 0, "tue": 1, "wed": 2, "thu": 3, "fri": 4, "sat": 5, "sun": 6}
+
+	macros = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Bits is the set of the values allowed by a field.
+type Bits uint64
+
+// Has reports whether the value is allowed.
+func (b Bits) Has(v int) bool {
+	return v >= 0 && v < 64 && b&(1<<uint(v)) != 0
+}
+
+// Count returns the number of the allowed values.
+func (b Bits) Count() int {
+	return bits.OnesCount64(uint64(b))
+}
+
+// ParseField parses a single field in the [lo, hi] range. An empty field is the same as "*".
+// Names are matched case-insensitively.
+func ParseField(field string, lo, hi int, names map[string]int) (Bits, error) {
+	if field == "" {
+		field = "*"
+	}
+
+	var result Bits
+	for _, part := range strings.Split(field, ",") {
+		b, err := parseFieldPart(strings.ToLower(strings.TrimSpace(part)), lo, hi, names)
+		if err != nil {
+			return 0, fmt.Errorf("%w: field %q: %w", ErrInvalidSchedule, field, err)
+		}
+		result |= b
+	}
+
+	return result, nil
+}
+
+func parseFieldPart(part string, lo, hi int, names map[string]int) (Bits, error) {
+	step := 1
+	if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+		var err error
+		if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step %q", stepPart)
+		}
+		part = rangePart
+	}
+
+	start, end := lo, hi
+	switch {
+	case part == "*":
+	case strings.Contains(part, "-"):
+		startPart, endPart, _ := strings.Cut(part, "-")
+		var err error
+		if start, err = parseFieldValue(startPart, names); err != nil {
+			return 0, err
+		}
+		if end, err = parseFieldValue(endPart, names); err != nil {
+			return 0, err
+		}
+	default:
+		v, err := parseFieldValue(part, names)
+		if err != nil {
+			return 0, err
+		}
+		start = v
+		// "5/10" is the same as "5-max/10"
+		if step == 1 {
+			end = v
+		}
+	}
+
+	if start < lo || end > hi || start > end {
+		return 0, fmt.Errorf("value %q out of range [%d, %d]", part, lo, hi)
+	}
+
+	var b Bits
+	for v := start; v <= end; v += step {
+		b |= 1 << uint(v)
+	}
+
+	return b, nil
+}
+
+func parseFieldValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[s]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+
+	return v, nil
+}
+
+// Spec is a schedule defined field by field. Empty fields are the same as "*".
+type Spec struct {
+	Minute     string
+	Hour       string
+	DayOfMonth string
+	Month      string
+	DayOfWeek  string
+	// Week is the ISO week number, 1-53.
+	Week string
+	// MondayFirst numbers the days of week from 0 for Monday to 6 for Sunday. Otherwise 0 and 7 are Sunday.
+	MondayFirst bool
+	// AllDays requires the day of month, the day of week and the week to match. Otherwise, as in crontab,
+	// a day matches either the day of month or the day of week when both are restricted.
+	AllDays bool
+	// Location is the timezone of the schedule, UTC by default.
+	Location *time.Location
+}
+
+// Schedule is a parsed cron schedule.
+type Schedule struct {
+	minute, hour, dom, month, dow, week Bits
+
+	domRestricted, dowRestricted bool
+	allDays                      bool
+	location                     *time.Location
+}
+
+// Parse parses a five field crontab expression or a macro in the location. A nil location is UTC.
+func Parse(expr string, location *time.Location) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d in %q", ErrInvalidSchedule, len(fields), expr)
+	}
+
+	return Spec{
+		Minute:     fields[0],
+		Hour:       fields[1],
+		DayOfMonth: fields[2],
+		Month:      fields[3],
+		DayOfWeek:  fields[4],
+		Location:   location,
+	}.Schedule()
+}
+
+// Schedule parses the fields of the spec.
+func (s Spec) Schedule() (*Schedule, error) {
+	sched := &Schedule{allDays: s.AllDays, location: s.Location}
+	if sched.location == nil {
+		sched.location = time.UTC
+	}
+
+	var err error
+	if sched.minute, err = ParseField(s.Minute, 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if sched.hour, err = ParseField(s.Hour, 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if sched.dom, err = ParseField(s.DayOfMonth, 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if sched.month, err = ParseField(s.Month, 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if sched.week, err = ParseField(s.Week, 1, 53, nil); err != nil {
+		return nil, err
+	}
+
+	if s.MondayFirst {
+		if sched.dow, err = ParseField(s.DayOfWeek, 0, 6, mondayFirstNames); err != nil {
+			return nil, err
+		}
+		// store the days of week as time.Weekday
+		sched.dow = (sched.dow << 1) | (sched.dow >> 6 & 1)
+		sched.dow &^= 1 << 7
+	} else {
+		if sched.dow, err = ParseField(s.DayOfWeek, 0, 7, sundayFirstNames); err != nil {
+			return nil, err
+		}
+		if sched.dow.Has(7) {
+			sched.dow = sched.dow&^(1<<7) | 1
+		}
+	}
+
+	sched.domRestricted = s.DayOfMonth != "" && s.DayOfMonth != "*"
+	sched.dowRestricted = s.DayOfWeek != "" && s.DayOfWeek != "*"
+
+	return sched, nil
+}
+
+// Location returns the timezone of the schedule.
+func (s *Schedule) Location() *time.Location {
+	return s.location
+}
+
+// Next returns the first fire time after t, in the schedule location. It returns the zero time
+// if the schedule does not fire within the next years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + searchYears
+
+	for t.Year() <= limit {
+		switch {
+		case !s.month.Has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+		case !s.hour.Has(t.Hour()):
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			// the wall clock hour is repeated when DST ends
+			if !next.After(t) {
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+		case !s.minute.Has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// NextN returns up to n fire times after t.
+func (s *Schedule) NextN(t time.Time, n int) []time.Time {
+	times := make([]time.Time, 0, n)
+	for len(times) < n {
+		t = s.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+
+	return times
+}
+
+// Equal reports whether the schedules fire at the same times, however their fields are written.
+func (s *Schedule) Equal(other *Schedule) bool {
+	if s.minute != other.minute || s.hour != other.hour || s.dom != other.dom || s.month != other.month ||
+		s.dow != other.dow || s.week != other.week || s.allDays != other.allDays {
+		return false
+	}
+
+	if !s.allDays && (s.domRestricted != other.domRestricted || s.dowRestricted != other.dowRestricted) {
+		return false
+	}
+
+	return s.location.String() == other.location.String()
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom.Has(t.Day())
+	dow := s.dow.Has(int(t.Weekday()))
+	_, isoWeek := t.ISOWeek()
+	week := s.week.Has(isoWeek)
+
+	if s.allDays || !s.domRestricted || !s.dowRestricted {
+		return dom && dow && week
+	}
+
+	return (dom || dow) && week
+}
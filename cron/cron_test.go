@@ -0,0 +1,145 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field    string
+		expected []int
+	}{
+		{field: "5", expected: []int{5}},
+		{field: "1,3-4", expected: []int{1, 3, 4}},
+		{field: "*/20", expected: []int{0, 20, 40}},
+		{field: "10-30/10", expected: []int{10, 20, 30}},
+		{field: "50/5", expected: []int{50, 55}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			b, err := ParseField(tt.field, 0, 59, nil)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.expected), b.Count())
+			for _, v := range tt.expected {
+				assert.True(t, b.Has(v))
+			}
+		})
+	}
+}
+
+func TestParseField_Error(t *testing.T) {
+	for _, field := range []string{"60", "5-1", "*/0", "a", "1-", "-1"} {
+		_, err := ParseField(field, 0, 59, nil)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, field)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8"} {
+		_, err := Parse(expr, nil)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	// Monday
+	start := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		location *time.Location
+		expected []string
+	}{
+		{expr: "@hourly", expected: []string{"2024-01-01T11:00:00Z", "2024-01-01T12:00:00Z"}},
+		{expr: "*/20 10 * * *", expected: []string{"2024-01-01T10:40:00Z", "2024-01-02T10:00:00Z"}},
+		{expr: "0 19 * * mon-fri", location: moscow, expected: []string{"2024-01-01T19:00:00+03:00", "2024-01-02T19:00:00+03:00"}},
+		{expr: "0 8 * * sat,7", expected: []string{"2024-01-06T08:00:00Z", "2024-01-07T08:00:00Z"}},
+		// either day of month or day of week
+		{expr: "0 0 15 * fri", expected: []string{"2024-01-05T00:00:00Z", "2024-01-12T00:00:00Z", "2024-01-15T00:00:00Z"}},
+		{expr: "0 0 29 feb *", expected: []string{"2024-02-29T00:00:00Z", "2028-02-29T00:00:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			sched, err := Parse(tt.expr, tt.location)
+			require.NoError(t, err)
+
+			times := sched.NextN(start, len(tt.expected))
+			actual := make([]string, 0, len(times))
+			for _, tm := range times {
+				actual = append(actual, tm.Format(time.RFC3339))
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestSchedule_NextNever(t *testing.T) {
+	sched, err := Parse("0 0 30 feb *", nil)
+	require.NoError(t, err)
+	assert.True(t, sched.Next(time.Now()).IsZero())
+	assert.Empty(t, sched.NextN(time.Now(), 3))
+}
+
+func TestSchedule_NextDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	sched, err := Parse("30 * * * *", berlin)
+	require.NoError(t, err)
+
+	// the clocks go back from 03:00 to 02:00 on 2024-10-27
+	times := sched.NextN(time.Date(2024, 10, 27, 1, 0, 0, 0, berlin), 3)
+	require.Len(t, times, 3)
+	assert.Equal(t, time.Hour, times[1].Sub(times[0]))
+	assert.Equal(t, time.Hour, times[2].Sub(times[1]))
+}
+
+func TestSpec_MondayFirst(t *testing.T) {
+	sched, err := Spec{Minute: "0", Hour: "12", DayOfWeek: "0,6", MondayFirst: true}.Schedule()
+	require.NoError(t, err)
+
+	times := sched.NextN(time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC), 2)
+	require.Len(t, times, 2)
+	assert.Equal(t, time.Sunday, times[0].Weekday())
+	assert.Equal(t, time.Monday, times[1].Weekday())
+
+	sched, err = Spec{Minute: "0", Hour: "0", DayOfWeek: "sun", MondayFirst: true}.Schedule()
+	require.NoError(t, err)
+	assert.Equal(t, time.Sunday, sched.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)).Weekday())
+}
+
+func TestSpec_AllDaysAndWeek(t *testing.T) {
+	sched, err := Spec{Minute: "0", Hour: "0", DayOfMonth: "1-7", DayOfWeek: "mon", Week: "*/2", AllDays: true}.Schedule()
+	require.NoError(t, err)
+
+	// the first Monday of a month in an odd ISO week
+	next := sched.Next(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2024-05-06T00:00:00Z", next.Format(time.RFC3339))
+}
+
+func TestSchedule_Equal(t *testing.T) {
+	weekdays, err := Parse("0 3 * * 1-5", nil)
+	require.NoError(t, err)
+	named, err := Parse("0 03 * * mon,tue,wed,thu,fri", time.UTC)
+	require.NoError(t, err)
+	assert.True(t, weekdays.Equal(named))
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	other, err := Parse("0 3 * * 1-5", moscow)
+	require.NoError(t, err)
+	assert.False(t, weekdays.Equal(other))
+
+	other, err = Parse("0 3 * * 1-6", nil)
+	require.NoError(t, err)
+	assert.False(t, weekdays.Equal(other))
+}
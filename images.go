@@ -67,7 +67,7 @@ type Image struct {
 	RegionID         int                `json:"region_id"`
 	Region           string             `json:"region"`
 	CreatorTaskID    string             `json:"creator_task_id"`
-	Status           string             `json:"status"`
+	Status           ImageStatus        `json:"status"`
 	OSType           OSType             `json:"os_type"`
 	SSHKey           SSHKey             `json:"ssh_key"`
 	OSDistro         string             `json:"os_distro"`
@@ -127,6 +127,8 @@ type ImageCreateRequest struct {
 	HWMachineType  HWMachineType  `json:"hw_machine_type"`
 	HWFirmwareType HWFirmwareType `json:"hw_firmware_type"`
 	Source         string         `json:"source"`
+	OSDistro       string         `json:"os_distro,omitempty"`
+	OSVersion      string         `json:"os_version,omitempty"`
 	Metadata       Metadata       `json:"metadata"`
 }
 
@@ -120,10 +120,10 @@ type Instance struct {
 	Region           string                       `json:"region"`
 	RegionID         int                          `json:"region_id"`
 	SecurityGroups   []Name                       `json:"security_groups"`
-	Status           string                       `json:"status,omitempty"` // todo: need to implement new status type
+	Status           InstanceStatus               `json:"status,omitempty"`
 	TaskID           string                       `json:"task_id"`
 	TaskState        string                       `json:"task_state,omitempty"`
-	VMState          string                       `json:"vm_state,omitempty"` // todo: need to implement new vm_state type
+	VMState          InstanceVMState              `json:"vm_state,omitempty"`
 	Volumes          []InstanceVolume             `json:"volumes"`
 }
 
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ladydascalie/currency"
 	"github.com/shopspring/decimal"
+
+	"github.com/Edge-Center/edgecentercloud-go/v2/cron"
 )
 
 type (
@@ -34,6 +37,7 @@ var (
 	ErrLifeCyclePolicyInvalidScheduleType = fmt.Errorf("invalid schedule type")
 	ErrLifeCyclePolicyInvalidStatus       = fmt.Errorf("invalid lifecycle policy status")
 	ErrLifeCyclePolicyInvalidAction       = fmt.Errorf("invalid lifecycle policy action")
+	ErrLifeCyclePolicyInvalidInterval     = fmt.Errorf("invalid lifecycle policy interval")
 )
 
 func (t LifeCyclePolicyScheduleType) List() []LifeCyclePolicyScheduleType {
@@ -373,7 +377,8 @@ type LifeCyclePolicyCommonCreateScheduleRequest struct {
 }
 
 // LifeCyclePolicyCreateCronScheduleRequest represents options used to create a single cron schedule.
-type LifeCyclePolicyCreateCronScheduleRequest struct { // TODO: validate?
+// Its fields follow the APScheduler cron conventions, see LifeCyclePolicyCronSpec, and are checked by Validate.
+type LifeCyclePolicyCreateCronScheduleRequest struct {
 	LifeCyclePolicyCommonCreateScheduleRequest
 	Timezone  string `json:"timezone,omitempty"`
 	Week      string `json:"week,omitempty"`
@@ -415,6 +420,95 @@ func (opts *LifeCyclePolicyCreateIntervalScheduleRequest) SetCommonCreateSchedul
 	opts.LifeCyclePolicyCommonCreateScheduleRequest = common
 }
 
+// Duration returns the retention time as a duration.
+func (r LifeCyclePolicyRetentionTimer) Duration() time.Duration {
+	return lifeCyclePolicyDuration(r.Weeks, r.Days, r.Hours, r.Minutes)
+}
+
+// Duration returns the interval between the snapshots.
+func (s LifeCyclePolicyIntervalSchedule) Duration() time.Duration {
+	return lifeCyclePolicyDuration(s.Weeks, s.Days, s.Hours, s.Minutes)
+}
+
+// Duration returns the interval between the snapshots.
+func (opts *LifeCyclePolicyCreateIntervalScheduleRequest) Duration() time.Duration {
+	return lifeCyclePolicyDuration(opts.Weeks, opts.Days, opts.Hours, opts.Minutes)
+}
+
+// Validate checks that the interval is positive.
+func (opts *LifeCyclePolicyCreateIntervalScheduleRequest) Validate() error {
+	if opts.Weeks < 0 || opts.Days < 0 || opts.Hours < 0 || opts.Minutes < 0 || opts.Duration() <= 0 {
+		return fmt.Errorf("%w: %v", ErrLifeCyclePolicyInvalidInterval, opts.Duration())
+	}
+
+	return nil
+}
+
+func lifeCyclePolicyDuration(weeks, days, hours, minutes int) time.Duration {
+	return time.Duration(weeks)*7*24*time.Hour + time.Duration(days)*24*time.Hour +
+		time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+}
+
+// Spec returns the parsed schedule spec, see LifeCyclePolicyCronSpec.
+func (s LifeCyclePolicyCronSchedule) Spec() (cron.Spec, error) {
+	return LifeCyclePolicyCronSpec(s.Timezone, s.Month, s.Day, s.Week, s.DayOfWeek, s.Hour, s.Minute)
+}
+
+// Spec returns the parsed schedule spec, see LifeCyclePolicyCronSpec.
+func (opts *LifeCyclePolicyCreateCronScheduleRequest) Spec() (cron.Spec, error) {
+	return LifeCyclePolicyCronSpec(opts.Timezone, opts.Month, opts.Day, opts.Week, opts.DayOfWeek, opts.Hour, opts.Minute)
+}
+
+// Validate checks the timezone and the fields of the schedule.
+func (opts *LifeCyclePolicyCreateCronScheduleRequest) Validate() error {
+	spec, err := opts.Spec()
+	if err != nil {
+		return err
+	}
+
+	_, err = spec.Schedule()
+
+	return err
+}
+
+// LifeCyclePolicyCronSpec builds the spec of a cron schedule with the APScheduler conventions the lifecycle
+// policies use: days of week are numbered from 0 for Monday, the week is the ISO week, and the day of month,
+// the day of week and the week must all match. Empty fields less significant than the least significant
+// set field default to their minimum, so that {hour: "3"} fires at 03:00, the others default to "*".
+// The timezone is UTC if empty.
+func LifeCyclePolicyCronSpec(timezone, month, day, week, dayOfWeek, hour, minute string) (cron.Spec, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return cron.Spec{}, fmt.Errorf("%w: timezone %q: %w", cron.ErrInvalidSchedule, timezone, err)
+	}
+
+	// the fields from the most significant, with their minimum values
+	fields := []*string{&month, &day, &week, &dayOfWeek, &hour, &minute}
+	minimums := []string{"1", "1", "*", "*", "0", "0"}
+
+	last := -1
+	for i, field := range fields {
+		if *field != "" {
+			last = i
+		}
+	}
+	for i := last + 1; i < len(fields) && last >= 0; i++ {
+		*fields[i] = minimums[i]
+	}
+
+	return cron.Spec{
+		Minute:      minute,
+		Hour:        hour,
+		DayOfMonth:  day,
+		Month:       month,
+		DayOfWeek:   dayOfWeek,
+		Week:        week,
+		MondayFirst: true,
+		AllDays:     true,
+		Location:    location,
+	}, nil
+}
+
 // LifeCyclePoliciesService is an interface for creating and managing lifecycle policies with the EdgecenterCloud API.
 // See: https://apidocs.edgecenter.ru/cloud#tag/Lifecycle-policy
 type LifeCyclePoliciesService interface {
@@ -11,6 +11,8 @@ import (
 	"github.com/ladydascalie/currency"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
+
+	"github.com/Edge-Center/edgecentercloud-go/v2/cron"
 )
 
 const (
@@ -354,3 +356,32 @@ func TestLifeCyclePoliciesServiceOp_EstimateIntervalMaxPolicyUsage(t *testing.T)
 	require.Equal(t, 200, resp.StatusCode)
 	require.Equal(t, expResp, *respActual)
 }
+
+func TestLifeCyclePolicyCronSpec(t *testing.T) {
+	spec, err := LifeCyclePolicyCronSpec("", "", "", "", "", "3", "")
+	require.NoError(t, err)
+	require.Empty(t, spec.Month)
+	require.Empty(t, spec.DayOfMonth)
+	require.Equal(t, "3", spec.Hour)
+	require.Equal(t, "0", spec.Minute)
+	require.True(t, spec.MondayFirst)
+	require.True(t, spec.AllDays)
+
+	spec, err = LifeCyclePolicyCronSpec("", "6", "", "", "", "", "")
+	require.NoError(t, err)
+	require.Equal(t, "1", spec.DayOfMonth)
+	require.Equal(t, "*", spec.Week)
+	require.Equal(t, "0", spec.Hour)
+
+	_, err = LifeCyclePolicyCronSpec("Mars/Olympus", "", "", "", "", "", "")
+	require.ErrorIs(t, err, cron.ErrInvalidSchedule)
+}
+
+func TestLifeCyclePolicyCreateScheduleRequest_Validate(t *testing.T) {
+	require.NoError(t, (&LifeCyclePolicyCreateCronScheduleRequest{Hour: "3", DayOfWeek: "0-4"}).Validate())
+	require.ErrorIs(t, (&LifeCyclePolicyCreateCronScheduleRequest{Hour: "25"}).Validate(), cron.ErrInvalidSchedule)
+
+	require.NoError(t, (&LifeCyclePolicyCreateIntervalScheduleRequest{Hours: 6}).Validate())
+	require.ErrorIs(t, (&LifeCyclePolicyCreateIntervalScheduleRequest{}).Validate(), ErrLifeCyclePolicyInvalidInterval)
+	require.ErrorIs(t, (&LifeCyclePolicyCreateIntervalScheduleRequest{Days: 1, Hours: -30}).Validate(), ErrLifeCyclePolicyInvalidInterval)
+}
@@ -44,7 +44,7 @@ type Router struct {
 	RegionID            int                 `json:"region_id"`
 	ProjectID           int                 `json:"project_id"`
 	TaskID              string              `json:"task_id"`
-	Status              string              `json:"status"`
+	Status              RouterStatus        `json:"status"`
 	CreatorTaskID       string              `json:"creator_task_id"`
 	ExternalGatewayInfo ExternalGatewayInfo `json:"external_gateway_info"`
 	Interfaces          []RouterInterface   `json:"interfaces"`
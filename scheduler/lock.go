@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultLockTTL = time.Minute
+
+var ErrLockNotHeld = errors.New("the lock is not held")
+
+// FileLock is a leader election lock backed by a file. The file holds the identity of the leader and is
+// refreshed by it. A lock that was not refreshed for TTL is considered abandoned and can be taken over.
+// The lock file should be on a filesystem shared by all the replicas.
+type FileLock struct {
+	Path string
+	// TTL is the time after which a lock that was not refreshed is taken over, 1 minute by default.
+	TTL time.Duration
+	// ID identifies the holder of the lock, the host name and the process ID by default.
+	ID string
+}
+
+// TryAcquire takes the lock if it is free, abandoned or already held by this holder. The replicas take the lock
+// one at a time, see guard.
+func (l *FileLock) TryAcquire() (acquired bool, err error) {
+	unlock, err := l.guard()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err = errors.Join(err, unlock()); err != nil {
+			acquired = false
+		}
+	}()
+
+	id := l.id()
+
+	holder, modTime, err := l.read()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return l.take(id, os.Link)
+	case err != nil:
+		return false, err
+	case holder == id:
+		if err = l.refresh(); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	case time.Since(modTime) < l.ttl():
+		return false, nil
+	default:
+		// the lock is abandoned, it is replaced at once so that it never looks free to another replica
+		return l.take(id, os.Rename)
+	}
+}
+
+// take writes the holder to a temporary file and moves it to the lock file with place, then reads the lock file
+// back to check that no other replica took the lock meanwhile.
+func (l *FileLock) take(id string, place func(oldpath, newpath string) error) (bool, error) {
+	f, err := os.CreateTemp(filepath.Dir(l.Path), filepath.Base(l.Path)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(id)
+	if err = errors.Join(err, f.Close()); err != nil {
+		return false, err
+	}
+
+	if err = place(f.Name(), l.Path); errors.Is(err, os.ErrExist) {
+		// another replica took the lock first
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	holder, _, err := l.read()
+	if err != nil {
+		return false, err
+	}
+
+	return holder == id, nil
+}
+
+// Refresh extends the lock held by this holder.
+func (l *FileLock) Refresh() (err error) {
+	unlock, err := l.guard()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	return l.refresh()
+}
+
+func (l *FileLock) refresh() error {
+	holder, _, err := l.read()
+	if err != nil {
+		return err
+	}
+
+	if holder != l.id() {
+		return fmt.Errorf("%w: held by %s", ErrLockNotHeld, holder)
+	}
+
+	now := time.Now()
+
+	return os.Chtimes(l.Path, now, now)
+}
+
+// Release frees the lock held by this holder.
+func (l *FileLock) Release() (err error) {
+	unlock, err := l.guard()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	holder, _, err := l.read()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if holder != l.id() {
+		return nil
+	}
+
+	return os.Remove(l.Path)
+}
+
+func (l *FileLock) read() (string, time.Time, error) {
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	info, err := os.Stat(l.Path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return strings.TrimSpace(string(data)), info.ModTime(), nil
+}
+
+func (l *FileLock) id() string {
+	if l.ID == "" {
+		hostname, _ := os.Hostname()
+		l.ID = fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	}
+
+	return l.ID
+}
+
+func (l *FileLock) ttl() time.Duration {
+	if l.TTL == 0 {
+		return defaultLockTTL
+	}
+
+	return l.TTL
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	leader := &FileLock{Path: path, ID: "replica-1", TTL: time.Minute}
+	standby := &FileLock{Path: path, ID: "replica-2", TTL: time.Minute}
+
+	acquired, err := leader.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = standby.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	assert.ErrorIs(t, standby.Refresh(), ErrLockNotHeld)
+
+	// the lock is held again by the leader
+	acquired, err = leader.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	// releasing a lock held by another replica does nothing
+	require.NoError(t, standby.Release())
+	require.NoError(t, leader.Release())
+	require.NoError(t, leader.Release())
+
+	acquired, err = standby.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestFileLock_Abandoned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	leader := &FileLock{Path: path, ID: "replica-1", TTL: time.Minute}
+	standby := &FileLock{Path: path, ID: "replica-2", TTL: time.Minute}
+
+	acquired, err := leader.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	past := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(path, past, past))
+
+	acquired, err = standby.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "replica-2", string(data))
+	assert.ErrorIs(t, leader.Refresh(), ErrLockNotHeld)
+}
+
+func TestFileLock_AbandonedConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	require.NoError(t, os.WriteFile(path, []byte("replica-0"), 0o644))
+	past := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(path, past, past))
+
+	var (
+		wg      sync.WaitGroup
+		leaders atomic.Int32
+	)
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock := &FileLock{Path: path, ID: fmt.Sprintf("replica-%d", i), TTL: time.Minute}
+			acquired, err := lock.TryAcquire()
+			assert.NoError(t, err)
+			if acquired {
+				leaders.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), leaders.Load())
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// guard serializes the lock operations of the replicas with an exclusive flock of a guard file next to the lock
+// file. The guard file is never removed, so all the replicas lock the same file.
+func (l *FileLock) guard() (func() error, error) {
+	f, err := os.OpenFile(l.Path+".guard", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+
+	return func() error {
+		return errors.Join(syscall.Flock(int(f.Fd()), syscall.LOCK_UN), f.Close())
+	}, nil
+}
@@ -0,0 +1,421 @@
+// Package scheduler runs instance power actions on cron schedules.
+//
+// A Rule selects instances by metadata, for example schedule=office-hours, and starts, stops or suspends
+// them at the times of a crontab expression in the timezone of the rule. Holidays skip the firings of
+// their dates. In dry-run mode the actions are only logged. Replicas of the scheduler elect a single
+// leader with a FileLock, the other replicas stay in standby until the lock is abandoned.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+	"github.com/Edge-Center/edgecentercloud-go/v2/cron"
+	"github.com/Edge-Center/edgecentercloud-go/v2/util"
+)
+
+const holidayLayout = "2006-01-02"
+
+var (
+	ErrInvalidRule        = errors.New("invalid scheduler rule")
+	ErrUnsupportedAction  = errors.New("unsupported scheduler action")
+	ErrSchedulerHasNoRule = errors.New("the scheduler has no rules")
+)
+
+// Clock provides the current time and timers. It is replaced by a fake clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// supportedActions are the power actions a rule can run.
+var supportedActions = map[util.InstanceActionType]bool{
+	util.InstanceActionStart:   true,
+	util.InstanceActionStop:    true,
+	util.InstanceActionSuspend: true,
+	util.InstanceActionResume:  true,
+}
+
+// Rule runs a power action on the instances selected by metadata.
+type Rule struct {
+	Name string
+	// Schedule is a crontab expression, see the cron package.
+	Schedule string
+	// Timezone is the IANA name of the timezone of the schedule and the holidays, UTC by default.
+	Timezone string
+	Action   util.InstanceActionType
+	// Selector are the metadata key-value pairs the instances must have.
+	Selector map[string]string
+	// Holidays are the dates in the YYYY-MM-DD format the rule does not fire on.
+	Holidays []string
+}
+
+// Firing is a scheduled run of a rule.
+type Firing struct {
+	Rule   string
+	Time   time.Time
+	Action util.InstanceActionType
+}
+
+// FiringResult describes a run of a rule.
+type FiringResult struct {
+	Firing
+	// Instances are the instances selected by the rule.
+	Instances []edgecloud.Instance
+	DryRun    bool
+	// Results are the results of the action, empty in dry-run mode.
+	Results util.InstanceActionResults
+}
+
+// Scheduler runs the rules on their schedules.
+type Scheduler struct {
+	Client *edgecloud.Client
+	Rules  []Rule
+	// Holidays are the dates in the YYYY-MM-DD format no rule fires on, in the timezone of each rule.
+	Holidays []string
+	// DryRun logs the actions instead of running them.
+	DryRun bool
+	// Logger is the standard logger by default.
+	Logger *log.Logger
+	// Lock elects the leader of the replicas. Without a lock the scheduler always runs the rules.
+	Lock *FileLock
+	// Clock is the real clock by default.
+	Clock Clock
+	// Concurrency and Attempts are passed to util.InstancesBatchAction.
+	Concurrency int
+	Attempts    *uint
+}
+
+type compiledRule struct {
+	Rule
+	schedule *cron.Schedule
+	holidays map[string]bool
+	next     time.Time
+}
+
+// Validate checks the rules of the scheduler.
+func (s *Scheduler) Validate() error {
+	_, err := s.compile()
+
+	return err
+}
+
+func (s *Scheduler) compile() ([]*compiledRule, error) {
+	if len(s.Rules) == 0 {
+		return nil, ErrSchedulerHasNoRule
+	}
+
+	rules := make([]*compiledRule, 0, len(s.Rules))
+	for _, rule := range s.Rules {
+		compiled, err := compileRule(rule, s.Holidays)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		rules = append(rules, compiled)
+	}
+
+	return rules, nil
+}
+
+func compileRule(rule Rule, holidays []string) (*compiledRule, error) {
+	if rule.Name == "" || len(rule.Selector) == 0 {
+		return nil, fmt.Errorf("%w: name and selector are required", ErrInvalidRule)
+	}
+
+	if !supportedActions[rule.Action] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAction, rule.Action)
+	}
+
+	location, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRule, err)
+	}
+
+	schedule, err := cron.Parse(rule.Schedule, location)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &compiledRule{Rule: rule, schedule: schedule, holidays: make(map[string]bool)}
+	for _, holiday := range append(append([]string(nil), holidays...), rule.Holidays...) {
+		if _, err = time.Parse(holidayLayout, holiday); err != nil {
+			return nil, fmt.Errorf("%w: holiday %q: %w", ErrInvalidRule, holiday, err)
+		}
+		compiled.holidays[holiday] = true
+	}
+
+	return compiled, nil
+}
+
+// nextFiring returns the first firing of the rule after t that is not on a holiday.
+func (r *compiledRule) nextFiring(t time.Time) time.Time {
+	for {
+		t = r.schedule.Next(t)
+		if t.IsZero() || !r.holidays[t.Format(holidayLayout)] {
+			return t
+		}
+	}
+}
+
+// Plan returns the next n firings of all the rules after t, ordered by time.
+func (s *Scheduler) Plan(t time.Time, n int) ([]Firing, error) {
+	rules, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	var firings []Firing
+	for _, rule := range rules {
+		next := t
+		for i := 0; i < n; i++ {
+			if next = rule.nextFiring(next); next.IsZero() {
+				break
+			}
+			firings = append(firings, Firing{Rule: rule.Name, Time: next, Action: rule.Action})
+		}
+	}
+
+	sort.SliceStable(firings, func(i, j int) bool {
+		return firings[i].Time.Before(firings[j].Time)
+	})
+
+	return firings[:min(n, len(firings))], nil
+}
+
+// Run fires the rules on their schedules until the context is canceled. Firings missed while the scheduler
+// was busy are run once, firings missed in standby are not run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	rules, err := s.compile()
+	if err != nil {
+		return err
+	}
+
+	if s.Lock != nil {
+		defer func() {
+			if err := s.Lock.Release(); err != nil {
+				s.logger().Printf("failed to release the lock %s: %v", s.Lock.Path, err)
+			}
+		}()
+	}
+
+	clock := s.clock()
+	leader := false
+	for _, rule := range rules {
+		rule.next = rule.nextFiring(clock.Now())
+	}
+
+	for ctx.Err() == nil {
+		now := clock.Now()
+
+		if s.Lock != nil {
+			acquired, err := s.Lock.TryAcquire()
+			if err != nil {
+				s.logger().Printf("failed to acquire the lock %s: %v", s.Lock.Path, err)
+			}
+			if acquired != leader {
+				leader = acquired
+				s.logger().Printf("leader: %t", leader)
+			}
+		}
+
+		var wait time.Duration
+		switch {
+		case s.Lock != nil && !leader:
+			for _, rule := range rules {
+				rule.next = rule.nextFiring(now)
+			}
+			wait = s.Lock.ttl() / 3
+		default:
+			s.fireDueHeld(ctx, rules, now)
+			wait = s.untilNext(rules, now)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-clock.After(wait):
+		}
+	}
+
+	return ctx.Err()
+}
+
+// fireDueHeld fires the due rules while a heartbeat refreshes the lock. The firings are canceled if the lock is
+// lost.
+func (s *Scheduler) fireDueHeld(ctx context.Context, rules []*compiledRule, now time.Time) {
+	if s.Lock == nil {
+		s.fireDue(ctx, rules, now)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.heartbeat(ctx, cancel)
+	}()
+
+	s.fireDue(ctx, rules, now)
+	cancel()
+	<-done
+}
+
+// heartbeat refreshes the lock until the context is canceled and calls lost if the lock cannot be refreshed.
+// The lock expires in real time, so the heartbeat does not use the clock of the scheduler.
+func (s *Scheduler) heartbeat(ctx context.Context, lost context.CancelFunc) {
+	ticker := time.NewTicker(s.Lock.ttl() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Lock.Refresh(); err != nil {
+			s.logger().Printf("lost the lock %s: %v", s.Lock.Path, err)
+			lost()
+			return
+		}
+	}
+}
+
+func (s *Scheduler) fireDue(ctx context.Context, rules []*compiledRule, now time.Time) {
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			// the lock was lost or the scheduler stopped, the next leader fires the rules
+			return
+		}
+		if rule.next.IsZero() || rule.next.After(now) {
+			continue
+		}
+
+		if _, err := s.fire(ctx, rule, rule.next); err != nil {
+			s.logger().Printf("rule %s: %v", rule.Name, err)
+		}
+		rule.next = rule.nextFiring(now)
+	}
+}
+
+// untilNext returns the time until the next firing, limited by the lock refresh interval.
+func (s *Scheduler) untilNext(rules []*compiledRule, now time.Time) time.Duration {
+	wait := time.Duration(-1)
+	for _, rule := range rules {
+		if !rule.next.IsZero() && (wait < 0 || rule.next.Sub(now) < wait) {
+			wait = rule.next.Sub(now)
+		}
+	}
+
+	if s.Lock != nil && (wait < 0 || wait > s.Lock.ttl()/3) {
+		wait = s.Lock.ttl() / 3
+	}
+
+	if wait < 0 {
+		// no rule will fire
+		wait = time.Hour
+	}
+
+	return wait
+}
+
+// Fire runs the rule as if it fired at the time, regardless of its schedule and holidays.
+func (s *Scheduler) Fire(ctx context.Context, rule Rule, at time.Time) (*FiringResult, error) {
+	compiled, err := compileRule(rule, s.Holidays)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.fire(ctx, compiled, at)
+}
+
+func (s *Scheduler) fire(ctx context.Context, rule *compiledRule, at time.Time) (*FiringResult, error) {
+	result := &FiringResult{Firing: Firing{Rule: rule.Name, Time: at, Action: rule.Action}, DryRun: s.DryRun}
+
+	instances, err := s.selectInstances(ctx, rule.Selector)
+	if err != nil {
+		return result, err
+	}
+	result.Instances = instances
+
+	if s.DryRun {
+		for _, instance := range instances {
+			s.logger().Printf("dry-run: rule %s at %s: %s instance %s (%s), status %s",
+				rule.Name, at.Format(time.RFC3339), rule.Action, instance.Name, instance.ID, instance.Status)
+		}
+
+		return result, nil
+	}
+
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, instance.ID)
+	}
+
+	result.Results = util.InstancesBatchAction(ctx, s.Client, ids, rule.Action, &util.InstanceBatchActionOptions{
+		Concurrency: s.Concurrency,
+		Attempts:    s.Attempts,
+	})
+
+	for _, r := range result.Results {
+		if r.Err != nil {
+			s.logger().Printf("rule %s at %s: %s instance %s: %v", rule.Name, at.Format(time.RFC3339), rule.Action, r.InstanceID, r.Err)
+			continue
+		}
+		s.logger().Printf("rule %s at %s: %s instance %s", rule.Name, at.Format(time.RFC3339), rule.Action, r.InstanceID)
+	}
+
+	return result, result.Results.Err()
+}
+
+func (s *Scheduler) selectInstances(ctx context.Context, selector map[string]string) ([]edgecloud.Instance, error) {
+	metadataKV, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, _, err := s.Client.Instances.List(ctx, &edgecloud.InstanceListOptions{MetadataKV: string(metadataKV)})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]edgecloud.Instance, 0, len(instances))
+	for _, instance := range instances {
+		matches := true
+		for k, v := range selector {
+			matches = matches && instance.Metadata[k] == v
+		}
+		if matches {
+			selected = append(selected, instance)
+		}
+	}
+
+	return selected, nil
+}
+
+func (s *Scheduler) logger() *log.Logger {
+	if s.Logger == nil {
+		return log.Default()
+	}
+
+	return s.Logger
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return realClock{}
+	}
+
+	return s.Clock
+}
@@ -0,0 +1,272 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+	"github.com/Edge-Center/edgecentercloud-go/v2/util"
+)
+
+const (
+	projectID = 2750
+	regionID  = 8
+
+	testInstanceID  = "f0d19cec-5c3f-4853-886e-304915960ff6"
+	testInstanceID2 = "9b3e1b4e-2a8f-4c1a-9f7e-1d2c3b4a5f60"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+
+	return ch
+}
+
+// fakeInstances serves the instance list filtered by metadata_kv and the instance stop action.
+type fakeInstances struct {
+	mu        sync.Mutex
+	instances map[string]*edgecloud.Instance
+	stopped   []string
+}
+
+func newFakeInstances(t *testing.T) (*edgecloud.Client, *fakeInstances) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	fake := &fakeInstances{instances: map[string]*edgecloud.Instance{
+		testInstanceID:  {ID: testInstanceID, Name: "dev-1", Status: edgecloud.InstanceStatusActive, Metadata: edgecloud.Metadata{"schedule": "office-hours"}},
+		testInstanceID2: {ID: testInstanceID2, Name: "prod-1", Status: edgecloud.InstanceStatusActive, Metadata: edgecloud.Metadata{"schedule": "always"}},
+	}}
+
+	URLList := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URLList, func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		var kv map[string]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("metadata_kv")), &kv); err != nil {
+			t.Errorf("invalid metadata_kv: %v", err)
+		}
+
+		instances := []edgecloud.Instance{}
+		for _, instance := range fake.instances {
+			if instance.Metadata["schedule"] == kv["schedule"] {
+				instances = append(instances, *instance)
+			}
+		}
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"results": instances}); err != nil {
+			t.Errorf("failed to encode response: %v", err)
+		}
+	})
+
+	for id := range fake.instances {
+		id := id
+		mux.HandleFunc(path.Join(URLList, id), func(w http.ResponseWriter, r *http.Request) {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+
+			if err := json.NewEncoder(w).Encode(fake.instances[id]); err != nil {
+				t.Errorf("failed to encode response: %v", err)
+			}
+		})
+		mux.HandleFunc(path.Join(URLList, id, "stop"), func(w http.ResponseWriter, r *http.Request) {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+
+			fake.instances[id].Status = edgecloud.InstanceStatusShutoff
+			fake.instances[id].VMState = edgecloud.InstanceVMStateStopped
+			fake.stopped = append(fake.stopped, id)
+			if err := json.NewEncoder(w).Encode(fake.instances[id]); err != nil {
+				t.Errorf("failed to encode response: %v", err)
+			}
+		})
+	}
+
+	client := edgecloud.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL)
+	client.Project = projectID
+	client.Region = regionID
+
+	return client, fake
+}
+
+func officeHoursStop() Rule {
+	return Rule{
+		Name:     "office-hours-stop",
+		Schedule: "0 19 * * mon-fri",
+		Timezone: "Europe/Moscow",
+		Action:   util.InstanceActionStop,
+		Selector: map[string]string{"schedule": "office-hours"},
+		Holidays: []string{"2024-01-02"},
+	}
+}
+
+func TestScheduler_Plan(t *testing.T) {
+	start := officeHoursStop()
+	start.Name, start.Schedule, start.Action, start.Holidays = "office-hours-start", "0 8 * * mon-fri", util.InstanceActionStart, nil
+	s := &Scheduler{Rules: []Rule{officeHoursStop(), start}, Holidays: []string{"2024-01-03"}}
+
+	// Monday, 2024-01-01 10:00 in Moscow
+	firings, err := s.Plan(time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC), 4)
+	require.NoError(t, err)
+
+	actual := make([]string, 0, len(firings))
+	for _, f := range firings {
+		actual = append(actual, fmt.Sprintf("%s %s", f.Time.Format(time.RFC3339), f.Action))
+	}
+	assert.Equal(t, []string{
+		"2024-01-01T19:00:00+03:00 stop",
+		"2024-01-02T08:00:00+03:00 start",
+		"2024-01-04T08:00:00+03:00 start",
+		"2024-01-04T19:00:00+03:00 stop",
+	}, actual)
+}
+
+func TestScheduler_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   func(*Rule)
+		target error
+	}{
+		{name: "action", rule: func(r *Rule) { r.Action = util.InstanceActionReboot }, target: ErrUnsupportedAction},
+		{name: "selector", rule: func(r *Rule) { r.Selector = nil }, target: ErrInvalidRule},
+		{name: "timezone", rule: func(r *Rule) { r.Timezone = "Mars/Olympus" }, target: ErrInvalidRule},
+		{name: "holiday", rule: func(r *Rule) { r.Holidays = []string{"01.01.2024"} }, target: ErrInvalidRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := officeHoursStop()
+			tt.rule(&rule)
+			s := &Scheduler{Rules: []Rule{rule}}
+			assert.ErrorIs(t, s.Validate(), tt.target)
+		})
+	}
+
+	assert.ErrorIs(t, (&Scheduler{}).Validate(), ErrSchedulerHasNoRule)
+}
+
+func TestScheduler_FireDryRun(t *testing.T) {
+	client, fake := newFakeInstances(t)
+
+	var logs bytes.Buffer
+	s := &Scheduler{Client: client, DryRun: true, Logger: log.New(&logs, "", 0)}
+
+	at := time.Date(2024, 1, 1, 19, 0, 0, 0, time.UTC)
+	result, err := s.Fire(context.Background(), officeHoursStop(), at)
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	require.Len(t, result.Instances, 1)
+	assert.Empty(t, result.Results)
+	assert.Empty(t, fake.stopped)
+	assert.Equal(t, fmt.Sprintf("dry-run: rule office-hours-stop at 2024-01-01T19:00:00Z: stop instance dev-1 (%s), status ACTIVE\n", testInstanceID), logs.String())
+}
+
+func TestScheduler_Fire(t *testing.T) {
+	client, fake := newFakeInstances(t)
+
+	var attempts uint = 2
+	s := &Scheduler{Client: client, Logger: log.New(&bytes.Buffer{}, "", 0), Attempts: &attempts}
+
+	result, err := s.Fire(context.Background(), officeHoursStop(), time.Now())
+	require.NoError(t, err)
+	require.Len(t, result.Results, 1)
+	assert.Equal(t, edgecloud.InstanceStatusShutoff, result.Results[0].Instance.Status)
+	assert.Equal(t, []string{testInstanceID}, fake.stopped)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	client, fake := newFakeInstances(t)
+
+	var logs bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Monday, 2024-01-01 18:00 in Moscow
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)}
+	var attempts uint = 2
+	s := &Scheduler{
+		Client:   client,
+		Rules:    []Rule{officeHoursStop()},
+		Logger:   log.New(&logs, "", 0),
+		Lock:     &FileLock{Path: filepath.Join(t.TempDir(), "scheduler.lock")},
+		Clock:    clock,
+		Attempts: &attempts,
+	}
+
+	go func() {
+		for ctx.Err() == nil {
+			// stop after the firing at 2024-01-03 19:00, the 2024-01-02 is a holiday
+			if clock.Now().After(time.Date(2024, 1, 3, 16, 0, 0, 0, time.UTC)) {
+				cancel()
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	err := s.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{testInstanceID}, fake.stopped)
+	assert.Contains(t, logs.String(), "leader: true")
+	assert.Contains(t, logs.String(), "rule office-hours-stop at 2024-01-01T19:00:00+03:00: stop instance "+testInstanceID)
+	assert.NoFileExists(t, s.Lock.Path)
+}
+
+func TestScheduler_HeartbeatLost(t *testing.T) {
+	var logs bytes.Buffer
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	s := &Scheduler{
+		Logger: log.New(&logs, "", 0),
+		Lock:   &FileLock{Path: path, ID: "replica-1", TTL: 30 * time.Millisecond},
+	}
+
+	acquired, err := s.Lock.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// another replica took the lock over while the rules fire
+	require.NoError(t, os.WriteFile(path, []byte("replica-2"), 0o644))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	s.heartbeat(ctx, cancel)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Contains(t, logs.String(), "lost the lock "+path)
+}
@@ -29,20 +29,20 @@ var _ SnapshotsService = &SnapshotsServiceOp{}
 
 // Snapshot represents an EdgecenterCloud Snapshot.
 type Snapshot struct {
-	Region        string   `json:"region"`
-	UpdatedAt     *string  `json:"updated_at"`
-	CreatedAt     string   `json:"created_at"`
-	Name          string   `json:"name"`
-	ID            string   `json:"id"`
-	RegionID      int      `json:"region_id"`
-	ProjectID     int      `json:"project_id"`
-	TaskID        *string  `json:"task_id"`
-	Status        string   `json:"status"`
-	CreatorTaskID *string  `json:"creator_task_id"`
-	Size          int      `json:"size"`
-	VolumeID      string   `json:"volume_id"`
-	Description   string   `json:"description"`
-	Metadata      Metadata `json:"metadata"`
+	Region        string         `json:"region"`
+	UpdatedAt     *string        `json:"updated_at"`
+	CreatedAt     string         `json:"created_at"`
+	Name          string         `json:"name"`
+	ID            string         `json:"id"`
+	RegionID      int            `json:"region_id"`
+	ProjectID     int            `json:"project_id"`
+	TaskID        *string        `json:"task_id"`
+	Status        SnapshotStatus `json:"status"`
+	CreatorTaskID *string        `json:"creator_task_id"`
+	Size          int            `json:"size"`
+	VolumeID      string         `json:"volume_id"`
+	Description   string         `json:"description"`
+	Metadata      Metadata       `json:"metadata"`
 }
 
 // SnapshotListOptions specifies the optional query parameters to List method.
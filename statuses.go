@@ -0,0 +1,450 @@
+package edgecloud
+
+import "slices"
+
+// statusSpec describes a status of a resource: whether the resource is still changing in it, whether it is
+// a failure, and the statuses the resource can move to from it. The helpers are lenient, a status missing
+// from the graph, for example one added to the API later, is neither terminal, error nor transitional,
+// and any transition from or to it is allowed.
+type statusSpec[S ~string] struct {
+	transitional bool
+	failed       bool
+	next         []S
+}
+
+func statusIsTerminal[S ~string](graph map[S]statusSpec[S], s S) bool {
+	spec, ok := graph[s]
+	return ok && !spec.transitional
+}
+
+func statusIsError[S ~string](graph map[S]statusSpec[S], s S) bool {
+	return graph[s].failed
+}
+
+func statusIsTransitional[S ~string](graph map[S]statusSpec[S], s S) bool {
+	return graph[s].transitional
+}
+
+func statusCanTransitionTo[S ~string](graph map[S]statusSpec[S], from, to S) bool {
+	spec, ok := graph[from]
+	if _, known := graph[to]; !ok || !known || from == to {
+		return true
+	}
+
+	return slices.Contains(spec.next, to)
+}
+
+// InstanceStatus is the status of an instance.
+type InstanceStatus string
+
+const (
+	InstanceStatusActive           InstanceStatus = "ACTIVE"
+	InstanceStatusBuild            InstanceStatus = "BUILD"
+	InstanceStatusDeleted          InstanceStatus = "DELETED"
+	InstanceStatusError            InstanceStatus = "ERROR"
+	InstanceStatusHardReboot       InstanceStatus = "HARD_REBOOT"
+	InstanceStatusMigrating        InstanceStatus = "MIGRATING"
+	InstanceStatusPassword         InstanceStatus = "PASSWORD"
+	InstanceStatusPaused           InstanceStatus = "PAUSED"
+	InstanceStatusReboot           InstanceStatus = "REBOOT"
+	InstanceStatusRebuild          InstanceStatus = "REBUILD"
+	InstanceStatusRescue           InstanceStatus = "RESCUE"
+	InstanceStatusResize           InstanceStatus = "RESIZE"
+	InstanceStatusRevertResize     InstanceStatus = "REVERT_RESIZE"
+	InstanceStatusShelved          InstanceStatus = "SHELVED"
+	InstanceStatusShelvedOffloaded InstanceStatus = "SHELVED_OFFLOADED"
+	InstanceStatusShutoff          InstanceStatus = "SHUTOFF"
+	InstanceStatusSoftDeleted      InstanceStatus = "SOFT_DELETED"
+	InstanceStatusSuspended        InstanceStatus = "SUSPENDED"
+	InstanceStatusUnknown          InstanceStatus = "UNKNOWN"
+	InstanceStatusVerifyResize     InstanceStatus = "VERIFY_RESIZE"
+)
+
+// instanceStatuses is the transition graph of the instance statuses:
+//
+//	BUILD -> ACTIVE | ERROR
+//	ACTIVE <-> SHUTOFF, PAUSED, SUSPENDED, RESCUE, SHELVED
+//	ACTIVE | SHUTOFF -> REBOOT, HARD_REBOOT, REBUILD, RESIZE, MIGRATING -> ACTIVE | SHUTOFF | ERROR
+//	RESIZE -> VERIFY_RESIZE -> ACTIVE | SHUTOFF | REVERT_RESIZE
+//	SHELVED -> SHELVED_OFFLOADED -> ACTIVE
+//	any -> ERROR, SOFT_DELETED, DELETED
+//
+// UNKNOWN is reported while the host of the instance is unreachable. It is left out of the graph, so like an
+// unlisted status it is neither transitional nor terminal and can move to any status.
+var instanceStatuses = map[InstanceStatus]statusSpec[InstanceStatus]{
+	InstanceStatusBuild: {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusError, InstanceStatusDeleted}},
+	InstanceStatusActive: {next: []InstanceStatus{
+		InstanceStatusShutoff, InstanceStatusPaused, InstanceStatusSuspended, InstanceStatusRescue, InstanceStatusShelved,
+		InstanceStatusReboot, InstanceStatusHardReboot, InstanceStatusRebuild, InstanceStatusResize, InstanceStatusMigrating,
+		InstanceStatusPassword, InstanceStatusError, InstanceStatusSoftDeleted, InstanceStatusDeleted,
+	}},
+	InstanceStatusShutoff: {next: []InstanceStatus{
+		InstanceStatusActive, InstanceStatusShelved, InstanceStatusRebuild, InstanceStatusResize, InstanceStatusMigrating,
+		InstanceStatusError, InstanceStatusSoftDeleted, InstanceStatusDeleted,
+	}},
+	InstanceStatusPaused:           {next: []InstanceStatus{InstanceStatusActive, InstanceStatusError, InstanceStatusDeleted}},
+	InstanceStatusSuspended:        {next: []InstanceStatus{InstanceStatusActive, InstanceStatusError, InstanceStatusDeleted}},
+	InstanceStatusRescue:           {next: []InstanceStatus{InstanceStatusActive, InstanceStatusError, InstanceStatusDeleted}},
+	InstanceStatusShelved:          {next: []InstanceStatus{InstanceStatusShelvedOffloaded, InstanceStatusActive, InstanceStatusError, InstanceStatusDeleted}},
+	InstanceStatusShelvedOffloaded: {next: []InstanceStatus{InstanceStatusActive, InstanceStatusError, InstanceStatusDeleted}},
+	InstanceStatusReboot:           {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusError}},
+	InstanceStatusHardReboot:       {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusError}},
+	InstanceStatusPassword:         {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusError}},
+	InstanceStatusRebuild:          {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusShutoff, InstanceStatusError}},
+	InstanceStatusMigrating:        {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusShutoff, InstanceStatusError}},
+	InstanceStatusResize: {transitional: true, next: []InstanceStatus{
+		InstanceStatusVerifyResize, InstanceStatusActive, InstanceStatusShutoff, InstanceStatusError,
+	}},
+	InstanceStatusVerifyResize: {next: []InstanceStatus{
+		InstanceStatusActive, InstanceStatusShutoff, InstanceStatusRevertResize, InstanceStatusError,
+	}},
+	InstanceStatusRevertResize: {transitional: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusShutoff, InstanceStatusError}},
+	InstanceStatusError:        {failed: true, next: []InstanceStatus{InstanceStatusActive, InstanceStatusShutoff, InstanceStatusRebuild, InstanceStatusDeleted}},
+	InstanceStatusSoftDeleted:  {next: []InstanceStatus{InstanceStatusActive, InstanceStatusDeleted}},
+	InstanceStatusDeleted:      {},
+}
+
+func (s InstanceStatus) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the instance has settled in the status, including the error status.
+func (s InstanceStatus) IsTerminal() bool {
+	return statusIsTerminal(instanceStatuses, s)
+}
+
+// IsError reports whether the status is a failure.
+func (s InstanceStatus) IsError() bool {
+	return statusIsError(instanceStatuses, s)
+}
+
+// IsTransitional reports whether the instance is still changing in the status.
+func (s InstanceStatus) IsTransitional() bool {
+	return statusIsTransitional(instanceStatuses, s)
+}
+
+// CanTransitionTo reports whether the instance can move from the status to the next one.
+func (s InstanceStatus) CanTransitionTo(next InstanceStatus) bool {
+	return statusCanTransitionTo(instanceStatuses, s, next)
+}
+
+// InstanceVMState is the state of the virtual machine of an instance.
+type InstanceVMState string
+
+const (
+	InstanceVMStateActive           InstanceVMState = "active"
+	InstanceVMStateBuilding         InstanceVMState = "building"
+	InstanceVMStateDeleted          InstanceVMState = "deleted"
+	InstanceVMStateError            InstanceVMState = "error"
+	InstanceVMStatePaused           InstanceVMState = "paused"
+	InstanceVMStateRescued          InstanceVMState = "rescued"
+	InstanceVMStateResized          InstanceVMState = "resized"
+	InstanceVMStateShelved          InstanceVMState = "shelved"
+	InstanceVMStateShelvedOffloaded InstanceVMState = "shelved_offloaded"
+	InstanceVMStateSoftDeleted      InstanceVMState = "soft-delete"
+	InstanceVMStateStopped          InstanceVMState = "stopped"
+	InstanceVMStateSuspended        InstanceVMState = "suspended"
+)
+
+// instanceVMStates is the transition graph of the VM states, it follows the instance statuses:
+//
+//	building -> active | error
+//	active <-> stopped, paused, suspended, rescued, resized, shelved
+//	shelved -> shelved_offloaded -> active
+//	any -> error, soft-delete, deleted
+var instanceVMStates = map[InstanceVMState]statusSpec[InstanceVMState]{
+	InstanceVMStateBuilding: {transitional: true, next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateError, InstanceVMStateDeleted}},
+	InstanceVMStateActive: {next: []InstanceVMState{
+		InstanceVMStateStopped, InstanceVMStatePaused, InstanceVMStateSuspended, InstanceVMStateRescued, InstanceVMStateResized,
+		InstanceVMStateShelved, InstanceVMStateError, InstanceVMStateSoftDeleted, InstanceVMStateDeleted,
+	}},
+	InstanceVMStateStopped: {next: []InstanceVMState{
+		InstanceVMStateActive, InstanceVMStateResized, InstanceVMStateShelved, InstanceVMStateError,
+		InstanceVMStateSoftDeleted, InstanceVMStateDeleted,
+	}},
+	InstanceVMStatePaused:           {next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateError, InstanceVMStateDeleted}},
+	InstanceVMStateSuspended:        {next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateError, InstanceVMStateDeleted}},
+	InstanceVMStateRescued:          {next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateError, InstanceVMStateDeleted}},
+	InstanceVMStateResized:          {next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateStopped, InstanceVMStateError}},
+	InstanceVMStateShelved:          {next: []InstanceVMState{InstanceVMStateShelvedOffloaded, InstanceVMStateActive, InstanceVMStateError, InstanceVMStateDeleted}},
+	InstanceVMStateShelvedOffloaded: {next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateError, InstanceVMStateDeleted}},
+	InstanceVMStateError:            {failed: true, next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateStopped, InstanceVMStateDeleted}},
+	InstanceVMStateSoftDeleted:      {next: []InstanceVMState{InstanceVMStateActive, InstanceVMStateDeleted}},
+	InstanceVMStateDeleted:          {},
+}
+
+func (s InstanceVMState) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the VM has settled in the state, including the error state.
+func (s InstanceVMState) IsTerminal() bool {
+	return statusIsTerminal(instanceVMStates, s)
+}
+
+// IsError reports whether the state is a failure.
+func (s InstanceVMState) IsError() bool {
+	return statusIsError(instanceVMStates, s)
+}
+
+// IsTransitional reports whether the VM is still changing in the state.
+func (s InstanceVMState) IsTransitional() bool {
+	return statusIsTransitional(instanceVMStates, s)
+}
+
+// CanTransitionTo reports whether the VM can move from the state to the next one.
+func (s InstanceVMState) CanTransitionTo(next InstanceVMState) bool {
+	return statusCanTransitionTo(instanceVMStates, s, next)
+}
+
+// VolumeStatus is the status of a volume.
+type VolumeStatus string
+
+const (
+	VolumeStatusCreating         VolumeStatus = "creating"
+	VolumeStatusAvailable        VolumeStatus = "available"
+	VolumeStatusReserved         VolumeStatus = "reserved"
+	VolumeStatusAttaching        VolumeStatus = "attaching"
+	VolumeStatusDetaching        VolumeStatus = "detaching"
+	VolumeStatusInUse            VolumeStatus = "in-use"
+	VolumeStatusMaintenance      VolumeStatus = "maintenance"
+	VolumeStatusDeleting         VolumeStatus = "deleting"
+	VolumeStatusDeleted          VolumeStatus = "deleted"
+	VolumeStatusAwaitingTransfer VolumeStatus = "awaiting-transfer"
+	VolumeStatusBackingUp        VolumeStatus = "backing-up"
+	VolumeStatusRestoringBackup  VolumeStatus = "restoring-backup"
+	VolumeStatusDownloading      VolumeStatus = "downloading"
+	VolumeStatusUploading        VolumeStatus = "uploading"
+	VolumeStatusRetyping         VolumeStatus = "retyping"
+	VolumeStatusExtending        VolumeStatus = "extending"
+	VolumeStatusError            VolumeStatus = "error"
+	VolumeStatusErrorDeleting    VolumeStatus = "error_deleting"
+	VolumeStatusErrorBackingUp   VolumeStatus = "error_backing-up"
+	VolumeStatusErrorRestoring   VolumeStatus = "error_restoring"
+	VolumeStatusErrorExtending   VolumeStatus = "error_extending"
+)
+
+// volumeStatuses is the transition graph of the volume statuses:
+//
+//	creating | downloading | restoring-backup -> available | error
+//	available -> reserved | attaching -> in-use -> detaching -> available
+//	available | in-use -> extending, retyping, uploading, backing-up -> available | in-use | error
+//	available -> awaiting-transfer -> available
+//	available | error -> deleting -> deleted | error_deleting
+var volumeStatuses = map[VolumeStatus]statusSpec[VolumeStatus]{
+	VolumeStatusCreating:        {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusError}},
+	VolumeStatusDownloading:     {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusError}},
+	VolumeStatusRestoringBackup: {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusErrorRestoring}},
+	VolumeStatusAvailable: {next: []VolumeStatus{
+		VolumeStatusReserved, VolumeStatusAttaching, VolumeStatusExtending, VolumeStatusRetyping, VolumeStatusUploading,
+		VolumeStatusBackingUp, VolumeStatusRestoringBackup, VolumeStatusMaintenance, VolumeStatusAwaitingTransfer,
+		VolumeStatusDeleting,
+	}},
+	VolumeStatusReserved:  {transitional: true, next: []VolumeStatus{VolumeStatusAttaching, VolumeStatusInUse, VolumeStatusAvailable}},
+	VolumeStatusAttaching: {transitional: true, next: []VolumeStatus{VolumeStatusInUse, VolumeStatusAvailable, VolumeStatusError}},
+	VolumeStatusInUse: {next: []VolumeStatus{
+		VolumeStatusDetaching, VolumeStatusExtending, VolumeStatusRetyping, VolumeStatusUploading, VolumeStatusBackingUp,
+	}},
+	VolumeStatusDetaching:        {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusInUse, VolumeStatusError}},
+	VolumeStatusExtending:        {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusInUse, VolumeStatusErrorExtending}},
+	VolumeStatusRetyping:         {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusInUse, VolumeStatusError}},
+	VolumeStatusUploading:        {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusInUse, VolumeStatusError}},
+	VolumeStatusBackingUp:        {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusInUse, VolumeStatusErrorBackingUp}},
+	VolumeStatusMaintenance:      {transitional: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusError}},
+	VolumeStatusAwaitingTransfer: {next: []VolumeStatus{VolumeStatusAvailable}},
+	VolumeStatusDeleting:         {transitional: true, next: []VolumeStatus{VolumeStatusDeleted, VolumeStatusErrorDeleting}},
+	VolumeStatusDeleted:          {},
+	VolumeStatusError:            {failed: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusDeleting}},
+	VolumeStatusErrorDeleting:    {failed: true, next: []VolumeStatus{VolumeStatusDeleting}},
+	VolumeStatusErrorBackingUp:   {failed: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusDeleting}},
+	VolumeStatusErrorRestoring:   {failed: true, next: []VolumeStatus{VolumeStatusDeleting}},
+	VolumeStatusErrorExtending:   {failed: true, next: []VolumeStatus{VolumeStatusAvailable, VolumeStatusDeleting}},
+}
+
+func (s VolumeStatus) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the volume has settled in the status, including the error statuses.
+func (s VolumeStatus) IsTerminal() bool {
+	return statusIsTerminal(volumeStatuses, s)
+}
+
+// IsError reports whether the status is a failure.
+func (s VolumeStatus) IsError() bool {
+	return statusIsError(volumeStatuses, s)
+}
+
+// IsTransitional reports whether the volume is still changing in the status.
+func (s VolumeStatus) IsTransitional() bool {
+	return statusIsTransitional(volumeStatuses, s)
+}
+
+// CanTransitionTo reports whether the volume can move from the status to the next one.
+func (s VolumeStatus) CanTransitionTo(next VolumeStatus) bool {
+	return statusCanTransitionTo(volumeStatuses, s, next)
+}
+
+// SnapshotStatus is the status of a snapshot.
+type SnapshotStatus string
+
+const (
+	SnapshotStatusCreating      SnapshotStatus = "creating"
+	SnapshotStatusAvailable     SnapshotStatus = "available"
+	SnapshotStatusBackingUp     SnapshotStatus = "backing-up"
+	SnapshotStatusRestoring     SnapshotStatus = "restoring"
+	SnapshotStatusDeleting      SnapshotStatus = "deleting"
+	SnapshotStatusDeleted       SnapshotStatus = "deleted"
+	SnapshotStatusUnmanaging    SnapshotStatus = "unmanaging"
+	SnapshotStatusError         SnapshotStatus = "error"
+	SnapshotStatusErrorDeleting SnapshotStatus = "error_deleting"
+)
+
+// snapshotStatuses is the transition graph of the snapshot statuses:
+//
+//	creating -> available | error
+//	available -> backing-up | restoring -> available
+//	available | error -> deleting | unmanaging -> deleted | error_deleting
+var snapshotStatuses = map[SnapshotStatus]statusSpec[SnapshotStatus]{
+	SnapshotStatusCreating: {transitional: true, next: []SnapshotStatus{SnapshotStatusAvailable, SnapshotStatusError}},
+	SnapshotStatusAvailable: {next: []SnapshotStatus{
+		SnapshotStatusBackingUp, SnapshotStatusRestoring, SnapshotStatusDeleting, SnapshotStatusUnmanaging,
+	}},
+	SnapshotStatusBackingUp:     {transitional: true, next: []SnapshotStatus{SnapshotStatusAvailable, SnapshotStatusError}},
+	SnapshotStatusRestoring:     {transitional: true, next: []SnapshotStatus{SnapshotStatusAvailable, SnapshotStatusError}},
+	SnapshotStatusDeleting:      {transitional: true, next: []SnapshotStatus{SnapshotStatusDeleted, SnapshotStatusErrorDeleting}},
+	SnapshotStatusUnmanaging:    {transitional: true, next: []SnapshotStatus{SnapshotStatusDeleted, SnapshotStatusError}},
+	SnapshotStatusDeleted:       {},
+	SnapshotStatusError:         {failed: true, next: []SnapshotStatus{SnapshotStatusDeleting, SnapshotStatusAvailable}},
+	SnapshotStatusErrorDeleting: {failed: true, next: []SnapshotStatus{SnapshotStatusDeleting}},
+}
+
+func (s SnapshotStatus) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the snapshot has settled in the status, including the error statuses.
+func (s SnapshotStatus) IsTerminal() bool {
+	return statusIsTerminal(snapshotStatuses, s)
+}
+
+// IsError reports whether the status is a failure.
+func (s SnapshotStatus) IsError() bool {
+	return statusIsError(snapshotStatuses, s)
+}
+
+// IsTransitional reports whether the snapshot is still changing in the status.
+func (s SnapshotStatus) IsTransitional() bool {
+	return statusIsTransitional(snapshotStatuses, s)
+}
+
+// CanTransitionTo reports whether the snapshot can move from the status to the next one.
+func (s SnapshotStatus) CanTransitionTo(next SnapshotStatus) bool {
+	return statusCanTransitionTo(snapshotStatuses, s, next)
+}
+
+// ImageStatus is the status of an image.
+type ImageStatus string
+
+const (
+	ImageStatusQueued        ImageStatus = "queued"
+	ImageStatusSaving        ImageStatus = "saving"
+	ImageStatusUploading     ImageStatus = "uploading"
+	ImageStatusImporting     ImageStatus = "importing"
+	ImageStatusActive        ImageStatus = "active"
+	ImageStatusDeactivated   ImageStatus = "deactivated"
+	ImageStatusKilled        ImageStatus = "killed"
+	ImageStatusPendingDelete ImageStatus = "pending_delete"
+	ImageStatusDeleted       ImageStatus = "deleted"
+)
+
+// imageStatuses is the transition graph of the image statuses:
+//
+//	queued -> saving | uploading -> importing -> active | killed
+//	active <-> deactivated
+//	any -> pending_delete -> deleted
+var imageStatuses = map[ImageStatus]statusSpec[ImageStatus]{
+	ImageStatusQueued: {transitional: true, next: []ImageStatus{
+		ImageStatusSaving, ImageStatusUploading, ImageStatusImporting, ImageStatusPendingDelete, ImageStatusDeleted,
+	}},
+	ImageStatusSaving:    {transitional: true, next: []ImageStatus{ImageStatusActive, ImageStatusKilled, ImageStatusQueued, ImageStatusDeleted}},
+	ImageStatusUploading: {transitional: true, next: []ImageStatus{ImageStatusImporting, ImageStatusQueued, ImageStatusDeleted}},
+	ImageStatusImporting: {transitional: true, next: []ImageStatus{ImageStatusActive, ImageStatusKilled, ImageStatusQueued, ImageStatusDeleted}},
+	ImageStatusActive:    {next: []ImageStatus{ImageStatusDeactivated, ImageStatusPendingDelete, ImageStatusDeleted}},
+	ImageStatusDeactivated: {next: []ImageStatus{
+		ImageStatusActive, ImageStatusPendingDelete, ImageStatusDeleted,
+	}},
+	ImageStatusKilled:        {failed: true, next: []ImageStatus{ImageStatusPendingDelete, ImageStatusDeleted}},
+	ImageStatusPendingDelete: {transitional: true, next: []ImageStatus{ImageStatusDeleted}},
+	ImageStatusDeleted:       {},
+}
+
+func (s ImageStatus) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the image has settled in the status, including the error status.
+func (s ImageStatus) IsTerminal() bool {
+	return statusIsTerminal(imageStatuses, s)
+}
+
+// IsError reports whether the status is a failure.
+func (s ImageStatus) IsError() bool {
+	return statusIsError(imageStatuses, s)
+}
+
+// IsTransitional reports whether the image is still changing in the status.
+func (s ImageStatus) IsTransitional() bool {
+	return statusIsTransitional(imageStatuses, s)
+}
+
+// CanTransitionTo reports whether the image can move from the status to the next one.
+func (s ImageStatus) CanTransitionTo(next ImageStatus) bool {
+	return statusCanTransitionTo(imageStatuses, s, next)
+}
+
+// RouterStatus is the status of a router.
+type RouterStatus string
+
+const (
+	RouterStatusActive RouterStatus = "ACTIVE"
+	RouterStatusBuild  RouterStatus = "BUILD"
+	RouterStatusDown   RouterStatus = "DOWN"
+	RouterStatusError  RouterStatus = "ERROR"
+)
+
+// routerStatuses is the transition graph of the router statuses:
+//
+//	BUILD -> ACTIVE | ERROR
+//	ACTIVE <-> DOWN
+//	ACTIVE | DOWN -> ERROR -> ACTIVE
+var routerStatuses = map[RouterStatus]statusSpec[RouterStatus]{
+	RouterStatusBuild:  {transitional: true, next: []RouterStatus{RouterStatusActive, RouterStatusError}},
+	RouterStatusActive: {next: []RouterStatus{RouterStatusDown, RouterStatusError}},
+	RouterStatusDown:   {next: []RouterStatus{RouterStatusActive, RouterStatusError}},
+	RouterStatusError:  {failed: true, next: []RouterStatus{RouterStatusActive, RouterStatusDown}},
+}
+
+func (s RouterStatus) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the router has settled in the status, including the error status.
+func (s RouterStatus) IsTerminal() bool {
+	return statusIsTerminal(routerStatuses, s)
+}
+
+// IsError reports whether the status is a failure.
+func (s RouterStatus) IsError() bool {
+	return statusIsError(routerStatuses, s)
+}
+
+// IsTransitional reports whether the router is still changing in the status.
+func (s RouterStatus) IsTransitional() bool {
+	return statusIsTransitional(routerStatuses, s)
+}
+
+// CanTransitionTo reports whether the router can move from the status to the next one.
+func (s RouterStatus) CanTransitionTo(next RouterStatus) bool {
+	return statusCanTransitionTo(routerStatuses, s, next)
+}
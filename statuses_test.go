@@ -0,0 +1,90 @@
+package edgecloud
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceStatus(t *testing.T) {
+	tests := []struct {
+		status                         InstanceStatus
+		terminal, failed, transitional bool
+	}{
+		{status: InstanceStatusActive, terminal: true},
+		{status: InstanceStatusShutoff, terminal: true},
+		{status: InstanceStatusError, terminal: true, failed: true},
+		{status: InstanceStatusBuild, transitional: true},
+		{status: InstanceStatusResize, transitional: true},
+		{status: InstanceStatusUnknown},
+		{status: "HIBERNATED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status.String(), func(t *testing.T) {
+			assert.Equal(t, tt.terminal, tt.status.IsTerminal())
+			assert.Equal(t, tt.failed, tt.status.IsError())
+			assert.Equal(t, tt.transitional, tt.status.IsTransitional())
+		})
+	}
+}
+
+func TestStatus_CanTransitionTo(t *testing.T) {
+	assert.True(t, InstanceStatusBuild.CanTransitionTo(InstanceStatusActive))
+	assert.False(t, InstanceStatusBuild.CanTransitionTo(InstanceStatusShutoff))
+	assert.True(t, InstanceStatusActive.CanTransitionTo(InstanceStatusActive))
+	assert.True(t, InstanceStatusActive.CanTransitionTo("HIBERNATED"))
+	assert.True(t, InstanceStatusUnknown.CanTransitionTo(InstanceStatusActive))
+	assert.True(t, VolumeStatusAvailable.CanTransitionTo(VolumeStatusAttaching))
+	assert.False(t, VolumeStatusCreating.CanTransitionTo(VolumeStatusInUse))
+	assert.True(t, VolumeStatusDeleting.CanTransitionTo(VolumeStatusDeleted))
+	assert.False(t, VolumeStatusDeleted.CanTransitionTo(VolumeStatusAvailable))
+	assert.False(t, SnapshotStatusDeleted.CanTransitionTo(SnapshotStatusAvailable))
+	assert.True(t, ImageStatusQueued.CanTransitionTo(ImageStatusSaving))
+	assert.False(t, RouterStatusBuild.CanTransitionTo(RouterStatusDown))
+}
+
+func TestStatus_Graphs(t *testing.T) {
+	checkStatusGraph(t, instanceStatuses)
+	checkStatusGraph(t, instanceVMStates)
+	checkStatusGraph(t, volumeStatuses)
+	checkStatusGraph(t, snapshotStatuses)
+	checkStatusGraph(t, imageStatuses)
+	checkStatusGraph(t, routerStatuses)
+}
+
+func checkStatusGraph[S ~string](t *testing.T, graph map[S]statusSpec[S]) {
+	t.Helper()
+
+	for status, spec := range graph {
+		assert.False(t, spec.transitional && spec.failed, "%s is both transitional and error", status)
+		if spec.transitional {
+			assert.True(t, slices.ContainsFunc(spec.next, func(next S) bool { return !graph[next].failed }),
+				"%s only leads to error statuses", status)
+		}
+		for _, next := range spec.next {
+			_, ok := graph[next]
+			assert.True(t, ok, "%s -> %s leads to an unknown status", status, next)
+		}
+	}
+}
+
+func TestStatus_UnmarshalUnknown(t *testing.T) {
+	var instance Instance
+	err := json.Unmarshal([]byte(`{"status":"HIBERNATED","vm_state":"hibernated"}`), &instance)
+	require.NoError(t, err)
+	assert.Equal(t, InstanceStatus("HIBERNATED"), instance.Status)
+	assert.Equal(t, InstanceVMState("hibernated"), instance.VMState)
+
+	var volume Volume
+	err = json.Unmarshal([]byte(`{"status":"error_managing"}`), &volume)
+	require.NoError(t, err)
+	assert.False(t, volume.Status.IsTerminal())
+
+	data, err := json.Marshal(volume)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"status":"error_managing"`)
+}
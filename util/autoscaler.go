@@ -0,0 +1,393 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const defaultAutoscalerInterval = time.Minute
+
+// Metrics of the instance utilization the autoscaler scales by, see InstanceMetricSeries.
+const (
+	MetricInstanceCPUUtil    = "instance_cpu_util"
+	MetricInstanceMemoryUtil = "instance_memory_util"
+)
+
+var (
+	ErrAutoscalerInvalid       = errors.New("invalid autoscaler")
+	ErrInstanceAddressNotFound = errors.New("instance has no fixed address in the subnet")
+)
+
+// Clock provides the current time and timers. It is replaced by a fake clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// AutoscaleAction is the action decided by the autoscaler.
+type AutoscaleAction string
+
+const (
+	AutoscaleActionNone     AutoscaleAction = "none"
+	AutoscaleActionScaleOut AutoscaleAction = "scale_out"
+	AutoscaleActionScaleIn  AutoscaleAction = "scale_in"
+)
+
+// AutoscalerPool is the loadbalancer pool the instances of the group are members of.
+type AutoscalerPool struct {
+	PoolID       string
+	ProtocolPort int
+	// SubnetID is the subnet of the instance address added to the pool. The first fixed address in the order of the
+	// network names is used if empty.
+	SubnetID string
+	Weight   int
+}
+
+// AutoscaleDecision describes a single evaluation of the autoscaler.
+type AutoscaleDecision struct {
+	Time    time.Time
+	Action  AutoscaleAction
+	Reason  string
+	Current int
+	Desired int
+	// CPU and Memory are the average utilization of the group, in percent.
+	CPU     float64
+	Memory  float64
+	Created []string
+	Deleted []string
+}
+
+// Autoscaler scales an instance group by the CPU and memory utilization of its members. The utilization is
+// averaged over the metrics window of every member and then over the members. The group scales out when
+// any enabled scale out threshold is exceeded, and scales in when all enabled utilization metrics are below
+// their scale in thresholds. A zero threshold is disabled.
+type Autoscaler struct {
+	Group    *InstanceGroup
+	MinCount int
+	MaxCount int
+
+	ScaleOutCPU    float64
+	ScaleOutMemory float64
+	ScaleInCPU     float64
+	ScaleInMemory  float64
+
+	// ScaleOutStep and ScaleInStep are the number of instances added or removed at once, 1 by default.
+	ScaleOutStep int
+	ScaleInStep  int
+	// ScaleOutCooldown and ScaleInCooldown are the minimum time since the last scaling before the next one.
+	ScaleOutCooldown time.Duration
+	ScaleInCooldown  time.Duration
+
+	// MetricsWindow is the period of the metrics requested for every member, the last hour by default.
+	MetricsWindow *edgecloud.InstanceMetricsListRequest
+	// Pool is the loadbalancer pool to add new instances to and to remove deleted instances from. Optional.
+	Pool *AutoscalerPool
+	// Interval is the time between evaluations in Run, 1 minute by default.
+	Interval time.Duration
+	// Clock is the real clock by default.
+	Clock Clock
+	// OnDecision is called by Run after every evaluation. Optional.
+	OnDecision func(*AutoscaleDecision, error)
+
+	lastScale time.Time
+}
+
+// Validate checks the parameters of the autoscaler.
+func (a *Autoscaler) Validate() error {
+	if a.Group == nil {
+		return fmt.Errorf("%w: group cannot be nil", ErrAutoscalerInvalid)
+	}
+
+	if a.MinCount < 0 || a.MaxCount < a.MinCount {
+		return fmt.Errorf("%w: invalid count range [%d, %d]", ErrAutoscalerInvalid, a.MinCount, a.MaxCount)
+	}
+
+	return a.Group.Validate()
+}
+
+// Run evaluates the autoscaler every interval until the context is canceled.
+func (a *Autoscaler) Run(ctx context.Context, client *edgecloud.Client) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+
+	interval := a.Interval
+	if interval == 0 {
+		interval = defaultAutoscalerInterval
+	}
+
+	for ctx.Err() == nil {
+		decision, err := a.Step(ctx, client)
+		if a.OnDecision != nil {
+			a.OnDecision(decision, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.clock().After(interval):
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Step evaluates the utilization of the group once and scales it if required.
+func (a *Autoscaler) Step(ctx context.Context, client *edgecloud.Client) (*AutoscaleDecision, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	members, err := a.Group.Members(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.clock().Now()
+	decision := &AutoscaleDecision{Time: now, Action: AutoscaleActionNone, Current: len(members), Desired: len(members)}
+
+	switch {
+	case len(members) < a.MinCount:
+		decision.Desired, decision.Reason = a.MinCount, "below the minimum count"
+	case len(members) > a.MaxCount:
+		decision.Desired, decision.Reason = a.MaxCount, "above the maximum count"
+	default:
+		if err = a.decide(ctx, client, members, decision); err != nil {
+			return decision, err
+		}
+	}
+
+	switch {
+	case decision.Desired > decision.Current:
+		decision.Action = AutoscaleActionScaleOut
+		err = a.scaleOut(ctx, client, decision)
+	case decision.Desired < decision.Current:
+		decision.Action = AutoscaleActionScaleIn
+		err = a.scaleIn(ctx, client, members, decision)
+	default:
+		return decision, nil
+	}
+
+	if err == nil {
+		a.lastScale = now
+	}
+
+	return decision, err
+}
+
+// decide sets the desired count of the decision by the utilization of the members.
+func (a *Autoscaler) decide(ctx context.Context, client *edgecloud.Client, members []edgecloud.Instance, decision *AutoscaleDecision) error {
+	cpu, memory, ok, err := a.utilization(ctx, client, members)
+	if err != nil || !ok {
+		return err
+	}
+	decision.CPU, decision.Memory = cpu, memory
+
+	now := decision.Time
+	scaleOut := a.ScaleOutCPU > 0 && cpu > a.ScaleOutCPU || a.ScaleOutMemory > 0 && memory > a.ScaleOutMemory
+	scaleIn := (a.ScaleInCPU > 0 || a.ScaleInMemory > 0) &&
+		(a.ScaleInCPU == 0 || cpu < a.ScaleInCPU) && (a.ScaleInMemory == 0 || memory < a.ScaleInMemory)
+
+	switch {
+	case scaleOut && len(members) < a.MaxCount:
+		if !a.lastScale.IsZero() && now.Sub(a.lastScale) < a.ScaleOutCooldown {
+			decision.Reason = "scale out cooldown"
+			return nil
+		}
+		decision.Desired = min(len(members)+max(a.ScaleOutStep, 1), a.MaxCount)
+		decision.Reason = fmt.Sprintf("utilization above the threshold: cpu %.1f%%, memory %.1f%%", cpu, memory)
+	case scaleIn && len(members) > a.MinCount:
+		if !a.lastScale.IsZero() && now.Sub(a.lastScale) < a.ScaleInCooldown {
+			decision.Reason = "scale in cooldown"
+			return nil
+		}
+		decision.Desired = max(len(members)-max(a.ScaleInStep, 1), a.MinCount)
+		decision.Reason = fmt.Sprintf("utilization below the threshold: cpu %.1f%%, memory %.1f%%", cpu, memory)
+	}
+
+	return nil
+}
+
+// utilization returns the average CPU and memory utilization of the members. It returns false if no metrics were reported.
+func (a *Autoscaler) utilization(ctx context.Context, client *edgecloud.Client, members []edgecloud.Instance) (float64, float64, bool, error) {
+	window := a.MetricsWindow
+	if window == nil {
+		window = &edgecloud.InstanceMetricsListRequest{TimeUnit: edgecloud.TimeUnitHour, TimeInterval: 1}
+	}
+
+	var cpu, memory float64
+	var count int
+	for _, member := range members {
+		series, err := InstanceMetricSeriesList(ctx, client, member.ID, window)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		reported := false
+		for _, s := range series {
+			switch s.Name {
+			case MetricInstanceCPUUtil:
+				cpu += s.Aggregate().Avg
+				reported = true
+			case MetricInstanceMemoryUtil:
+				memory += s.Aggregate().Avg
+			}
+		}
+
+		if reported {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, 0, false, nil
+	}
+
+	return cpu / float64(count), memory / float64(count), true, nil
+}
+
+func (a *Autoscaler) scaleOut(ctx context.Context, client *edgecloud.Client, decision *AutoscaleDecision) error {
+	hash, err := a.Group.TemplateHash()
+	if err != nil {
+		return err
+	}
+
+	result := &InstanceGroupReconcileResult{}
+	created, err := a.Group.createMembers(ctx, client, hash, decision.Desired-decision.Current, result)
+	decision.Created = result.Created
+	if err != nil || a.Pool == nil {
+		return err
+	}
+
+	for _, instance := range created {
+		if err = a.addPoolMember(ctx, client, &instance); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scaleIn removes the newest members from the pool and deletes them.
+func (a *Autoscaler) scaleIn(ctx context.Context, client *edgecloud.Client, members []edgecloud.Instance, decision *AutoscaleDecision) error {
+	victims := members[decision.Desired:]
+
+	if a.Pool != nil {
+		for _, instance := range victims {
+			if err := a.removePoolMember(ctx, client, &instance); err != nil {
+				return err
+			}
+		}
+	}
+
+	result := &InstanceGroupReconcileResult{}
+	err := a.Group.deleteMembers(ctx, client, victims, result)
+	decision.Deleted = result.Deleted
+
+	return err
+}
+
+func (a *Autoscaler) addPoolMember(ctx context.Context, client *edgecloud.Client, instance *edgecloud.Instance) error {
+	address := instanceFixedAddress(instance, a.Pool.SubnetID)
+	if address == nil {
+		return fmt.Errorf("%w: instance %s, subnet %s", ErrInstanceAddressNotFound, instance.ID, a.Pool.SubnetID)
+	}
+
+	pool, _, err := client.Loadbalancers.PoolGet(ctx, a.Pool.PoolID)
+	if err != nil {
+		return err
+	}
+
+	return a.changePoolMembers(ctx, client, pool, func() (*edgecloud.TaskResponse, *edgecloud.Response, error) {
+		return client.Loadbalancers.PoolMemberCreate(ctx, a.Pool.PoolID, &edgecloud.PoolMemberCreateRequest{
+			Address:      address,
+			ProtocolPort: a.Pool.ProtocolPort,
+			SubnetID:     a.Pool.SubnetID,
+			InstanceID:   instance.ID,
+			Weight:       a.Pool.Weight,
+		})
+	})
+}
+
+// removePoolMember removes every member of the pool on the pool port that is the instance or has its address.
+func (a *Autoscaler) removePoolMember(ctx context.Context, client *edgecloud.Client, instance *edgecloud.Instance) error {
+	pool, _, err := client.Loadbalancers.PoolGet(ctx, a.Pool.PoolID)
+	if err != nil {
+		return err
+	}
+
+	address := instanceFixedAddress(instance, a.Pool.SubnetID)
+	for _, member := range pool.Members {
+		if member.ProtocolPort != a.Pool.ProtocolPort {
+			continue
+		}
+		if member.InstanceID != instance.ID && (address == nil || !address.Equal(member.Address)) {
+			continue
+		}
+
+		memberID := member.ID
+		err = a.changePoolMembers(ctx, client, pool, func() (*edgecloud.TaskResponse, *edgecloud.Response, error) {
+			return client.Loadbalancers.PoolMemberDelete(ctx, a.Pool.PoolID, memberID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changePoolMembers runs a change of the pool members with the loadbalancer of the pool in the ACTIVE
+// provisioning status before and after it, since the loadbalancer rejects changes while it is updating.
+func (a *Autoscaler) changePoolMembers(ctx context.Context, client *edgecloud.Client, pool *edgecloud.Pool, change func() (*edgecloud.TaskResponse, *edgecloud.Response, error)) error {
+	if len(pool.Loadbalancers) == 0 {
+		return fmt.Errorf("%w: %s", ErrPoolHasNoLoadbalancer, pool.ID)
+	}
+	loadbalancerID := pool.Loadbalancers[0].ID
+
+	if err := WaitLoadbalancerProvisioningStatusActive(ctx, client, loadbalancerID, a.Group.Attempts); err != nil {
+		return err
+	}
+
+	task, _, err := change()
+	if err != nil {
+		return err
+	}
+
+	if err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(a.Group.Timeout)...); err != nil {
+		return err
+	}
+
+	return WaitLoadbalancerProvisioningStatusActive(ctx, client, loadbalancerID, a.Group.Attempts)
+}
+
+func (a *Autoscaler) clock() Clock {
+	if a.Clock == nil {
+		return realClock{}
+	}
+
+	return a.Clock
+}
+
+// instanceFixedAddress returns the fixed address of the instance in the subnet, or the first fixed address
+// in the order of the network names if the subnet is empty.
+func instanceFixedAddress(instance *edgecloud.Instance, subnetID string) net.IP {
+	for _, address := range instanceAddresses(*instance) {
+		if address.Type == string(edgecloud.AddressTypeFixed) && (subnetID == "" || address.SubnetID == subnetID) {
+			return address.Address
+		}
+	}
+
+	return nil
+}
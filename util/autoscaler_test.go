@@ -0,0 +1,317 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After advances the clock by d and fires immediately.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// fakeMetricsInstances reports the same utilization for every instance and gives every instance named
+// "<group>-<n>" the fixed address 10.0.0.<n> in the test subnet.
+type fakeMetricsInstances struct {
+	edgecloud.InstancesService
+	cpu, memory int
+}
+
+func (f *fakeMetricsInstances) List(ctx context.Context, opts *edgecloud.InstanceListOptions) ([]edgecloud.Instance, *edgecloud.Response, error) {
+	instances, resp, err := f.InstancesService.List(ctx, opts)
+	for i := range instances {
+		withTestAddress(&instances[i])
+	}
+
+	return instances, resp, err
+}
+
+func (f *fakeMetricsInstances) Get(ctx context.Context, instanceID string) (*edgecloud.Instance, *edgecloud.Response, error) {
+	instance, resp, err := f.InstancesService.Get(ctx, instanceID)
+	if instance != nil {
+		withTestAddress(instance)
+	}
+
+	return instance, resp, err
+}
+
+func withTestAddress(instance *edgecloud.Instance) {
+	n := instance.Name[strings.LastIndex(instance.Name, "-")+1:]
+	instance.Addresses = map[string][]edgecloud.InstanceAddress{"private": {{
+		Type:     string(edgecloud.AddressTypeFixed),
+		SubnetID: testSubnetID,
+		Address:  net.ParseIP("10.0.0." + n),
+	}}}
+}
+
+func (f *fakeMetricsInstances) MetricsList(_ context.Context, _ string, _ *edgecloud.InstanceMetricsListRequest) ([]edgecloud.InstanceMetrics, *edgecloud.Response, error) {
+	return []edgecloud.InstanceMetrics{
+		{Time: "2024-01-01T00:00:00Z", CPUUtil: f.cpu - 5, MemoryUtil: f.memory},
+		{Time: "2024-01-01T00:01:00Z", CPUUtil: f.cpu + 5, MemoryUtil: f.memory},
+	}, nil, nil
+}
+
+// fakePoolMembers keeps the members of a single pool of an active loadbalancer.
+type fakePoolMembers struct {
+	edgecloud.LoadbalancersService
+	members []edgecloud.PoolMember
+	// statusChecks counts the provisioning status checks of the loadbalancer.
+	statusChecks int
+	// createErr is returned by the member creation if set.
+	createErr error
+}
+
+func (f *fakePoolMembers) Get(_ context.Context, loadbalancerID string) (*edgecloud.Loadbalancer, *edgecloud.Response, error) {
+	f.statusChecks++
+
+	return &edgecloud.Loadbalancer{ID: loadbalancerID, ProvisioningStatus: edgecloud.ProvisioningStatusActive}, nil, nil
+}
+
+func (f *fakePoolMembers) PoolGet(_ context.Context, poolID string) (*edgecloud.Pool, *edgecloud.Response, error) {
+	return &edgecloud.Pool{ID: poolID, Loadbalancers: []edgecloud.ID{{ID: testResourceID3}}, Members: slices.Clone(f.members)}, nil, nil
+}
+
+func (f *fakePoolMembers) PoolMemberCreate(_ context.Context, _ string, reqBody *edgecloud.PoolMemberCreateRequest) (*edgecloud.TaskResponse, *edgecloud.Response, error) {
+	if f.createErr != nil {
+		return nil, nil, f.createErr
+	}
+	f.members = append(f.members, edgecloud.PoolMember{ID: uuid.NewString(), PoolMemberCreateRequest: *reqBody})
+
+	return &edgecloud.TaskResponse{Tasks: []string{uuid.NewString()}}, nil, nil
+}
+
+func (f *fakePoolMembers) PoolMemberDelete(_ context.Context, _, memberID string) (*edgecloud.TaskResponse, *edgecloud.Response, error) {
+	for i, member := range f.members {
+		if member.ID == memberID {
+			f.members = append(f.members[:i], f.members[i+1:]...)
+			break
+		}
+	}
+
+	return &edgecloud.TaskResponse{Tasks: []string{uuid.NewString()}}, nil, nil
+}
+
+func testAutoscaler(t *testing.T) (*Autoscaler, *edgecloud.Client, *fakeInstancesAPI, *fakeMetricsInstances, *fakePoolMembers, *fakeClock) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	api := newFakeInstancesAPI(t, mux, nil)
+	client := newTestClient(server.URL)
+
+	metrics := &fakeMetricsInstances{InstancesService: client.Instances}
+	pool := &fakePoolMembers{LoadbalancersService: client.Loadbalancers}
+	client.Instances, client.Loadbalancers = metrics, pool
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	group := testInstanceGroup()
+	group.DesiredCount = 1
+
+	autoscaler := &Autoscaler{
+		Group:            group,
+		MinCount:         1,
+		MaxCount:         3,
+		ScaleOutCPU:      70,
+		ScaleInCPU:       20,
+		ScaleInMemory:    50,
+		ScaleOutCooldown: 5 * time.Minute,
+		ScaleInCooldown:  10 * time.Minute,
+		Pool:             &AutoscalerPool{PoolID: testResourceID, ProtocolPort: 80, SubnetID: testSubnetID},
+		Clock:            clock,
+	}
+
+	return autoscaler, client, api, metrics, pool, clock
+}
+
+func TestAutoscaler_Step(t *testing.T) {
+	autoscaler, client, api, metrics, pool, clock := testAutoscaler(t)
+	ctx := context.Background()
+
+	// the group is created up to the minimum count regardless of the metrics
+	decision, err := autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionScaleOut, decision.Action)
+	assert.Equal(t, "below the minimum count", decision.Reason)
+	require.Len(t, pool.members, 1)
+	assert.Equal(t, net.ParseIP("10.0.0.1").String(), pool.members[0].Address.String())
+	// the loadbalancer is active before and after the member is added
+	assert.Equal(t, 2, pool.statusChecks)
+
+	metrics.cpu, metrics.memory = 90, 40
+	clock.Advance(10 * time.Minute)
+	decision, err = autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionScaleOut, decision.Action)
+	assert.Equal(t, 2, decision.Desired)
+	assert.InDelta(t, 90.0, decision.CPU, 0.01)
+	assert.Len(t, decision.Created, 1)
+	assert.Len(t, api.members(autoscaler.Group.Name), 2)
+	assert.Len(t, pool.members, 2)
+
+	clock.Advance(time.Minute)
+	decision, err = autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionNone, decision.Action)
+	assert.Equal(t, "scale out cooldown", decision.Reason)
+
+	// memory is above the scale in threshold
+	metrics.cpu, metrics.memory = 10, 60
+	clock.Advance(time.Hour)
+	decision, err = autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionNone, decision.Action)
+
+	metrics.memory = 30
+	members := api.members(autoscaler.Group.Name)
+	decision, err = autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionScaleIn, decision.Action)
+	assert.Equal(t, []string{members[1].ID}, decision.Deleted)
+	assert.Len(t, api.members(autoscaler.Group.Name), 1)
+	require.Len(t, pool.members, 1)
+	assert.Equal(t, members[0].ID, pool.members[0].InstanceID)
+
+	// the minimum count is kept
+	clock.Advance(time.Hour)
+	decision, err = autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionNone, decision.Action)
+}
+
+func TestAutoscaler_Run(t *testing.T) {
+	autoscaler, client, api, metrics, _, clock := testAutoscaler(t)
+	metrics.cpu = 90
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var decisions []*AutoscaleDecision
+	autoscaler.Interval = 3 * time.Minute
+	autoscaler.OnDecision = func(decision *AutoscaleDecision, err error) {
+		assert.NoError(t, err)
+		decisions = append(decisions, decision)
+		if len(decisions) == 5 {
+			cancel()
+		}
+	}
+
+	err := autoscaler.Run(ctx, client)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	actions := make([]string, 0, len(decisions))
+	for _, d := range decisions {
+		actions = append(actions, fmt.Sprintf("%s %d", d.Action, d.Desired))
+	}
+	// scale out is allowed every other interval because of the cooldown
+	assert.Equal(t, []string{"scale_out 1", "none 1", "scale_out 2", "none 2", "scale_out 3"}, actions)
+	assert.Len(t, api.members(autoscaler.Group.Name), 3)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC), clock.Now())
+}
+
+func TestAutoscaler_StepFailed(t *testing.T) {
+	autoscaler, client, _, metrics, pool, clock := testAutoscaler(t)
+	ctx := context.Background()
+
+	errCreate := errors.New("create failed")
+	pool.createErr = errCreate
+	decision, err := autoscaler.Step(ctx, client)
+	assert.ErrorIs(t, err, errCreate)
+	assert.Len(t, decision.Created, 1)
+
+	// the failed scaling does not start the cooldown
+	pool.createErr = nil
+	metrics.cpu = 90
+	clock.Advance(time.Minute)
+	decision, err = autoscaler.Step(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, AutoscaleActionScaleOut, decision.Action)
+}
+
+func TestAutoscaler_RemovePoolMember(t *testing.T) {
+	autoscaler, client, _, _, pool, _ := testAutoscaler(t)
+
+	instance := &edgecloud.Instance{ID: testInstanceID2, Name: "web-1"}
+	withTestAddress(instance)
+	member := func(id, address string, port int, instanceID string) edgecloud.PoolMember {
+		return edgecloud.PoolMember{ID: id, PoolMemberCreateRequest: edgecloud.PoolMemberCreateRequest{
+			Address: net.ParseIP(address), ProtocolPort: port, InstanceID: instanceID,
+		}}
+	}
+	pool.members = []edgecloud.PoolMember{
+		member("instance", "10.0.0.1", 80, testInstanceID2),
+		member("address", "10.0.0.1", 80, ""),
+		member("other-port", "10.0.0.1", 443, ""),
+		member("other-address", "10.0.0.2", 80, ""),
+	}
+
+	require.NoError(t, autoscaler.removePoolMember(context.Background(), client, instance))
+	ids := make([]string, 0, len(pool.members))
+	for _, m := range pool.members {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"other-port", "other-address"}, ids)
+}
+
+func TestInstanceFixedAddress(t *testing.T) {
+	instance := &edgecloud.Instance{Addresses: map[string][]edgecloud.InstanceAddress{
+		"net-c": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.2.1"), SubnetID: "subnet-c"}},
+		"net-a": {
+			{Type: string(edgecloud.AddressTypeFloating), Address: net.ParseIP("203.0.113.1")},
+			{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.0.1"), SubnetID: "subnet-a"},
+		},
+		"net-b": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.1.1"), SubnetID: "subnet-b"}},
+	}}
+
+	for range 10 {
+		assert.Equal(t, "10.0.0.1", instanceFixedAddress(instance, "").String())
+	}
+	assert.Equal(t, "10.0.2.1", instanceFixedAddress(instance, "subnet-c").String())
+	assert.Nil(t, instanceFixedAddress(instance, "subnet-d"))
+}
+
+func TestAutoscaler_Validate(t *testing.T) {
+	autoscaler := &Autoscaler{Group: testInstanceGroup(), MinCount: 3, MaxCount: 1}
+
+	_, err := autoscaler.Step(context.Background(), edgecloud.NewClient(nil))
+	assert.ErrorIs(t, err, ErrAutoscalerInvalid)
+}
@@ -0,0 +1,255 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// Quota resources checked by PlanInstanceCapacity. The quota limit and usage are
+// reported by the API under the "<resource>_limit" and "<resource>_usage" keys.
+const (
+	QuotaInstanceCount   = "vm_count"
+	QuotaCPUCount        = "cpu_count"
+	QuotaRAM             = "ram"
+	QuotaVolumeCount     = "volume_count"
+	QuotaVolumeSize      = "volume_size"
+	QuotaPortCount       = "port_count"
+	QuotaFloatingIPCount = "floating_count"
+	QuotaExternalIPCount = "external_ip_count"
+)
+
+// QuotaScope is the scope of the quota a requirement was compared against.
+type QuotaScope string
+
+const (
+	QuotaScopeRegional QuotaScope = "regional"
+	QuotaScopeGlobal   QuotaScope = "global"
+)
+
+var (
+	ErrFlavorNotFound    = errors.New("flavor not found")
+	ErrQuotaInsufficient = errors.New("insufficient quota")
+)
+
+// QuotaVolumeSizeByType returns the quota resource for the size of volumes of the volume type.
+func QuotaVolumeSizeByType(volumeType edgecloud.VolumeType) string {
+	return fmt.Sprintf("%s_%s", volumeType, QuotaVolumeSize)
+}
+
+// CapacityCheck is a comparison of the required amount of a resource with its quota.
+type CapacityCheck struct {
+	Resource  string
+	Scope     QuotaScope
+	Required  int
+	Limit     int
+	Usage     int
+	Available int
+	// Shortfall is the amount of the resource missing to satisfy the requirement.
+	Shortfall int
+}
+
+// CapacityReport is the result of the capacity pre-flight check.
+type CapacityReport struct {
+	// InstanceCount is the number of instances in the request.
+	InstanceCount int
+	// Required is the total amount of each resource required by the request.
+	Required map[string]int
+	// Checks are ordered by resource, the regional check of a resource comes before the global one.
+	Checks []CapacityCheck
+	// Unchecked are the required resources the quotas have no limit for.
+	Unchecked []string
+}
+
+// Shortfalls returns the checks that do not fit into the quota.
+func (r *CapacityReport) Shortfalls() []CapacityCheck {
+	var shortfalls []CapacityCheck
+	for _, check := range r.Checks {
+		if check.Shortfall > 0 {
+			shortfalls = append(shortfalls, check)
+		}
+	}
+
+	return shortfalls
+}
+
+// OK reports whether the request fits into the quotas.
+func (r *CapacityReport) OK() bool {
+	return len(r.Shortfalls()) == 0
+}
+
+// Err returns an error describing every shortfall, or nil if the request fits into the quotas.
+func (r *CapacityReport) Err() error {
+	shortfalls := r.Shortfalls()
+	if len(shortfalls) == 0 {
+		return nil
+	}
+
+	details := make([]string, 0, len(shortfalls))
+	for _, s := range shortfalls {
+		details = append(details, fmt.Sprintf("%s %s: required %d, available %d, missing %d",
+			s.Scope, s.Resource, s.Required, s.Available, s.Shortfall))
+	}
+
+	return fmt.Errorf("%w: %s", ErrQuotaInsufficient, strings.Join(details, "; "))
+}
+
+// PlanInstanceCapacity computes the resources required to create the instances of the request and compares them
+// with the regional and global quotas of the client. No resources are created. Each resource is checked against
+// the quota of the client region and the global quota, both checks are reported if both quotas limit it.
+func PlanInstanceCapacity(ctx context.Context, client *edgecloud.Client, reqBody *edgecloud.InstanceCreateRequest) (*CapacityReport, error) {
+	if reqBody == nil {
+		return nil, edgecloud.NewArgError("reqBody", "cannot be nil")
+	}
+
+	flavor, err := instanceFlavorByID(ctx, client, reqBody.Flavor)
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := instanceCreateRequirements(ctx, client, flavor, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas, _, err := client.Quotas.ListCombined(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CapacityReport{
+		InstanceCount: len(reqBody.Names) + len(reqBody.NameTemplates),
+		Required:      required,
+	}
+
+	regional := regionalQuota(quotas, client.Region)
+	for _, resource := range sortedKeys(required) {
+		amount := required[resource]
+		if amount == 0 {
+			continue
+		}
+
+		regionalCheck, regionalOK := quotaCheck(regional, QuotaScopeRegional, resource, amount)
+		if regionalOK {
+			report.Checks = append(report.Checks, regionalCheck)
+		}
+
+		globalCheck, globalOK := quotaCheck(quotas.GlobalQuotas, QuotaScopeGlobal, resource, amount)
+		if globalOK {
+			report.Checks = append(report.Checks, globalCheck)
+		}
+
+		if !regionalOK && !globalOK {
+			report.Unchecked = append(report.Unchecked, resource)
+		}
+	}
+
+	return report, nil
+}
+
+// instanceCreateRequirements returns the amount of each resource required by the request.
+func instanceCreateRequirements(ctx context.Context, client *edgecloud.Client, flavor *edgecloud.Flavor, reqBody *edgecloud.InstanceCreateRequest) (map[string]int, error) {
+	count := len(reqBody.Names) + len(reqBody.NameTemplates)
+
+	perInstance := map[string]int{
+		QuotaInstanceCount: 1,
+		QuotaCPUCount:      flavor.VCPUS,
+		QuotaRAM:           flavor.RAM,
+	}
+
+	for _, volume := range reqBody.Volumes {
+		size := volume.Size
+		switch volume.Source {
+		case edgecloud.VolumeSourceExistingVolume:
+			continue
+		case edgecloud.VolumeSourceSnapshot:
+			if size == 0 {
+				snapshot, _, err := client.Snapshots.Get(ctx, volume.SnapshotID)
+				if err != nil {
+					return nil, err
+				}
+				size = snapshot.Size
+			}
+		}
+
+		volumeType := volume.TypeName
+		if volumeType == "" {
+			volumeType = edgecloud.VolumeTypeStandard
+		}
+
+		perInstance[QuotaVolumeCount]++
+		perInstance[QuotaVolumeSize] += size
+		perInstance[QuotaVolumeSizeByType(volumeType)] += size
+	}
+
+	for _, iface := range reqBody.Interfaces {
+		switch iface.Type {
+		case edgecloud.InterfaceTypeExternal:
+			perInstance[QuotaExternalIPCount]++
+		case edgecloud.InterfaceTypeReservedFixedIP:
+			// the port of a reserved fixed IP already exists
+		default:
+			perInstance[QuotaPortCount]++
+		}
+
+		if iface.FloatingIP != nil && iface.FloatingIP.Source == edgecloud.NewFloatingIP {
+			perInstance[QuotaFloatingIPCount]++
+		}
+	}
+
+	required := make(map[string]int, len(perInstance))
+	for resource, amount := range perInstance {
+		required[resource] = amount * count
+	}
+
+	return required, nil
+}
+
+func instanceFlavorByID(ctx context.Context, client *edgecloud.Client, flavorID string) (*edgecloud.Flavor, error) {
+	flavors, _, err := client.Flavors.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range flavors {
+		if f.FlavorID == flavorID {
+			return &f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrFlavorNotFound, flavorID)
+}
+
+func regionalQuota(quotas *edgecloud.CombinedQuota, regionID int) edgecloud.Quota {
+	for _, quota := range quotas.RegionalQuotas {
+		if quota["region_id"] == regionID {
+			return quota
+		}
+	}
+
+	return nil
+}
+
+// quotaCheck compares the required amount with the quota. A negative limit is treated as unlimited.
+func quotaCheck(quota edgecloud.Quota, scope QuotaScope, resource string, required int) (CapacityCheck, bool) {
+	limit, ok := quota[resource+"_limit"]
+	if !ok || limit < 0 {
+		return CapacityCheck{}, false
+	}
+
+	usage := quota[resource+"_usage"]
+	check := CapacityCheck{
+		Resource:  resource,
+		Scope:     scope,
+		Required:  required,
+		Limit:     limit,
+		Usage:     usage,
+		Available: max(limit-usage, 0),
+	}
+	check.Shortfall = max(required-check.Available, 0)
+
+	return check, true
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+func testCapacityServer(t *testing.T, quotas edgecloud.CombinedQuota) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	URLFlavors := path.Join("/v1/flavors", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URLFlavors, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, map[string]interface{}{"results": []edgecloud.Flavor{
+			{FlavorID: testFlavorID, VCPUS: 2, RAM: 4096},
+		}})
+	})
+
+	URLSnapshot := path.Join("/v1/snapshots", strconv.Itoa(projectID), strconv.Itoa(regionID), testSnapshotID)
+	mux.HandleFunc(URLSnapshot, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Snapshot{ID: testSnapshotID, Size: 20})
+	})
+
+	mux.HandleFunc("/v2/quotas_client", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, quotas)
+	})
+
+	return server
+}
+
+func testCapacityRequest() *edgecloud.InstanceCreateRequest {
+	return &edgecloud.InstanceCreateRequest{
+		Names:  []string{"web-1", "web-2", "web-3"},
+		Flavor: testFlavorID,
+		Interfaces: []edgecloud.InstanceInterface{
+			{Type: edgecloud.InterfaceTypeSubnet, FloatingIP: &edgecloud.InterfaceFloatingIP{Source: edgecloud.NewFloatingIP}},
+			{Type: edgecloud.InterfaceTypeExternal},
+		},
+		Volumes: []edgecloud.InstanceVolumeCreate{
+			{Source: edgecloud.VolumeSourceSnapshot, SnapshotID: testSnapshotID, TypeName: edgecloud.VolumeTypeSsdHiIops},
+			{Source: edgecloud.VolumeSourceNewVolume, Size: 50},
+			{Source: edgecloud.VolumeSourceExistingVolume, VolumeID: testVolumeID},
+		},
+	}
+}
+
+func TestPlanInstanceCapacity(t *testing.T) {
+	server := testCapacityServer(t, edgecloud.CombinedQuota{
+		GlobalQuotas: edgecloud.Quota{
+			"floating_count_limit": 10,
+			"floating_count_usage": 1,
+			// tighter than the regional quota
+			"cpu_count_limit": 20,
+			"cpu_count_usage": 16,
+			// looser than the regional quota
+			"ram_limit": 1 << 20,
+		},
+		RegionalQuotas: []edgecloud.Quota{
+			{"region_id": regionID + 1, "cpu_count_limit": 1},
+			{
+				"region_id":       regionID,
+				"vm_count_limit":  10,
+				"vm_count_usage":  2,
+				"cpu_count_limit": 16,
+				"cpu_count_usage": 4,
+				"ram_limit":       65536,
+				"ram_usage":       8192,
+				// unlimited
+				"volume_size_limit":            -1,
+				"ssd_hiiops_volume_size_limit": 100,
+				"ssd_hiiops_volume_size_usage": 80,
+				"standard_volume_size_limit":   1000,
+				"external_ip_count_limit":      5,
+				"external_ip_count_usage":      4,
+			},
+		},
+	})
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+
+	report, err := PlanInstanceCapacity(context.Background(), client, testCapacityRequest())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.InstanceCount)
+	assert.Equal(t, map[string]int{
+		QuotaInstanceCount:       3,
+		QuotaCPUCount:            6,
+		QuotaRAM:                 12288,
+		QuotaVolumeCount:         6,
+		QuotaVolumeSize:          210,
+		"ssd_hiiops_volume_size": 60,
+		"standard_volume_size":   150,
+		QuotaPortCount:           3,
+		QuotaFloatingIPCount:     3,
+		QuotaExternalIPCount:     3,
+	}, report.Required)
+	assert.Equal(t, []string{QuotaPortCount, QuotaVolumeCount, QuotaVolumeSize}, report.Unchecked)
+
+	assert.False(t, report.OK())
+	assert.Equal(t, []CapacityCheck{
+		{Resource: QuotaCPUCount, Scope: QuotaScopeGlobal, Required: 6, Limit: 20, Usage: 16, Available: 4, Shortfall: 2},
+		{Resource: QuotaExternalIPCount, Scope: QuotaScopeRegional, Required: 3, Limit: 5, Usage: 4, Available: 1, Shortfall: 2},
+		{Resource: "ssd_hiiops_volume_size", Scope: QuotaScopeRegional, Required: 60, Limit: 100, Usage: 80, Available: 20, Shortfall: 40},
+	}, report.Shortfalls())
+
+	err = report.Err()
+	assert.ErrorIs(t, err, ErrQuotaInsufficient)
+	assert.ErrorContains(t, err, "regional external_ip_count: required 3, available 1, missing 2")
+
+	scopes := map[string][]QuotaScope{}
+	for _, check := range report.Checks {
+		scopes[check.Resource] = append(scopes[check.Resource], check.Scope)
+		if check.Resource == QuotaFloatingIPCount {
+			assert.Equal(t, 9, check.Available)
+		}
+	}
+	assert.Equal(t, []QuotaScope{QuotaScopeGlobal}, scopes[QuotaFloatingIPCount])
+	assert.Equal(t, []QuotaScope{QuotaScopeRegional, QuotaScopeGlobal}, scopes[QuotaCPUCount])
+	assert.Equal(t, []QuotaScope{QuotaScopeRegional, QuotaScopeGlobal}, scopes[QuotaRAM])
+	assert.Equal(t, []QuotaScope{QuotaScopeRegional}, scopes[QuotaInstanceCount])
+}
+
+func TestPlanInstanceCapacity_OK(t *testing.T) {
+	server := testCapacityServer(t, edgecloud.CombinedQuota{
+		RegionalQuotas: []edgecloud.Quota{{"region_id": regionID, "vm_count_limit": 10, "cpu_count_limit": 100}},
+	})
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+
+	report, err := PlanInstanceCapacity(context.Background(), client, testCapacityRequest())
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.NoError(t, report.Err())
+	assert.Len(t, report.Checks, 2)
+}
+
+func TestPlanInstanceCapacity_FlavorNotFound(t *testing.T) {
+	server := testCapacityServer(t, edgecloud.CombinedQuota{})
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+
+	reqBody := testCapacityRequest()
+	reqBody.Flavor = testNewFlavorID
+
+	report, err := PlanInstanceCapacity(context.Background(), client, reqBody)
+	assert.ErrorIs(t, err, ErrFlavorNotFound)
+	assert.Nil(t, report)
+}
@@ -0,0 +1,296 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	// ImageBuilderMetadataKey marks the builder instances with the name of the image they build.
+	ImageBuilderMetadataKey = "image_builder"
+
+	imageSourceVolume = "volume"
+
+	defaultImageBuildTimeout      = time.Hour
+	defaultImageBuildPollInterval = 10 * time.Second
+)
+
+var ErrImageBuildInvalid = errors.New("invalid image build options")
+
+// ImagePublishTarget is a reseller, client or project the built image is made available to.
+type ImagePublishTarget struct {
+	EntityType edgecloud.EntityType
+	EntityID   edgecloud.EntityID
+}
+
+// ImageBuildOptions specifies the parameters to BuildImage.
+type ImageBuildOptions struct {
+	// Name of the image. Required.
+	Name string
+	// BaseImageID is the image the builder boots from. Either BaseImageID or BaseImage is required.
+	BaseImageID string
+	// BaseImage resolves the base image with FindImage if BaseImageID is empty.
+	BaseImage *ImageQuery
+	// Flavor of the builder instance. Required.
+	Flavor string
+	// Interfaces of the builder instance. Required.
+	Interfaces     []edgecloud.InstanceInterface
+	SecurityGroups []edgecloud.ID
+	KeypairName    string
+	// UserData provisions the builder and powers it off when done. It is base64 encoded by BuildImage.
+	UserData string
+	// VolumeSize is the size of the boot volume in GiB. Required.
+	VolumeSize int
+	VolumeType edgecloud.VolumeType
+
+	OSType         edgecloud.OSType
+	OSDistro       string
+	OSVersion      string
+	SSHKey         edgecloud.SSHKey
+	HWMachineType  edgecloud.HWMachineType
+	HWFirmwareType edgecloud.HWFirmwareType
+	// Metadata of the image.
+	Metadata edgecloud.Metadata
+
+	// Publish makes the image available to the resellers, clients or projects in the client region.
+	Publish []ImagePublishTarget
+	// KeepBuilder keeps the builder instance and its volumes, for debugging.
+	KeepBuilder bool
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// BuildTimeout is the maximum time the user data has to provision the builder and power it off, 1 hour
+	// by default.
+	BuildTimeout time.Duration
+	// PollInterval is the interval the builder is checked for power off at, 10 seconds by default.
+	PollInterval time.Duration
+	// Attempts limits the number of checks of the builder for power off. If nil, the builder is checked
+	// every PollInterval until BuildTimeout, since provisioning usually takes minutes.
+	Attempts *uint
+}
+
+// ImageBuildResult describes a built image.
+type ImageBuildResult struct {
+	Image             *edgecloud.Image
+	BuilderInstanceID string
+	// BuilderVolumeID is the boot volume of the builder the image was created from.
+	BuilderVolumeID string
+}
+
+// BuildImage boots a builder instance from the base image with the provisioning user data, waits for the
+// user data to power it off, creates the image from its boot volume and publishes it. The builder and its
+// volumes are deleted afterwards, also on failure, unless opts.KeepBuilder is set. The result is returned
+// with the resources created so far on error.
+func BuildImage(ctx context.Context, client *edgecloud.Client, opts *ImageBuildOptions) (result *ImageBuildResult, err error) {
+	if err = opts.validate(); err != nil {
+		return nil, err
+	}
+
+	baseImageID := opts.BaseImageID
+	if baseImageID == "" {
+		image, err := FindImage(ctx, client, opts.BaseImage)
+		if err != nil {
+			return nil, err
+		}
+		baseImageID = image.ID
+	}
+
+	builderName := opts.Name + "-builder"
+	taskResult, err := ExecuteAndExtractTaskResult(ctx, client.Instances.Create, &edgecloud.InstanceCreateRequest{
+		Names:          []string{builderName},
+		Flavor:         opts.Flavor,
+		KeypairName:    opts.KeypairName,
+		UserData:       base64.StdEncoding.EncodeToString([]byte(opts.UserData)),
+		Interfaces:     opts.Interfaces,
+		SecurityGroups: opts.SecurityGroups,
+		Metadata:       edgecloud.Metadata{ImageBuilderMetadataKey: opts.Name},
+		Volumes: []edgecloud.InstanceVolumeCreate{{
+			Source:    edgecloud.VolumeSourceImage,
+			ImageID:   baseImageID,
+			BootIndex: edgecloud.PtrTo(0),
+			Size:      opts.VolumeSize,
+			TypeName:  opts.VolumeType,
+			Name:      builderName,
+		}},
+	}, client, nonZeroTimeouts(opts.Timeout)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(taskResult.Instances) == 0 {
+		return nil, fmt.Errorf("%w: instances", ErrTaskResultHasNoResources)
+	}
+
+	result = &ImageBuildResult{BuilderInstanceID: taskResult.Instances[0]}
+	if !opts.KeepBuilder {
+		defer func() {
+			err = errors.Join(err, deleteImageBuilder(context.WithoutCancel(ctx), client, result.BuilderInstanceID, opts.Timeout))
+		}()
+	}
+
+	instance, err := waitForImageBuilder(ctx, client, result.BuilderInstanceID, opts)
+	if err != nil {
+		return result, err
+	}
+
+	volumes, err := instanceVolumesByDevice(ctx, client, instance)
+	if err != nil {
+		return result, err
+	}
+	result.BuilderVolumeID = volumes[0].ID
+
+	taskResult, err = ExecuteAndExtractTaskResult(ctx, client.Images.Create, &edgecloud.ImageCreateRequest{
+		Name:           opts.Name,
+		VolumeID:       result.BuilderVolumeID,
+		Source:         imageSourceVolume,
+		OSType:         opts.OSType,
+		OSDistro:       opts.OSDistro,
+		OSVersion:      opts.OSVersion,
+		SSHKey:         opts.SSHKey,
+		HWMachineType:  opts.HWMachineType,
+		HWFirmwareType: opts.HWFirmwareType,
+		Metadata:       opts.Metadata,
+	}, client, nonZeroTimeouts(opts.Timeout)...)
+	if err != nil {
+		return result, err
+	}
+
+	if len(taskResult.Images) == 0 {
+		return result, fmt.Errorf("%w: images", ErrTaskResultHasNoResources)
+	}
+
+	if result.Image, _, err = client.Images.Get(ctx, taskResult.Images[0]); err != nil {
+		return result, err
+	}
+
+	for _, target := range opts.Publish {
+		if err = PublishImage(ctx, client, result.Image.ID, target); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (opts *ImageBuildOptions) validate() error {
+	switch {
+	case opts == nil || opts.Name == "":
+		return fmt.Errorf("%w: name cannot be empty", ErrImageBuildInvalid)
+	case opts.BaseImageID == "" && opts.BaseImage == nil:
+		return fmt.Errorf("%w: base image cannot be empty", ErrImageBuildInvalid)
+	case opts.Flavor == "":
+		return fmt.Errorf("%w: flavor cannot be empty", ErrImageBuildInvalid)
+	case len(opts.Interfaces) == 0:
+		return fmt.Errorf("%w: interfaces cannot be empty", ErrImageBuildInvalid)
+	case opts.VolumeSize <= 0:
+		return fmt.Errorf("%w: volume size must be positive", ErrImageBuildInvalid)
+	}
+
+	return nil
+}
+
+// deleteImageBuilder deletes the builder instance with all its volumes.
+func deleteImageBuilder(ctx context.Context, client *edgecloud.Client, instanceID string, timeout time.Duration) error {
+	instance, _, err := client.Instances.Get(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+
+	opts := &edgecloud.InstanceDeleteOptions{}
+	for _, volume := range instance.Volumes {
+		opts.Volumes = append(opts.Volumes, volume.ID)
+	}
+
+	task, _, err := client.Instances.Delete(ctx, instanceID, opts)
+	if err != nil {
+		return err
+	}
+
+	return WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(timeout)...)
+}
+
+// PublishImage adds the image to the list of images available to the target in the client region, keeping the
+// images already in the list. A target without a list in the region gets a list with just the image, and
+// nothing is changed if the list of the target does not restrict the images.
+func PublishImage(ctx context.Context, client *edgecloud.Client, imageID string, target ImagePublishTarget) error {
+	current, _, err := client.ResellerImageV2.List(ctx, target.EntityType, target.EntityID)
+	if err != nil {
+		return err
+	}
+
+	imageIDs := edgecloud.ImageIDs{}
+	for _, limit := range current.Results {
+		if limit.RegionID != client.Region {
+			continue
+		}
+		if limit.ImageIDs == nil {
+			return nil
+		}
+		imageIDs = append(imageIDs, *limit.ImageIDs...)
+	}
+
+	for _, id := range imageIDs {
+		if id == imageID {
+			return nil
+		}
+	}
+	imageIDs = append(imageIDs, imageID)
+
+	_, _, err = client.ResellerImageV2.Update(ctx, &edgecloud.ResellerImageV2UpdateRequest{
+		ImageIDs:   &imageIDs,
+		RegionID:   client.Region,
+		EntityID:   target.EntityID,
+		EntityType: target.EntityType,
+	})
+
+	return err
+}
+
+// waitForImageBuilder checks the builder every opts.PollInterval until it is powered off, for up to
+// opts.BuildTimeout or opts.Attempts checks. Errors getting the builder are retried until then.
+func waitForImageBuilder(ctx context.Context, client *edgecloud.Client, instanceID string, opts *ImageBuildOptions) (*edgecloud.Instance, error) {
+	timeout := opts.BuildTimeout
+	if timeout <= 0 {
+		timeout = defaultImageBuildTimeout
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = defaultImageBuildPollInterval
+	}
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for check := uint(1); ; check++ {
+		instance, _, err := client.Instances.Get(ctx, instanceID)
+		switch {
+		case err != nil:
+			lastErr = err
+		case instance.Status == edgecloud.InstanceStatusShutoff:
+			return instance, nil
+		case instance.Status.IsError() || instance.VMState.IsError():
+			return nil, fmt.Errorf("%w: builder %s", ErrInstanceErrorState, instanceID)
+		default:
+			lastErr = fmt.Errorf("%w: builder %s is %s", ErrInstanceNotInTargetState, instanceID, instance.Status)
+		}
+
+		if opts.Attempts != nil && check >= *opts.Attempts {
+			return nil, lastErr
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, fmt.Errorf("the builder was not powered off within %s: %w", timeout, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
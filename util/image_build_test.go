@@ -0,0 +1,160 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const testImageID = "3c2b1a0f-9e8d-4c7b-a6f5-e4d3c2b1a0f9"
+
+type fakeImageBuildAPI struct {
+	mu          sync.Mutex
+	tasks       map[string]map[string]interface{}
+	instanceGet int
+	deleted     []string
+	image       edgecloud.ImageCreateRequest
+	published   edgecloud.ResellerImageV2UpdateRequest
+}
+
+func (f *fakeImageBuildAPI) register(t *testing.T, mux *http.ServeMux) {
+	t.Helper()
+	instances := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	instancesV2 := path.Join("/v2/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	images := path.Join("/v1/images", strconv.Itoa(projectID), strconv.Itoa(regionID))
+
+	mux.HandleFunc(instancesV2, func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.InstanceCreateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		userData, err := base64.StdEncoding.DecodeString(req.UserData)
+		require.NoError(t, err)
+		assert.Equal(t, "#cloud-config\npower_state: {mode: poweroff}\n", string(userData))
+		assert.Equal(t, testResourceID3, req.Volumes[0].ImageID)
+		assert.Equal(t, "golden", req.Metadata[ImageBuilderMetadataKey])
+		f.writeTask(w, map[string]interface{}{"instances": []string{testResourceID}})
+	})
+	mux.HandleFunc(path.Join(instances, testResourceID), func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Method == http.MethodDelete {
+			f.deleted = append(f.deleted, r.URL.Query().Get("volumes"))
+			f.writeTask(w, nil)
+			return
+		}
+		f.instanceGet++
+		status := edgecloud.InstanceStatusActive
+		if f.instanceGet > 1 {
+			status = edgecloud.InstanceStatusShutoff
+		}
+		writeTestJSON(t, w, edgecloud.Instance{ID: testResourceID, Status: status, Volumes: []edgecloud.InstanceVolume{{ID: testVolumeID}}})
+	})
+	mux.HandleFunc(path.Join("/v1/volumes", strconv.Itoa(projectID), strconv.Itoa(regionID), testVolumeID), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Volume{ID: testVolumeID, Attachments: []edgecloud.Attachment{{ServerID: testResourceID, Device: "/dev/vda"}}})
+	})
+	mux.HandleFunc(images, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&f.image))
+		f.writeTask(w, map[string]interface{}{"images": []string{testImageID}})
+	})
+	mux.HandleFunc(path.Join(images, testImageID), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Image{ID: testImageID, Name: f.image.Name, OSDistro: f.image.OSDistro})
+	})
+	mux.HandleFunc("/v2/reseller_image/project/42", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"count":2,"results":[{"region_id":%d,"image_ids":["%s"]},{"region_id":1,"image_ids":[]}]}`, regionID, testResourceID3)
+	})
+	mux.HandleFunc("/v2/reseller_image", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&f.published))
+		writeTestJSON(t, w, f.published)
+	})
+	mux.HandleFunc("/v1/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		writeTestJSON(t, w, edgecloud.Task{
+			ID:               path.Base(r.URL.Path),
+			State:            edgecloud.TaskStateFinished,
+			CreatedResources: f.tasks[path.Base(r.URL.Path)],
+		})
+	})
+}
+
+func (f *fakeImageBuildAPI) writeTask(w http.ResponseWriter, resources map[string]interface{}) {
+	taskID := uuid.NewString()
+	f.tasks[taskID] = resources
+	_, _ = fmt.Fprintf(w, `{"tasks":["%s"]}`, taskID)
+}
+
+func TestBuildImage(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fake := &fakeImageBuildAPI{tasks: make(map[string]map[string]interface{})}
+	fake.register(t, mux)
+
+	result, err := BuildImage(context.Background(), newTestClient(server.URL), &ImageBuildOptions{
+		Name:           "golden",
+		BaseImageID:    testResourceID3,
+		Flavor:         testFlavorID,
+		Interfaces:     []edgecloud.InstanceInterface{{Type: edgecloud.InterfaceTypeExternal}},
+		UserData:       "#cloud-config\npower_state: {mode: poweroff}\n",
+		VolumeSize:     10,
+		OSType:         edgecloud.OSTypeLinux,
+		OSDistro:       "ubuntu",
+		OSVersion:      "22.04",
+		HWFirmwareType: edgecloud.HWFirmwareTypeUEFI,
+		Metadata:       edgecloud.Metadata{"build": "42"},
+		Publish:        []ImagePublishTarget{{EntityType: edgecloud.ProjectType, EntityID: 42}},
+		PollInterval:   time.Millisecond,
+		Attempts:       &attempts,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, testImageID, result.Image.ID)
+	assert.Equal(t, testResourceID, result.BuilderInstanceID)
+	assert.Equal(t, testVolumeID, result.BuilderVolumeID)
+
+	assert.Equal(t, testVolumeID, fake.image.VolumeID)
+	assert.Equal(t, "volume", fake.image.Source)
+	assert.Equal(t, "22.04", fake.image.OSVersion)
+	assert.Equal(t, edgecloud.HWFirmwareTypeUEFI, fake.image.HWFirmwareType)
+
+	assert.Equal(t, &edgecloud.ImageIDs{testResourceID3, testImageID}, fake.published.ImageIDs)
+	assert.Equal(t, regionID, fake.published.RegionID)
+	assert.Equal(t, []string{testVolumeID}, fake.deleted)
+}
+
+func TestWaitForImageBuilder_Timeout(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc(path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Instance{ID: testResourceID, Status: edgecloud.InstanceStatusActive})
+	})
+
+	_, err := waitForImageBuilder(context.Background(), newTestClient(server.URL), testResourceID, &ImageBuildOptions{
+		BuildTimeout: 50 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	})
+	require.ErrorIs(t, err, ErrInstanceNotInTargetState)
+	assert.ErrorContains(t, err, "not powered off within 50ms")
+}
+
+func TestBuildImage_Invalid(t *testing.T) {
+	_, err := BuildImage(context.Background(), nil, &ImageBuildOptions{Name: "golden"})
+	require.ErrorIs(t, err, ErrImageBuildInvalid)
+}
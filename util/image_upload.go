@@ -0,0 +1,248 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// ImageUploadChunkSize is the default size of the chunks an image is streamed in.
+const ImageUploadChunkSize = 8 << 20
+
+var (
+	ErrImageUploadInvalid      = errors.New("invalid image upload options")
+	ErrImageChecksumMismatch   = errors.New("the image checksum does not match")
+	ErrImageStagingFailed      = errors.New("failed to stage the image")
+	ErrImageStagingURLRequired = errors.New("the image staging returned no URL")
+)
+
+// ImageStaging stores an image stream temporarily at a URL the platform can download it from. The images API
+// only imports images from URLs, so local images are staged first.
+type ImageStaging interface {
+	// Stage stores the stream under the name and returns the URL to import the image from. The size is -1
+	// if unknown.
+	Stage(ctx context.Context, name string, r io.Reader, size int64) (string, error)
+	// Cleanup deletes the staged image once it is imported or the upload failed.
+	Cleanup(ctx context.Context, name string) error
+}
+
+// PresignedURLStaging stages images with pre-signed URLs of an object storage, such as S3.
+type PresignedURLStaging struct {
+	// Presign returns the URL to PUT the object to and the URL the platform GETs it from. Required.
+	Presign func(ctx context.Context, name string) (putURL, getURL string, err error)
+	// Delete deletes the object. The object is left to expire if nil.
+	Delete func(ctx context.Context, name string) error
+	// HTTPClient uploads the object, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+var _ ImageStaging = &PresignedURLStaging{}
+
+// Stage uploads the stream with a single PUT request. Pre-signed URLs reject chunked uploads, so a stream of
+// unknown size is spooled to a temporary file first to learn its Content-Length.
+func (s *PresignedURLStaging) Stage(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	if s.Presign == nil {
+		return "", fmt.Errorf("%w: presign cannot be nil", ErrImageUploadInvalid)
+	}
+
+	if size < 0 {
+		spool, err := os.CreateTemp("", "image-staging-*")
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}()
+
+		if size, err = io.Copy(spool, r); err != nil {
+			return "", err
+		}
+		if _, err = spool.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		r = spool
+	}
+
+	putURL, getURL, err := s.Presign(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, putURL, io.NopCloser(r))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("%w: PUT %s: %s", ErrImageStagingFailed, name, resp.Status)
+	}
+
+	return getURL, nil
+}
+
+// Cleanup deletes the object if Delete is set.
+func (s *PresignedURLStaging) Cleanup(ctx context.Context, name string) error {
+	if s.Delete == nil {
+		return nil
+	}
+
+	return s.Delete(ctx, name)
+}
+
+// ImageStreamUploadOptions specifies the parameters to UploadImageFromReader and UploadImageFromFile.
+type ImageStreamUploadOptions struct {
+	// Request describes the image. Its URL is set to the staged image. Required.
+	Request *edgecloud.ImageUploadRequest
+	// Staging stores the image until the platform imports it. Required.
+	Staging ImageStaging
+	// Size is the size of the image in bytes, -1 or 0 if unknown. It is set by UploadImageFromFile.
+	Size int64
+	// ChunkSize is the size of the chunks the image is read in, ImageUploadChunkSize by default.
+	ChunkSize int
+	// Progress is called after every chunk with the bytes uploaded so far and the size, if known.
+	Progress func(uploaded, total int64)
+	// SHA256 is the expected hex encoded SHA-256 checksum of the image. The image is not imported on mismatch.
+	SHA256 string
+	// Timeout is the maximum time to wait for the import task.
+	Timeout time.Duration
+}
+
+// UploadImageFromReader streams the image to the staging, verifies its checksum, imports it with
+// ImagesService.Upload and waits for the import task to finish before the staged copy is cleaned up.
+// It returns the task of the import.
+func UploadImageFromReader(ctx context.Context, client *edgecloud.Client, r io.Reader, opts *ImageStreamUploadOptions) (task *edgecloud.TaskResponse, err error) {
+	if opts == nil || opts.Request == nil || opts.Staging == nil {
+		return nil, fmt.Errorf("%w: request and staging cannot be nil", ErrImageUploadInvalid)
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = ImageUploadChunkSize
+	}
+
+	size := opts.Size
+	if size <= 0 {
+		size = -1
+	}
+
+	name := fmt.Sprintf("%s-%d", opts.Request.Name, time.Now().UnixNano())
+	defer func() {
+		err = errors.Join(err, opts.Staging.Cleanup(context.WithoutCancel(ctx), name))
+	}()
+
+	stream := &imageUploadReader{r: r, hash: sha256.New(), chunk: chunkSize, total: size, progress: opts.Progress}
+	url, err := opts.Staging.Stage(ctx, name, stream, size)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, ErrImageStagingURLRequired
+	}
+
+	if size > 0 && stream.read != size {
+		return nil, fmt.Errorf("%w: staged %d of %d bytes", ErrImageStagingFailed, stream.read, size)
+	}
+
+	if sum := hex.EncodeToString(stream.hash.Sum(nil)); opts.SHA256 != "" && !strings.EqualFold(sum, opts.SHA256) {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrImageChecksumMismatch, opts.SHA256, sum)
+	}
+
+	req := *opts.Request
+	req.URL = url
+
+	task, _, err = client.Images.Upload(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(task.Tasks) == 0 {
+		return nil, fmt.Errorf("%w: image %s", ErrTaskResultHasNoResources, req.Name)
+	}
+
+	if err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// UploadImageFromFile uploads the image file with UploadImageFromReader. The image is named after the file
+// if opts.Request has no name.
+func UploadImageFromFile(ctx context.Context, client *edgecloud.Client, path string, opts *ImageStreamUploadOptions) (*edgecloud.TaskResponse, error) {
+	if opts == nil || opts.Request == nil {
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrImageUploadInvalid)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	fileOpts := *opts
+	fileOpts.Size = info.Size()
+	if opts.Request.Name == "" {
+		req := *opts.Request
+		req.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		fileOpts.Request = &req
+	}
+
+	return UploadImageFromReader(ctx, client, f, &fileOpts)
+}
+
+// imageUploadReader hashes the image and reports the progress while it is read in chunks.
+type imageUploadReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	chunk    int
+	read     int64
+	total    int64
+	progress func(uploaded, total int64)
+}
+
+func (u *imageUploadReader) Read(p []byte) (int, error) {
+	if len(p) > u.chunk {
+		p = p[:u.chunk]
+	}
+
+	n, err := u.r.Read(p)
+	if n > 0 {
+		u.hash.Write(p[:n])
+		u.read += int64(n)
+		if u.progress != nil {
+			u.progress(u.read, u.total)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+type fakeImageStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	deleted []string
+	uploads []edgecloud.ImageUploadRequest
+}
+
+func (f *fakeImageStorage) register(t *testing.T, mux *http.ServeMux, serverURL *string) *PresignedURLStaging {
+	t.Helper()
+	mux.HandleFunc("/staging/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Empty(t, r.TransferEncoding, "pre-signed URLs reject chunked uploads")
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		f.mu.Lock()
+		f.objects[path.Base(r.URL.Path)] = data
+		f.mu.Unlock()
+	})
+	mux.HandleFunc(path.Join("/v1/downloadimage", strconv.Itoa(projectID), strconv.Itoa(regionID)), func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.ImageUploadRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		f.mu.Lock()
+		f.uploads = append(f.uploads, req)
+		f.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `{"tasks":["%s"]}`, testTaskID)
+	})
+	mux.HandleFunc(path.Join("/v1/tasks", testTaskID), func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"id":"%s","state":"%s"}`, testTaskID, edgecloud.TaskStateFinished)
+	})
+
+	return &PresignedURLStaging{
+		Presign: func(_ context.Context, name string) (string, string, error) {
+			return *serverURL + "/staging/" + name, "https://storage.example.com/" + name, nil
+		},
+		Delete: func(_ context.Context, name string) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.deleted = append(f.deleted, name)
+			return nil
+		},
+	}
+}
+
+func TestUploadImageFromReader(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fake := &fakeImageStorage{objects: make(map[string][]byte)}
+	staging := fake.register(t, mux, &server.URL)
+	client := newTestClient(server.URL)
+
+	image := bytes.Repeat([]byte("qcow2"), 1000)
+	sum := sha256.Sum256(image)
+
+	var progress []int64
+	task, err := UploadImageFromReader(context.Background(), client, bytes.NewReader(image), &ImageStreamUploadOptions{
+		Request:   &edgecloud.ImageUploadRequest{Name: "built", OSType: edgecloud.OSTypeLinux},
+		Staging:   staging,
+		Size:      int64(len(image)),
+		ChunkSize: 1024,
+		Progress:  func(uploaded, _ int64) { progress = append(progress, uploaded) },
+		SHA256:    hex.EncodeToString(sum[:]),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testTaskID}, task.Tasks)
+	assert.Equal(t, []int64{1024, 2048, 3072, 4096, 5000}, progress)
+
+	require.Len(t, fake.uploads, 1)
+	require.Len(t, fake.deleted, 1)
+	name := fake.deleted[0]
+	assert.True(t, strings.HasPrefix(name, "built-"))
+	assert.Equal(t, image, fake.objects[name])
+	assert.Equal(t, "https://storage.example.com/"+name, fake.uploads[0].URL)
+	assert.Equal(t, edgecloud.OSTypeLinux, fake.uploads[0].OSType)
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		_, err := UploadImageFromReader(context.Background(), client, bytes.NewReader(image), &ImageStreamUploadOptions{
+			Request: &edgecloud.ImageUploadRequest{Name: "built"},
+			Staging: staging,
+			SHA256:  strings.Repeat("0", 64),
+		})
+		require.ErrorIs(t, err, ErrImageChecksumMismatch)
+		assert.Len(t, fake.uploads, 1)
+		assert.Len(t, fake.deleted, 2)
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		_, err := UploadImageFromReader(context.Background(), client, io.NopCloser(bytes.NewReader(image)), &ImageStreamUploadOptions{
+			Request: &edgecloud.ImageUploadRequest{Name: "streamed"},
+			Staging: staging,
+		})
+		require.NoError(t, err)
+		require.Len(t, fake.uploads, 2)
+		assert.Equal(t, image, fake.objects[path.Base(fake.uploads[1].URL)])
+	})
+
+	t.Run("file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "ubuntu.qcow2")
+		require.NoError(t, os.WriteFile(file, image, 0o600))
+
+		_, err := UploadImageFromFile(context.Background(), client, file, &ImageStreamUploadOptions{
+			Request: &edgecloud.ImageUploadRequest{},
+			Staging: staging,
+		})
+		require.NoError(t, err)
+		require.Len(t, fake.uploads, 3)
+		assert.Equal(t, "ubuntu", fake.uploads[2].Name)
+	})
+}
@@ -0,0 +1,251 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	// ImageArchitectureMetadataKey is the image metadata key holding the CPU architecture of the image.
+	ImageArchitectureMetadataKey = "architecture"
+	// ImageDefaultArchitecture is the architecture of the images without ImageArchitectureMetadataKey.
+	ImageDefaultArchitecture = "x86_64"
+
+	imageVisibilityPublic = "public"
+)
+
+var (
+	ErrImageNotFound  = errors.New("no image was found for the specified search criteria")
+	ErrImageAmbiguous = errors.New("several images match the specified search criteria")
+)
+
+// ImageQuery describes the wanted image. Empty fields match any image.
+type ImageQuery struct {
+	// Distro is matched case-insensitively against the OS distribution, for example ubuntu.
+	Distro string
+	// Version matches the OS versions starting with its components, so 22 matches 22.04 and 22.10,
+	// and 22.04 matches 22.04 and 22.04.3.
+	Version string
+	OSType  edgecloud.OSType
+	// Architecture is matched against ImageArchitectureMetadataKey, the images without it are ImageDefaultArchitecture.
+	Architecture string
+	FirmwareType edgecloud.HWFirmwareType
+	Baremetal    bool
+	// Visibility is public, private or shared.
+	Visibility string
+	// Metadata limits the images to the ones with all the metadata key-value pairs.
+	Metadata edgecloud.Metadata
+	// PreferPrivate prefers the images that are not public to the public ones.
+	PreferPrivate bool
+	// Latest picks the image with the highest version, then the newest one. FindImage fails if several
+	// images match without it.
+	Latest bool
+}
+
+// Matches reports whether the image satisfies the query.
+func (q *ImageQuery) Matches(image edgecloud.Image) bool {
+	if image.IsBaremetal != q.Baremetal {
+		return false
+	}
+
+	if q.Distro != "" && !strings.EqualFold(image.OSDistro, q.Distro) {
+		return false
+	}
+
+	if q.Version != "" && !imageVersionHasPrefix(image.OSVersion, q.Version) {
+		return false
+	}
+
+	if q.OSType != "" && image.OSType != q.OSType ||
+		q.FirmwareType != "" && image.HWFirmwareType != q.FirmwareType ||
+		q.Visibility != "" && image.Visibility != q.Visibility {
+		return false
+	}
+
+	if q.Architecture != "" {
+		architecture := image.Metadata[ImageArchitectureMetadataKey]
+		if architecture == "" {
+			architecture = ImageDefaultArchitecture
+		}
+		if !strings.EqualFold(architecture, q.Architecture) {
+			return false
+		}
+	}
+
+	for k, v := range q.Metadata {
+		if image.Metadata[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FindImage returns the image matching the query. Baremetal images are looked up in the baremetal images,
+// the others in the images of the region and then in the images of the project, the first list with
+// a match is used. See ImageQuery.Latest for the choice between several matches.
+func FindImage(ctx context.Context, client *edgecloud.Client, query *ImageQuery) (*edgecloud.Image, error) {
+	if query == nil {
+		query = &ImageQuery{}
+	}
+
+	opts := &edgecloud.ImageListOptions{Visibility: query.Visibility}
+	if len(query.Metadata) > 0 {
+		metadataKV, err := json.Marshal(query.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		opts.MetadataKV = string(metadataKV)
+	}
+
+	sources := []imageSource{imageSourceRegion, imageSourceProject}
+	if query.Baremetal {
+		sources = []imageSource{imageSourceBaremetal, imageSourceRegion}
+	}
+
+	for _, source := range sources {
+		images, err := listImages(ctx, client, source, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if image, err := SelectImage(images, query); !errors.Is(err, ErrImageNotFound) {
+			return image, err
+		}
+	}
+
+	return nil, ErrImageNotFound
+}
+
+// imageSource is an image list of the API.
+type imageSource int
+
+const (
+	imageSourceRegion imageSource = iota
+	imageSourceProject
+	imageSourceBaremetal
+)
+
+func listImages(ctx context.Context, client *edgecloud.Client, source imageSource, opts *edgecloud.ImageListOptions) ([]edgecloud.Image, error) {
+	var images []edgecloud.Image
+	var err error
+	switch source {
+	case imageSourceRegion:
+		images, _, err = client.Images.List(ctx, opts)
+	case imageSourceProject:
+		images, _, err = client.Images.ImagesProjectList(ctx)
+	case imageSourceBaremetal:
+		images, _, err = client.Images.ImagesBaremetalList(ctx, opts)
+	}
+
+	return images, err
+}
+
+// SelectImage returns the image of the list matching the query, see FindImage.
+func SelectImage(images []edgecloud.Image, query *ImageQuery) (*edgecloud.Image, error) {
+	var candidates []edgecloud.Image
+	for _, image := range images {
+		if query.Matches(image) {
+			candidates = append(candidates, image)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrImageNotFound
+	}
+
+	if query.PreferPrivate {
+		private := candidates[:0:0]
+		for _, image := range candidates {
+			if image.Visibility != imageVisibilityPublic {
+				private = append(private, image)
+			}
+		}
+		if len(private) > 0 {
+			candidates = private
+		}
+	}
+
+	if len(candidates) > 1 && !query.Latest {
+		ids := make([]string, 0, len(candidates))
+		for _, image := range candidates {
+			ids = append(ids, image.ID)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrImageAmbiguous, strings.Join(ids, ", "))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if c := compareImageVersions(candidates[i].OSVersion, candidates[j].OSVersion); c != 0 {
+			return c > 0
+		}
+
+		created, errI := parseAPITime(candidates[i].CreatedAt)
+		other, errJ := parseAPITime(candidates[j].CreatedAt)
+		if errI == nil && errJ == nil && !created.Equal(other) {
+			return created.After(other)
+		}
+
+		return candidates[i].ID < candidates[j].ID
+	})
+
+	return &candidates[0], nil
+}
+
+// imageVersionParts splits a version into its components, for example 22.04-lts into 22, 04 and lts.
+func imageVersionParts(version string) []string {
+	return strings.FieldsFunc(strings.ToLower(version), func(r rune) bool {
+		return r == '.' || r == '-' || r == '_' || r == ' '
+	})
+}
+
+func imageVersionHasPrefix(version, prefix string) bool {
+	parts, prefixParts := imageVersionParts(version), imageVersionParts(prefix)
+	if len(prefixParts) > len(parts) {
+		return false
+	}
+
+	for i, part := range prefixParts {
+		if compareImageVersionParts(parts[i], part) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareImageVersions compares the versions component by component, numerically where both components are
+// numbers. A version with more components is higher, so 22.04.3 is higher than 22.04.
+func compareImageVersions(a, b string) int {
+	partsA, partsB := imageVersionParts(a), imageVersionParts(b)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if c := compareImageVersionParts(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(partsA) - len(partsB)
+}
+
+func compareImageVersionParts(a, b string) int {
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		return numA - numB
+	case errA == nil:
+		// numbers are higher than names, so 22.04 is higher than 22.beta
+		return 1
+	case errB == nil:
+		return -1
+	default:
+		return strings.Compare(a, b)
+	}
+}
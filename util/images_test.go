@@ -0,0 +1,82 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+func TestCompareImageVersions(t *testing.T) {
+	assert.Positive(t, compareImageVersions("22.10", "22.04"))
+	assert.Positive(t, compareImageVersions("10", "9.3"))
+	assert.Positive(t, compareImageVersions("22.04.3", "22.04"))
+	assert.Positive(t, compareImageVersions("22.04", "22.beta"))
+	assert.Zero(t, compareImageVersions("22.04", "22.4"))
+
+	assert.True(t, imageVersionHasPrefix("22.04.3", "22.04"))
+	assert.True(t, imageVersionHasPrefix("22.10", "22"))
+	assert.False(t, imageVersionHasPrefix("2.2", "22"))
+	assert.False(t, imageVersionHasPrefix("22", "22.04"))
+}
+
+func TestFindImage(t *testing.T) {
+	images := []edgecloud.Image{
+		{ID: "ubuntu-2004", OSDistro: "ubuntu", OSVersion: "20.04", Visibility: "public", CreatedAt: "2024-01-01T00:00:00"},
+		{ID: "ubuntu-2204-old", OSDistro: "Ubuntu", OSVersion: "22.04", Visibility: "public", CreatedAt: "2024-01-01T00:00:00"},
+		{ID: "ubuntu-2204-new", OSDistro: "ubuntu", OSVersion: "22.04", Visibility: "public", CreatedAt: "2024-02-01T00:00:00"},
+		{ID: "ubuntu-2204-arm", OSDistro: "ubuntu", OSVersion: "22.04.1", Visibility: "public", Metadata: edgecloud.Metadata{"architecture": "aarch64"}},
+		{ID: "ubuntu-2204-private", OSDistro: "ubuntu", OSVersion: "22.04", Visibility: "private", CreatedAt: "2023-01-01T00:00:00"},
+	}
+	projectImages := []edgecloud.Image{{ID: "debian-12", OSDistro: "debian", OSVersion: "12", Visibility: "private"}}
+	baremetalImages := []edgecloud.Image{{ID: "ubuntu-bm", OSDistro: "ubuntu", OSVersion: "22.04", IsBaremetal: true}}
+
+	mux := http.NewServeMux()
+	for base, list := range map[string][]edgecloud.Image{
+		"/v1/images":        images,
+		"/v1/projectimages": projectImages,
+		"/v1/bmimages":      baremetalImages,
+	} {
+		list := list
+		mux.HandleFunc(path.Join(base, strconv.Itoa(projectID), strconv.Itoa(regionID)), func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(t, w, map[string]interface{}{"count": len(list), "results": list})
+		})
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := newTestClient(server.URL)
+
+	tests := []struct {
+		name     string
+		query    ImageQuery
+		expected string
+		err      error
+	}{
+		{name: "latest", query: ImageQuery{Distro: "ubuntu", Version: "22.04", Architecture: "x86_64", Latest: true}, expected: "ubuntu-2204-new"},
+		{name: "highest version", query: ImageQuery{Distro: "ubuntu", Version: "22", Latest: true}, expected: "ubuntu-2204-arm"},
+		{name: "prefer private", query: ImageQuery{Distro: "ubuntu", Version: "22.04", PreferPrivate: true}, expected: "ubuntu-2204-private"},
+		{name: "project fallback", query: ImageQuery{Distro: "debian"}, expected: "debian-12"},
+		{name: "baremetal", query: ImageQuery{Distro: "ubuntu", Baremetal: true}, expected: "ubuntu-bm"},
+		{name: "ambiguous", query: ImageQuery{Distro: "ubuntu", Version: "22.04"}, err: ErrImageAmbiguous},
+		{name: "not found", query: ImageQuery{Distro: "centos"}, err: ErrImageNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, err := FindImage(context.Background(), client, &tt.query)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, image.ID)
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/avast/retry-go/v4"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const defaultBatchActionConcurrency = 5
+
+var (
+	ErrInstanceActionUnknown    = errors.New("unknown instance action")
+	ErrInstanceNotInTargetState = errors.New("the instance has not reached the target state")
+	ErrInstanceNotRestarting    = errors.New("the instance has not started restarting")
+	ErrInstanceErrorState       = errors.New("the instance is in error state")
+)
+
+// InstanceActionType is a power action that can be applied to an instance.
+type InstanceActionType string
+
+const (
+	InstanceActionStart      InstanceActionType = "start"
+	InstanceActionStop       InstanceActionType = "stop"
+	InstanceActionPowercycle InstanceActionType = "powercycle"
+	InstanceActionReboot     InstanceActionType = "reboot"
+	InstanceActionSuspend    InstanceActionType = "suspend"
+	InstanceActionResume     InstanceActionType = "resume"
+)
+
+// InstanceTargetState is the Status/VMState pair an instance is expected to reach after an action.
+type InstanceTargetState struct {
+	Status  edgecloud.InstanceStatus
+	VMState edgecloud.InstanceVMState
+}
+
+type instanceActionSpec struct {
+	target InstanceTargetState
+	// idempotent actions are skipped when the instance is already in the target state.
+	idempotent bool
+	// restarts are actions that take the instance out of the target state and bring it back.
+	restarts bool
+}
+
+var instanceActionSpecs = map[InstanceActionType]instanceActionSpec{
+	InstanceActionStart:      {target: InstanceTargetState{Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive}, idempotent: true},
+	InstanceActionStop:       {target: InstanceTargetState{Status: edgecloud.InstanceStatusShutoff, VMState: edgecloud.InstanceVMStateStopped}, idempotent: true},
+	InstanceActionPowercycle: {target: InstanceTargetState{Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive}, restarts: true},
+	InstanceActionReboot:     {target: InstanceTargetState{Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive}, restarts: true},
+	InstanceActionSuspend:    {target: InstanceTargetState{Status: edgecloud.InstanceStatusSuspended, VMState: edgecloud.InstanceVMStateSuspended}, idempotent: true},
+	InstanceActionResume:     {target: InstanceTargetState{Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive}, idempotent: true},
+}
+
+func callInstanceAction(ctx context.Context, client *edgecloud.Client, instanceID string, action InstanceActionType) (*edgecloud.Instance, error) {
+	var instance *edgecloud.Instance
+	var err error
+	switch action {
+	case InstanceActionStart:
+		instance, _, err = client.Instances.InstanceStart(ctx, instanceID)
+	case InstanceActionStop:
+		instance, _, err = client.Instances.InstanceStop(ctx, instanceID)
+	case InstanceActionPowercycle:
+		instance, _, err = client.Instances.InstancePowercycle(ctx, instanceID)
+	case InstanceActionReboot:
+		instance, _, err = client.Instances.InstanceReboot(ctx, instanceID)
+	case InstanceActionSuspend:
+		instance, _, err = client.Instances.InstanceSuspend(ctx, instanceID)
+	case InstanceActionResume:
+		instance, _, err = client.Instances.InstanceResume(ctx, instanceID)
+	default:
+		err = fmt.Errorf("%w: %s", ErrInstanceActionUnknown, action)
+	}
+
+	return instance, err
+}
+
+// InstanceActionTargetState returns the state an instance is expected to reach after the action.
+func InstanceActionTargetState(action InstanceActionType) (InstanceTargetState, error) {
+	spec, ok := instanceActionSpecs[action]
+	if !ok {
+		return InstanceTargetState{}, fmt.Errorf("%w: %s", ErrInstanceActionUnknown, action)
+	}
+
+	return spec.target, nil
+}
+
+// Reached reports whether the instance is in the target state.
+func (s InstanceTargetState) Reached(instance *edgecloud.Instance) bool {
+	if instance == nil || instance.Status != s.Status {
+		return false
+	}
+
+	return s.VMState == "" || instance.VMState == s.VMState
+}
+
+// WaitForInstanceState waits until the instance reaches the target state. An instance in error state is not
+// waited for.
+func WaitForInstanceState(ctx context.Context, client *edgecloud.Client, instanceID string, target InstanceTargetState, attempts *uint) (*edgecloud.Instance, error) {
+	var instance *edgecloud.Instance
+
+	err := WithRetry(
+		func() error {
+			var err error
+			instance, _, err = client.Instances.Get(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+
+			if target.Reached(instance) {
+				return nil
+			}
+
+			if instance.Status.IsError() || instance.VMState.IsError() {
+				return retry.Unrecoverable(ErrInstanceErrorState)
+			}
+
+			return ErrInstanceNotInTargetState
+		},
+		attempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// InstanceActionAndWait applies the power action to the instance and waits for the state expected after it.
+// Start, stop, suspend and resume are not sent when the instance is already in the target state. After a reboot
+// or a powercycle the instance must be seen restarting, REBOOT status or a task in progress, before it is
+// waited for to become active again.
+func InstanceActionAndWait(ctx context.Context, client *edgecloud.Client, instanceID string, action InstanceActionType, attempts *uint) (*edgecloud.Instance, error) {
+	spec, ok := instanceActionSpecs[action]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceActionUnknown, action)
+	}
+
+	if spec.idempotent {
+		instance, _, err := client.Instances.Get(ctx, instanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.target.Reached(instance) {
+			return instance, nil
+		}
+	}
+
+	instance, err := callInstanceAction(ctx, client, instanceID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.restarts && !instanceRestarting(instance, spec.target) {
+		if err = waitForInstanceRestarting(ctx, client, instanceID, spec.target, attempts); err != nil {
+			return nil, err
+		}
+	}
+
+	return WaitForInstanceState(ctx, client, instanceID, spec.target, attempts)
+}
+
+// instanceRestarting reports whether the instance has left the target state or has a task in progress.
+func instanceRestarting(instance *edgecloud.Instance, target InstanceTargetState) bool {
+	if instance == nil || instance.Status == "" {
+		return false
+	}
+
+	return instance.TaskState != "" || !target.Reached(instance)
+}
+
+// waitForInstanceRestarting waits until a reboot or powercycle of the instance is in progress, since the instance
+// is in the target state of the action already before it.
+func waitForInstanceRestarting(ctx context.Context, client *edgecloud.Client, instanceID string, target InstanceTargetState, attempts *uint) error {
+	return WithRetry(
+		func() error {
+			instance, _, err := client.Instances.Get(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+
+			if instance.Status.IsError() || instance.VMState.IsError() {
+				return retry.Unrecoverable(ErrInstanceErrorState)
+			}
+
+			if instanceRestarting(instance, target) {
+				return nil
+			}
+
+			return ErrInstanceNotRestarting
+		},
+		attempts,
+	)
+}
+
+// InstanceActionResult is the outcome of a power action applied to a single instance in batch mode.
+type InstanceActionResult struct {
+	InstanceID string
+	Instance   *edgecloud.Instance
+	Err        error
+}
+
+// InstanceActionResults is a per-instance report of a batch power action.
+type InstanceActionResults []InstanceActionResult
+
+// Failed returns the results of the instances the action failed for.
+func (r InstanceActionResults) Failed() InstanceActionResults {
+	var failed InstanceActionResults
+	for _, res := range r {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+
+	return failed
+}
+
+// Err joins the errors of all failed instances, or returns nil if the action succeeded for every instance.
+func (r InstanceActionResults) Err() error {
+	errs := make([]error, 0, len(r))
+	for _, res := range r.Failed() {
+		errs = append(errs, fmt.Errorf("instance %s: %w", res.InstanceID, res.Err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// InstanceBatchActionOptions specifies the optional parameters to InstancesBatchAction.
+type InstanceBatchActionOptions struct {
+	// Concurrency is the maximum number of instances processed at the same time. Defaults to 5.
+	Concurrency int
+	// Attempts is the number of attempts to wait for the target state of each instance.
+	Attempts *uint
+}
+
+// InstancesBatchAction applies the power action to all instances with bounded concurrency and waits
+// for each of them to reach the expected state. Results are returned in the order of instanceIDs.
+func InstancesBatchAction(ctx context.Context, client *edgecloud.Client, instanceIDs []string, action InstanceActionType, opts *InstanceBatchActionOptions) InstanceActionResults {
+	concurrency := defaultBatchActionConcurrency
+	var attempts *uint
+	if opts != nil {
+		if opts.Concurrency > 0 {
+			concurrency = opts.Concurrency
+		}
+		attempts = opts.Attempts
+	}
+
+	results := make(InstanceActionResults, len(instanceIDs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, instanceID := range instanceIDs {
+		results[i].InstanceID = instanceID
+
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, instanceID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i].Instance, results[i].Err = InstanceActionAndWait(ctx, client, instanceID, action, attempts)
+		}(i, instanceID)
+	}
+
+	wg.Wait()
+
+	return results
+}
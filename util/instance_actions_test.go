@@ -0,0 +1,203 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const testInstanceID2 = "9b3e1b4e-2a8f-4c1a-9f7e-1d2c3b4a5f60"
+
+func TestInstanceActionAndWait(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stopped atomic.Bool
+
+	URLStop := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "stop")
+	mux.HandleFunc(URLStop, func(w http.ResponseWriter, r *http.Request) {
+		stopped.Store(true)
+		_, _ = fmt.Fprintf(w, `{"instance_id":"%s"}`, testResourceID)
+	})
+
+	URLGet := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URLGet, func(w http.ResponseWriter, r *http.Request) {
+		instance := edgecloud.Instance{ID: testResourceID, Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive}
+		if stopped.Load() {
+			instance.Status, instance.VMState = edgecloud.InstanceStatusShutoff, edgecloud.InstanceVMStateStopped
+		}
+		resp, err := json.Marshal(instance)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	})
+
+	client := newTestClient(server.URL)
+
+	instance, err := InstanceActionAndWait(context.Background(), client, testResourceID, InstanceActionStop, &attempts)
+	require.NoError(t, err)
+	assert.True(t, stopped.Load())
+	assert.Equal(t, edgecloud.InstanceStatusShutoff, instance.Status)
+}
+
+func TestInstanceActionAndWait_AlreadyInTargetState(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URLStart := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "start")
+	mux.HandleFunc(URLStart, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("start must not be called for an active instance")
+	})
+
+	expectedResp := edgecloud.Instance{ID: testResourceID, Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive}
+	URLGet := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URLGet, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := json.Marshal(expectedResp)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	})
+
+	client := newTestClient(server.URL)
+
+	instance, err := InstanceActionAndWait(context.Background(), client, testResourceID, InstanceActionStart, &attempts)
+	require.NoError(t, err)
+	assert.Equal(t, expectedResp, *instance)
+}
+
+func TestInstanceActionAndWait_ErrorState(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URLReboot := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "reboot")
+	mux.HandleFunc(URLReboot, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"instance_id":"%s"}`, testResourceID)
+	})
+
+	var gets atomic.Int32
+	expectedResp := edgecloud.Instance{ID: testResourceID, Status: edgecloud.InstanceStatusError, VMState: edgecloud.InstanceVMStateError}
+	URLGet := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URLGet, func(w http.ResponseWriter, r *http.Request) {
+		gets.Add(1)
+		resp, err := json.Marshal(expectedResp)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	})
+
+	client := newTestClient(server.URL)
+
+	instance, err := InstanceActionAndWait(context.Background(), client, testResourceID, InstanceActionReboot, &attempts)
+	assert.ErrorIs(t, err, ErrInstanceErrorState)
+	assert.Nil(t, instance)
+	// the error state is not waited out
+	assert.Equal(t, int32(1), gets.Load())
+}
+
+func TestInstanceActionAndWait_Reboot(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URLReboot := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "reboot")
+	mux.HandleFunc(URLReboot, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"instance_id":"%s"}`, testResourceID)
+	})
+
+	// the instance is still active right after the request, then reboots
+	states := []edgecloud.Instance{
+		{ID: testResourceID, Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive},
+		{ID: testResourceID, Status: edgecloud.InstanceStatusReboot, VMState: edgecloud.InstanceVMStateActive, TaskState: "rebooting"},
+		{ID: testResourceID, Status: edgecloud.InstanceStatusActive, VMState: edgecloud.InstanceVMStateActive},
+	}
+	var gets atomic.Int32
+	URLGet := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URLGet, func(w http.ResponseWriter, r *http.Request) {
+		n := int(gets.Add(1))
+		writeTestJSON(t, w, states[min(n, len(states))-1])
+	})
+
+	instance, err := InstanceActionAndWait(context.Background(), newTestClient(server.URL), testResourceID, InstanceActionReboot, &attempts)
+	require.NoError(t, err)
+	assert.Equal(t, states[2], *instance)
+	assert.Equal(t, int32(3), gets.Load())
+}
+
+func TestInstanceActionAndWait_UnknownAction(t *testing.T) {
+	client := edgecloud.NewClient(nil)
+
+	_, err := InstanceActionAndWait(context.Background(), client, testResourceID, "hibernate", &attempts)
+	assert.ErrorIs(t, err, ErrInstanceActionUnknown)
+}
+
+func TestInstancesBatchAction(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URLSuspend := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "suspend")
+	mux.HandleFunc(URLSuspend, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"instance_id":"%s"}`, testResourceID)
+	})
+
+	URLSuspend2 := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testInstanceID2, "suspend")
+	mux.HandleFunc(URLSuspend2, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	for _, id := range []string{testResourceID, testInstanceID2} {
+		instance := edgecloud.Instance{ID: id, Status: edgecloud.InstanceStatusSuspended, VMState: edgecloud.InstanceVMStateSuspended}
+		if id == testInstanceID2 {
+			instance.Status, instance.VMState = edgecloud.InstanceStatusActive, edgecloud.InstanceVMStateActive
+		}
+		URLGet := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), id)
+		var calls atomic.Int32
+		mux.HandleFunc(URLGet, func(w http.ResponseWriter, r *http.Request) {
+			resp := instance
+			// the first instance is active until the suspend request is processed
+			if calls.Add(1) == 1 {
+				resp.Status, resp.VMState = edgecloud.InstanceStatusActive, edgecloud.InstanceVMStateActive
+			}
+			body, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatalf("failed to marshal JSON: %v", err)
+			}
+			_, _ = fmt.Fprint(w, string(body))
+		})
+	}
+
+	client := newTestClient(server.URL)
+
+	opts := &InstanceBatchActionOptions{Concurrency: 1, Attempts: &attempts}
+	results := InstancesBatchAction(context.Background(), client, []string{testResourceID, testInstanceID2}, InstanceActionSuspend, opts)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, testResourceID, results[0].InstanceID)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, edgecloud.InstanceStatusSuspended, results[0].Instance.Status)
+
+	assert.Equal(t, testInstanceID2, results[1].InstanceID)
+	assert.Error(t, results[1].Err)
+
+	failed := results.Failed()
+	require.Len(t, failed, 1)
+	assert.Equal(t, testInstanceID2, failed[0].InstanceID)
+	assert.ErrorContains(t, results.Err(), testInstanceID2)
+}
@@ -0,0 +1,262 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var (
+	ErrInstanceHasNoVolumes      = errors.New("the instance has no volumes")
+	ErrInstanceHasNoInterfaces   = errors.New("the instance has no network interfaces")
+	ErrInstanceBootVolumeUnknown = errors.New("the boot volume of the instance is not found")
+)
+
+// instanceBootDevices are the devices the boot volume of an instance is attached as.
+var instanceBootDevices = map[string]bool{
+	"/dev/vda":  true,
+	"/dev/sda":  true,
+	"/dev/xvda": true,
+	"/dev/hda":  true,
+}
+
+// InstanceCloneOptions specifies the parameters to CloneInstance.
+type InstanceCloneOptions struct {
+	// Name of the new instance. Required.
+	Name string
+	// Metadata overrides or adds metadata keys of the new instance.
+	Metadata edgecloud.Metadata
+	// RemoveMetadataKeys are the metadata keys of the source instance not to copy.
+	RemoveMetadataKeys []string
+	// Flavor overrides the flavor of the source instance.
+	Flavor string
+	// NewFloatingIPs creates a new floating IP for every interface that has one on the source instance.
+	NewFloatingIPs bool
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for the snapshots to become available.
+	Attempts *uint
+}
+
+// InstanceCloneResult describes a cloned instance.
+type InstanceCloneResult struct {
+	Instance *edgecloud.Instance
+	// SnapshotIDs maps source volume IDs to the snapshots the new volumes were created from.
+	SnapshotIDs map[string]string
+	Request     *edgecloud.InstanceCreateRequest
+}
+
+// CloneInstance creates a copy of a live instance. The boot and data volumes of the instance are snapshotted,
+// and a new instance is created from the snapshots with the same flavor, interfaces on the same subnets,
+// security groups, keypair, metadata and server group. The snapshots are kept after the instance is created.
+func CloneInstance(ctx context.Context, client *edgecloud.Client, instanceID string, opts *InstanceCloneOptions) (*InstanceCloneResult, error) {
+	if opts == nil || opts.Name == "" {
+		return nil, edgecloud.NewArgError("opts.Name", "cannot be empty")
+	}
+
+	instance, _, err := client.Instances.Get(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := instanceVolumesByDevice(ctx, client, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces, err := instanceCloneInterfaces(ctx, client, instanceID, opts.NewFloatingIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	serverGroupID := ""
+	serverGroup, err := ServerGroupGetByInstance(ctx, client, instanceID)
+	switch {
+	case err == nil:
+		serverGroupID = serverGroup.ID
+	case !errors.Is(err, ErrServerGroupNotFound):
+		return nil, err
+	}
+
+	result := &InstanceCloneResult{SnapshotIDs: make(map[string]string, len(volumes))}
+	volumesCreate := make([]edgecloud.InstanceVolumeCreate, 0, len(volumes))
+	for i, volume := range volumes {
+		var bootIndex *int
+		if i == 0 {
+			bootIndex = edgecloud.PtrTo(0)
+		}
+
+		snapshotName := fmt.Sprintf("%s-%s", opts.Name, volume.Name)
+		snapshotID, err := CreateSnapshotAndWait(ctx, client, &edgecloud.SnapshotCreateRequest{
+			VolumeID:    volume.ID,
+			Name:        snapshotName,
+			Description: fmt.Sprintf("clone of instance %s", instanceID),
+		}, opts.Attempts, nonZeroTimeouts(opts.Timeout)...)
+		if err != nil {
+			return result, err
+		}
+		result.SnapshotIDs[volume.ID] = snapshotID
+
+		volumesCreate = append(volumesCreate, edgecloud.InstanceVolumeCreate{
+			Source:     edgecloud.VolumeSourceSnapshot,
+			SnapshotID: snapshotID,
+			BootIndex:  bootIndex,
+			TypeName:   volume.VolumeType,
+			Name:       snapshotName,
+			Metadata:   writableMetadata(volume.Metadata, volume.MetadataDetailed),
+		})
+	}
+
+	flavor := opts.Flavor
+	if flavor == "" && instance.Flavor != nil {
+		flavor = instance.Flavor.FlavorID
+	}
+
+	metadata := writableMetadata(instance.Metadata, instance.MetadataDetailed)
+	for _, key := range opts.RemoveMetadataKeys {
+		delete(metadata, key)
+	}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+
+	result.Request = &edgecloud.InstanceCreateRequest{
+		Names:         []string{opts.Name},
+		Flavor:        flavor,
+		KeypairName:   instance.KeypairName,
+		Interfaces:    interfaces,
+		Metadata:      metadata,
+		ServerGroupID: serverGroupID,
+		Volumes:       volumesCreate,
+	}
+
+	taskResult, err := ExecuteAndExtractTaskResult(ctx, client.Instances.Create, result.Request, client, nonZeroTimeouts(opts.Timeout)...)
+	if err != nil {
+		return result, err
+	}
+
+	if len(taskResult.Instances) == 0 {
+		return result, fmt.Errorf("%w: instances", ErrTaskResultHasNoResources)
+	}
+
+	result.Instance, err = WaitForInstanceState(ctx, client, taskResult.Instances[0], InstanceTargetState{Status: edgecloud.InstanceStatusActive}, opts.Attempts)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// instanceVolumesByDevice returns the volumes of the instance with the boot volume first
+// and the data volumes ordered by the device they are attached as. The boot volume is the volume attached
+// as a boot device, see instanceBootDevices, or the only volume of the instance.
+func instanceVolumesByDevice(ctx context.Context, client *edgecloud.Client, instance *edgecloud.Instance) ([]edgecloud.Volume, error) {
+	if len(instance.Volumes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceHasNoVolumes, instance.ID)
+	}
+
+	volumes := make([]edgecloud.Volume, 0, len(instance.Volumes))
+	for _, v := range instance.Volumes {
+		volume, _, err := client.Volumes.Get(ctx, v.ID)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+	}
+
+	device := func(volume edgecloud.Volume) string {
+		if attachment := volumeAttachment(&volume, instance.ID); attachment != nil {
+			return attachment.Device
+		}
+		return volume.Device
+	}
+
+	sort.SliceStable(volumes, func(i, j int) bool {
+		if boot := instanceBootDevices[device(volumes[i])]; boot != instanceBootDevices[device(volumes[j])] {
+			return boot
+		}
+		return device(volumes[i]) < device(volumes[j])
+	})
+
+	if len(volumes) > 1 && !instanceBootDevices[device(volumes[0])] {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceBootVolumeUnknown, instance.ID)
+	}
+
+	return volumes, nil
+}
+
+// instanceCloneInterfaces builds interfaces on the same networks and subnets with the same security groups
+// as the interfaces of the instance.
+func instanceCloneInterfaces(ctx context.Context, client *edgecloud.Client, instanceID string, newFloatingIPs bool) ([]edgecloud.InstanceInterface, error) {
+	ifaces, _, err := client.Instances.InterfaceList(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceHasNoInterfaces, instanceID)
+	}
+
+	ports, _, err := client.Instances.PortsList(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	securityGroupsByPort := make(map[string][]edgecloud.ID, len(ports))
+	for _, port := range ports {
+		for _, sg := range port.SecurityGroups {
+			securityGroupsByPort[port.ID] = append(securityGroupsByPort[port.ID], edgecloud.ID{ID: sg.ID})
+		}
+	}
+
+	interfaces := make([]edgecloud.InstanceInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		newIface := edgecloud.InstanceInterface{SecurityGroups: securityGroupsByPort[iface.PortID]}
+		if newIface.SecurityGroups == nil {
+			newIface.SecurityGroups = []edgecloud.ID{}
+		}
+
+		switch {
+		case iface.NetworkDetails.External:
+			newIface.Type = edgecloud.InterfaceTypeExternal
+		case len(iface.IPAssignments) > 0:
+			newIface.Type = edgecloud.InterfaceTypeSubnet
+			newIface.NetworkID = iface.NetworkID
+			newIface.SubnetID = iface.IPAssignments[0].SubnetID
+		default:
+			newIface.Type = edgecloud.InterfaceTypeAnySubnet
+			newIface.NetworkID = iface.NetworkID
+		}
+
+		if newFloatingIPs && len(iface.FloatingIPDetails) > 0 {
+			newIface.FloatingIP = &edgecloud.InterfaceFloatingIP{Source: edgecloud.NewFloatingIP}
+		}
+
+		interfaces = append(interfaces, newIface)
+	}
+
+	return interfaces, nil
+}
+
+// writableMetadata returns a copy of the metadata without the read-only keys set by the platform.
+func writableMetadata(metadata edgecloud.Metadata, detailed []edgecloud.MetadataDetailed) edgecloud.Metadata {
+	readOnly := make(map[string]bool, len(detailed))
+	for _, item := range detailed {
+		if item.ReadOnly {
+			readOnly[item.Key] = true
+		}
+	}
+
+	result := make(edgecloud.Metadata, len(metadata))
+	for k, v := range metadata {
+		if !readOnly[k] {
+			result[k] = v
+		}
+	}
+
+	return result
+}
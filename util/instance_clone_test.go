@@ -0,0 +1,190 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	testVolumeID     = "5d4c3b2a-1f0e-4d9c-8b7a-6f5e4d3c2b1a"
+	testSnapshotID   = "7e6d5c4b-3a2f-4e1d-9c8b-7a6f5e4d3c2b"
+	testSnapshotID2  = "8f7e6d5c-4b3a-4f2e-8d9c-7b6a5f4e3d2c"
+	testCloneTaskID  = "4d9c8b7a-6f5e-4d3c-2b1a-0f9e8d7c6b5a"
+	testSubnetID     = "6a5f4e3d-2c1b-4a0f-9e8d-7c6b5a4f3e2d"
+	testNetworkID    = "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+	testSecurityGrID = "0f1e2d3c-4b5a-4968-8776-655443322110"
+	testTaskID2      = "9a8b7c6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d"
+)
+
+func TestCloneInstance(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	source := edgecloud.Instance{
+		ID:          testResourceID,
+		Status:      edgecloud.InstanceStatusActive,
+		Flavor:      &edgecloud.Flavor{FlavorID: testFlavorID},
+		KeypairName: "deploy",
+		Metadata:    edgecloud.Metadata{"role": "web", "env": "prod", "image_id": "read-only"},
+		MetadataDetailed: []edgecloud.MetadataDetailed{
+			{Key: "image_id", Value: "read-only", ReadOnly: true},
+		},
+		Volumes: []edgecloud.InstanceVolume{{ID: testVolumeID}, {ID: testResourceID3}},
+	}
+	clone := edgecloud.Instance{ID: testInstanceID2, Status: edgecloud.InstanceStatusActive}
+
+	for _, instance := range []edgecloud.Instance{source, clone} {
+		instance := instance
+		URL := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), instance.ID)
+		mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(t, w, instance)
+		})
+	}
+
+	volumes := []edgecloud.Volume{
+		// the data volume was created from an image, so it is bootable too
+		{ID: testVolumeID, Name: "data", VolumeType: "standard", Bootable: true, Attachments: []edgecloud.Attachment{{ServerID: testResourceID, Device: "/dev/vdb"}}},
+		{ID: testResourceID3, Name: "boot", VolumeType: "ssd_hiiops", Attachments: []edgecloud.Attachment{{ServerID: testResourceID, Device: "/dev/vda"}}},
+	}
+	for _, volume := range volumes {
+		volume := volume
+		URL := path.Join("/v1/volumes", strconv.Itoa(projectID), strconv.Itoa(regionID), volume.ID)
+		mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(t, w, volume)
+		})
+	}
+
+	URLInterfaces := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "interfaces")
+	mux.HandleFunc(URLInterfaces, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, map[string]interface{}{"results": []edgecloud.InstancePortInterface{
+			{
+				PortID:            testResourceID2,
+				NetworkID:         testNetworkID,
+				IPAssignments:     []edgecloud.PortIP{{IPAddress: net.ParseIP("10.0.0.5"), SubnetID: testSubnetID}},
+				FloatingIPDetails: []edgecloud.FloatingIP{{FloatingIPAddress: "203.0.113.10"}},
+			},
+		}})
+	})
+
+	URLPorts := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "ports")
+	mux.HandleFunc(URLPorts, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, map[string]interface{}{"results": []edgecloud.InstancePort{
+			{ID: testResourceID2, SecurityGroups: []edgecloud.IDName{{ID: testSecurityGrID, Name: "default"}}},
+		}})
+	})
+
+	URLServerGroups := path.Join("/v1/servergroups", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URLServerGroups, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, map[string]interface{}{"results": []edgecloud.ServerGroup{
+			{ID: testResourceID2, Instances: []edgecloud.ServerGroupInstance{{InstanceID: testResourceID}}},
+		}})
+	})
+
+	var snapshots atomic.Int32
+	snapshotTasks := []string{testTaskID, testTaskID2}
+	URLSnapshots := path.Join("/v1/snapshots", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URLSnapshots, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"tasks":["%s"]}`, snapshotTasks[snapshots.Add(1)-1])
+	})
+
+	for _, id := range []string{testSnapshotID, testSnapshotID2} {
+		id := id
+		URL := path.Join("/v1/snapshots", strconv.Itoa(projectID), strconv.Itoa(regionID), id)
+		mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(t, w, edgecloud.Snapshot{ID: id, Status: edgecloud.SnapshotStatusAvailable})
+		})
+	}
+
+	var createReq edgecloud.InstanceCreateRequest
+	URLCreate := path.Join("/v2/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URLCreate, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		_, _ = fmt.Fprintf(w, `{"tasks":["%s"]}`, testCloneTaskID)
+	})
+
+	tasks := map[string]map[string]interface{}{
+		testTaskID:      {"snapshots": []string{testSnapshotID}},
+		testTaskID2:     {"snapshots": []string{testSnapshotID2}},
+		testCloneTaskID: {"instances": []string{testInstanceID2}},
+	}
+	for id, resources := range tasks {
+		id, resources := id, resources
+		mux.HandleFunc(path.Join("/v1/tasks", id), func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(t, w, edgecloud.Task{ID: id, State: edgecloud.TaskStateFinished, CreatedResources: resources})
+		})
+	}
+
+	client := newTestClient(server.URL)
+
+	result, err := CloneInstance(context.Background(), client, testResourceID, &InstanceCloneOptions{
+		Name:               "web-2",
+		Metadata:           edgecloud.Metadata{"role": "web-clone"},
+		RemoveMetadataKeys: []string{"env"},
+		NewFloatingIPs:     true,
+		Attempts:           &attempts,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testInstanceID2, result.Instance.ID)
+	assert.Equal(t, map[string]string{testResourceID3: testSnapshotID, testVolumeID: testSnapshotID2}, result.SnapshotIDs)
+
+	assert.Equal(t, []string{"web-2"}, createReq.Names)
+	assert.Equal(t, testFlavorID, createReq.Flavor)
+	assert.Equal(t, "deploy", createReq.KeypairName)
+	assert.Equal(t, testResourceID2, createReq.ServerGroupID)
+	assert.Equal(t, edgecloud.Metadata{"role": "web-clone"}, createReq.Metadata)
+
+	require.Len(t, createReq.Volumes, 2)
+	assert.Equal(t, testSnapshotID, createReq.Volumes[0].SnapshotID)
+	assert.Equal(t, 0, *createReq.Volumes[0].BootIndex)
+	assert.Equal(t, "ssd_hiiops", string(createReq.Volumes[0].TypeName))
+	assert.Equal(t, testSnapshotID2, createReq.Volumes[1].SnapshotID)
+	assert.Nil(t, createReq.Volumes[1].BootIndex)
+
+	require.Len(t, createReq.Interfaces, 1)
+	assert.Equal(t, edgecloud.InterfaceTypeSubnet, createReq.Interfaces[0].Type)
+	assert.Equal(t, testSubnetID, createReq.Interfaces[0].SubnetID)
+	assert.Equal(t, []edgecloud.ID{{ID: testSecurityGrID}}, createReq.Interfaces[0].SecurityGroups)
+	require.NotNil(t, createReq.Interfaces[0].FloatingIP)
+	assert.Equal(t, edgecloud.NewFloatingIP, createReq.Interfaces[0].FloatingIP.Source)
+}
+
+func TestCloneInstance_EmptyName(t *testing.T) {
+	client := edgecloud.NewClient(nil)
+
+	result, err := CloneInstance(context.Background(), client, testResourceID, &InstanceCloneOptions{})
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
+
+func TestCloneInstance_NoVolumes(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URL := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Instance{ID: testResourceID})
+	})
+
+	client := newTestClient(server.URL)
+
+	result, err := CloneInstance(context.Background(), client, testResourceID, &InstanceCloneOptions{Name: "web-2"})
+	assert.ErrorIs(t, err, ErrInstanceHasNoVolumes)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,248 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// Metadata keys used to discover the members of an instance group.
+const (
+	InstanceGroupMetadataKey         = "instance_group"
+	InstanceGroupTemplateMetadataKey = "instance_group_template"
+)
+
+var (
+	ErrInstanceGroupInvalid   = errors.New("invalid instance group")
+	ErrInstanceGroupUnhealthy = errors.New("instance group member failed the health check")
+)
+
+// InstanceHealthCheck reports whether an active instance is ready to serve.
+type InstanceHealthCheck func(ctx context.Context, instance *edgecloud.Instance) error
+
+// InstanceGroup is a set of identical instances created from the same template. The members of the group are
+// discovered by the group name in the instance metadata, so the group keeps no local state.
+type InstanceGroup struct {
+	// Name of the group. It is stored in the metadata of every member.
+	Name string
+	// DesiredCount is the number of instances the group should have.
+	DesiredCount int
+	// Template is the request to create the members. Names are ignored, the first of NameTemplates is used
+	// for every member, "<name>-{ip_octets}" by default.
+	Template *edgecloud.InstanceCreateRequest
+	// MaxSurge is the number of instances that can be created above DesiredCount during a rolling replacement.
+	MaxSurge int
+	// MaxUnavailable is the number of instances that can be missing from DesiredCount during a rolling replacement.
+	MaxUnavailable int
+	// HealthCheck gates a rolling replacement. New instances must be active and pass the check
+	// before old instances are deleted.
+	HealthCheck InstanceHealthCheck
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for a new instance to become healthy.
+	Attempts *uint
+}
+
+// InstanceGroupReconcileResult describes the changes made by Reconcile.
+type InstanceGroupReconcileResult struct {
+	Created []string
+	Deleted []string
+}
+
+// Validate checks the parameters of the group.
+func (g *InstanceGroup) Validate() error {
+	switch {
+	case g.Name == "":
+		return fmt.Errorf("%w: name cannot be empty", ErrInstanceGroupInvalid)
+	case g.Template == nil:
+		return fmt.Errorf("%w: template cannot be nil", ErrInstanceGroupInvalid)
+	case g.DesiredCount < 0 || g.MaxSurge < 0 || g.MaxUnavailable < 0:
+		return fmt.Errorf("%w: counts cannot be negative", ErrInstanceGroupInvalid)
+	case g.MaxSurge == 0 && g.MaxUnavailable == 0:
+		return fmt.Errorf("%w: max surge and max unavailable cannot both be zero", ErrInstanceGroupInvalid)
+	}
+
+	return nil
+}
+
+// TemplateHash returns the hash of the template. Members created from another template are replaced by Reconcile.
+func (g *InstanceGroup) TemplateHash() (string, error) {
+	template := *g.Template
+	template.Names, template.NameTemplates = nil, nil
+
+	template.Metadata = make(edgecloud.Metadata, len(g.Template.Metadata))
+	for k, v := range g.Template.Metadata {
+		if k != InstanceGroupMetadataKey && k != InstanceGroupTemplateMetadataKey {
+			template.Metadata[k] = v
+		}
+	}
+
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Members returns the instances of the group ordered by creation time.
+func (g *InstanceGroup) Members(ctx context.Context, client *edgecloud.Client) ([]edgecloud.Instance, error) {
+	metadataKV, err := json.Marshal(map[string]string{InstanceGroupMetadataKey: g.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	instances, _, err := client.Instances.List(ctx, &edgecloud.InstanceListOptions{MetadataKV: string(metadataKV)})
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]edgecloud.Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Metadata[InstanceGroupMetadataKey] == g.Name {
+			members = append(members, instance)
+		}
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].CreatedAt < members[j].CreatedAt
+	})
+
+	return members, nil
+}
+
+// Reconcile creates or deletes instances to match DesiredCount. Members created from an outdated template,
+// or in error state, are replaced in batches limited by MaxSurge and MaxUnavailable. New instances must pass
+// the health check before outdated instances are deleted. On error the group is left as is, and the next
+// Reconcile continues from the discovered state.
+func (g *InstanceGroup) Reconcile(ctx context.Context, client *edgecloud.Client) (*InstanceGroupReconcileResult, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	hash, err := g.TemplateHash()
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := g.Members(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var current, outdated []edgecloud.Instance
+	for _, member := range members {
+		if member.Metadata[InstanceGroupTemplateMetadataKey] == hash && !member.Status.IsError() {
+			current = append(current, member)
+		} else {
+			outdated = append(outdated, member)
+		}
+	}
+
+	result := &InstanceGroupReconcileResult{}
+	for {
+		total := len(current) + len(outdated)
+
+		if len(outdated) == 0 {
+			switch {
+			case len(current) > g.DesiredCount:
+				err = g.deleteMembers(ctx, client, current[:len(current)-g.DesiredCount], result)
+			case len(current) < g.DesiredCount:
+				_, err = g.createMembers(ctx, client, hash, g.DesiredCount-len(current), result)
+			}
+
+			return result, err
+		}
+
+		if n := min(g.DesiredCount-len(current), g.DesiredCount+g.MaxSurge-total); n > 0 {
+			created, err := g.createMembers(ctx, client, hash, n, result)
+			if err != nil {
+				return result, err
+			}
+			current = append(current, created...)
+
+			continue
+		}
+
+		n := min(len(outdated), total-(g.DesiredCount-g.MaxUnavailable))
+		if err = g.deleteMembers(ctx, client, outdated[:n], result); err != nil {
+			return result, err
+		}
+		outdated = outdated[n:]
+	}
+}
+
+// createMembers creates n instances from the template and waits for them to pass the health check.
+func (g *InstanceGroup) createMembers(ctx context.Context, client *edgecloud.Client, hash string, n int, result *InstanceGroupReconcileResult) ([]edgecloud.Instance, error) {
+	reqBody := *g.Template
+	nameTemplate := g.Name + "-{ip_octets}"
+	if len(g.Template.NameTemplates) > 0 {
+		nameTemplate = g.Template.NameTemplates[0]
+	}
+
+	reqBody.Names = nil
+	reqBody.NameTemplates = make([]string, n)
+	for i := range reqBody.NameTemplates {
+		reqBody.NameTemplates[i] = nameTemplate
+	}
+
+	reqBody.Metadata = make(edgecloud.Metadata, len(g.Template.Metadata)+2)
+	for k, v := range g.Template.Metadata {
+		reqBody.Metadata[k] = v
+	}
+	reqBody.Metadata[InstanceGroupMetadataKey] = g.Name
+	reqBody.Metadata[InstanceGroupTemplateMetadataKey] = hash
+
+	taskResult, err := ExecuteAndExtractTaskResult(ctx, client.Instances.Create, &reqBody, client, nonZeroTimeouts(g.Timeout)...)
+	if err != nil {
+		return nil, err
+	}
+	result.Created = append(result.Created, taskResult.Instances...)
+
+	created := make([]edgecloud.Instance, 0, len(taskResult.Instances))
+	for _, instanceID := range taskResult.Instances {
+		instance, err := g.waitHealthy(ctx, client, instanceID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: instance %s: %w", ErrInstanceGroupUnhealthy, instanceID, err)
+		}
+		created = append(created, *instance)
+	}
+
+	return created, nil
+}
+
+func (g *InstanceGroup) waitHealthy(ctx context.Context, client *edgecloud.Client, instanceID string) (*edgecloud.Instance, error) {
+	instance, err := WaitForInstanceState(ctx, client, instanceID, InstanceTargetState{Status: edgecloud.InstanceStatusActive}, g.Attempts)
+	if err != nil || g.HealthCheck == nil {
+		return instance, err
+	}
+
+	err = WithRetry(
+		func() error {
+			return g.HealthCheck(ctx, instance)
+		},
+		g.Attempts,
+	)
+
+	return instance, err
+}
+
+func (g *InstanceGroup) deleteMembers(ctx context.Context, client *edgecloud.Client, members []edgecloud.Instance, result *InstanceGroupReconcileResult) error {
+	for _, member := range members {
+		if err := DeleteInstanceAndWait(ctx, client, &member, nonZeroTimeouts(g.Timeout)...); err != nil {
+			return err
+		}
+		result.Deleted = append(result.Deleted, member.ID)
+	}
+
+	return nil
+}
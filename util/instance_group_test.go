@@ -0,0 +1,268 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// fakeInstancesAPI keeps instances in memory and serves the list, get, create and delete instance endpoints.
+type fakeInstancesAPI struct {
+	*fakeAPI
+	instances []edgecloud.Instance
+	created   int
+	// maxCount and minCount are the bounds of the number of instances observed after every change.
+	maxCount int
+	minCount int
+}
+
+func newFakeInstancesAPI(t *testing.T, mux *http.ServeMux, instances []edgecloud.Instance) *fakeInstancesAPI {
+	t.Helper()
+
+	api := &fakeInstancesAPI{
+		fakeAPI:   newFakeAPI(t, mux),
+		instances: append([]edgecloud.Instance(nil), instances...),
+		maxCount:  len(instances),
+		minCount:  len(instances),
+	}
+	api.handle(resourcePath("/v1/instances"), api.list)
+	api.handle(resourcePath("/v1/instances")+"/", api.instance)
+	api.handle(resourcePath("/v2/instances"), api.create)
+
+	return api
+}
+
+func (api *fakeInstancesAPI) list(w http.ResponseWriter, r *http.Request) {
+	var kv map[string]string
+	if s := r.URL.Query().Get("metadata_kv"); s != "" {
+		if err := json.Unmarshal([]byte(s), &kv); err != nil {
+			api.t.Errorf("invalid metadata_kv: %v", err)
+		}
+	}
+
+	instances := []edgecloud.Instance{}
+	for _, instance := range api.instances {
+		match := true
+		for k, v := range kv {
+			match = match && instance.Metadata[k] == v
+		}
+		if match {
+			instances = append(instances, instance)
+		}
+	}
+	writeTestJSON(api.t, w, map[string]interface{}{"results": instances})
+}
+
+func (api *fakeInstancesAPI) instance(w http.ResponseWriter, r *http.Request) {
+	id := path.Base(r.URL.Path)
+	for i, instance := range api.instances {
+		if instance.ID != id {
+			continue
+		}
+		if r.Method == http.MethodDelete {
+			api.instances = append(api.instances[:i], api.instances[i+1:]...)
+			api.minCount = min(api.minCount, len(api.instances))
+			api.writeTask(w, nil)
+
+			return
+		}
+		writeTestJSON(api.t, w, instance)
+
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (api *fakeInstancesAPI) create(w http.ResponseWriter, r *http.Request) {
+	var reqBody edgecloud.InstanceCreateRequest
+	api.decode(r, &reqBody)
+
+	ids := make([]string, 0, len(reqBody.NameTemplates))
+	for _, name := range reqBody.NameTemplates {
+		api.created++
+		instance := edgecloud.Instance{
+			ID:        uuid.NewString(),
+			Name:      strings.Replace(name, "{ip_octets}", strconv.Itoa(api.created), 1),
+			Status:    edgecloud.InstanceStatusActive,
+			Metadata:  reqBody.Metadata,
+			CreatedAt: fmt.Sprintf("2024-01-01T00:00:%02d", api.created),
+		}
+		api.instances = append(api.instances, instance)
+		ids = append(ids, instance.ID)
+	}
+	api.maxCount = max(api.maxCount, len(api.instances))
+	api.writeTask(w, map[string]interface{}{"instances": ids})
+}
+
+func (api *fakeInstancesAPI) members(group string) []edgecloud.Instance {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	var members []edgecloud.Instance
+	for _, instance := range api.instances {
+		if instance.Metadata[InstanceGroupMetadataKey] == group {
+			members = append(members, instance)
+		}
+	}
+
+	return members
+}
+
+func testInstanceGroup() *InstanceGroup {
+	return &InstanceGroup{
+		Name:         "web",
+		DesiredCount: 3,
+		MaxSurge:     1,
+		Template: &edgecloud.InstanceCreateRequest{
+			Flavor:     testFlavorID,
+			Interfaces: []edgecloud.InstanceInterface{{Type: edgecloud.InterfaceTypeExternal}},
+			Volumes:    []edgecloud.InstanceVolumeCreate{{Source: edgecloud.VolumeSourceImage, ImageID: testResourceID, Size: 10}},
+			Metadata:   edgecloud.Metadata{"role": "web"},
+		},
+		Attempts: &attempts,
+	}
+}
+
+func TestInstanceGroup_TemplateHash(t *testing.T) {
+	group := testInstanceGroup()
+	hash, err := group.TemplateHash()
+	require.NoError(t, err)
+
+	group.Template.NameTemplates = []string{"other-{ip_octets}"}
+	group.Template.Metadata[InstanceGroupMetadataKey] = "other"
+	sameHash, err := group.TemplateHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, sameHash)
+
+	group.Template.Flavor = testNewFlavorID
+	newHash, err := group.TemplateHash()
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, newHash)
+}
+
+func TestInstanceGroup_ReconcileScale(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	other := edgecloud.Instance{ID: testResourceID, Metadata: edgecloud.Metadata{"role": "db"}}
+	api := newFakeInstancesAPI(t, mux, []edgecloud.Instance{other})
+	client := newTestClient(server.URL)
+	group := testInstanceGroup()
+
+	result, err := group.Reconcile(context.Background(), client)
+	require.NoError(t, err)
+	assert.Len(t, result.Created, 3)
+	assert.Empty(t, result.Deleted)
+
+	members := api.members(group.Name)
+	require.Len(t, members, 3)
+	assert.Equal(t, "web-1", members[0].Name)
+	assert.Equal(t, "web", members[0].Metadata["role"])
+
+	group.DesiredCount = 1
+	result, err = group.Reconcile(context.Background(), client)
+	require.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Equal(t, []string{members[0].ID, members[1].ID}, result.Deleted)
+	assert.Len(t, api.members(group.Name), 1)
+
+	result, err = group.Reconcile(context.Background(), client)
+	require.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Deleted)
+}
+
+func TestInstanceGroup_ReconcileRollingReplace(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	group := testInstanceGroup()
+	hash, err := group.TemplateHash()
+	require.NoError(t, err)
+
+	var outdated []edgecloud.Instance
+	for i := 0; i < 3; i++ {
+		outdated = append(outdated, edgecloud.Instance{
+			ID:        uuid.NewString(),
+			Status:    edgecloud.InstanceStatusActive,
+			Metadata:  edgecloud.Metadata{InstanceGroupMetadataKey: group.Name, InstanceGroupTemplateMetadataKey: hash},
+			CreatedAt: fmt.Sprintf("2023-01-01T00:00:%02d", i),
+		})
+	}
+	api := newFakeInstancesAPI(t, mux, outdated)
+	client := newTestClient(server.URL)
+
+	var checked []string
+	group.Template.Flavor = testNewFlavorID
+	group.HealthCheck = func(ctx context.Context, instance *edgecloud.Instance) error {
+		checked = append(checked, instance.ID)
+		return nil
+	}
+
+	result, err := group.Reconcile(context.Background(), client)
+	require.NoError(t, err)
+	assert.Len(t, result.Created, 3)
+	assert.Equal(t, []string{outdated[0].ID, outdated[1].ID, outdated[2].ID}, result.Deleted)
+	assert.Equal(t, result.Created, checked)
+
+	// max surge 1, max unavailable 0
+	assert.Equal(t, 4, api.maxCount)
+	assert.Equal(t, 3, api.minCount)
+
+	newHash, err := group.TemplateHash()
+	require.NoError(t, err)
+	for _, member := range api.members(group.Name) {
+		assert.Equal(t, newHash, member.Metadata[InstanceGroupTemplateMetadataKey])
+	}
+}
+
+func TestInstanceGroup_ReconcileHealthGate(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	group := testInstanceGroup()
+	outdated := edgecloud.Instance{
+		ID:       testResourceID,
+		Status:   edgecloud.InstanceStatusActive,
+		Metadata: edgecloud.Metadata{InstanceGroupMetadataKey: group.Name, InstanceGroupTemplateMetadataKey: "outdated"},
+	}
+	api := newFakeInstancesAPI(t, mux, []edgecloud.Instance{outdated})
+	client := newTestClient(server.URL)
+
+	errUnhealthy := errors.New("unhealthy")
+	group.DesiredCount = 1
+	group.HealthCheck = func(ctx context.Context, instance *edgecloud.Instance) error {
+		return errUnhealthy
+	}
+
+	result, err := group.Reconcile(context.Background(), client)
+	assert.ErrorIs(t, err, ErrInstanceGroupUnhealthy)
+	assert.ErrorIs(t, err, errUnhealthy)
+	assert.Len(t, result.Created, 1)
+	assert.Empty(t, result.Deleted)
+	assert.Len(t, api.members(group.Name), 2)
+}
+
+func TestInstanceGroup_Validate(t *testing.T) {
+	group := testInstanceGroup()
+	group.MaxSurge = 0
+
+	_, err := group.Reconcile(context.Background(), edgecloud.NewClient(nil))
+	assert.ErrorIs(t, err, ErrInstanceGroupInvalid)
+}
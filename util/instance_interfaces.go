@@ -0,0 +1,201 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var (
+	ErrInterfaceInvalid          = errors.New("invalid interface options")
+	ErrInterfaceAttachedNotFound = errors.New("the attached interface was not found on the instance")
+)
+
+// InterfaceAddOptions specifies the parameters to AddInterface.
+type InterfaceAddOptions struct {
+	// Interface is the interface to attach. Required.
+	Interface *edgecloud.InstanceAttachInterfaceRequest
+	// PortSecurity enables or disables the port security of the new port. It is left as is if nil.
+	PortSecurity *bool
+	// SecurityGroupNames are assigned to the new port. They require the port security to be enabled.
+	SecurityGroupNames []string
+	// FloatingIP creates a new floating IP or assigns an existing one to the new port. Optional.
+	FloatingIP *edgecloud.InterfaceFloatingIP
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to find the new port on the instance.
+	Attempts *uint
+}
+
+// AddInterface attaches the interface to the instance, configures the port security, the security groups and
+// the floating IP of the new port, and returns the interface with its addresses. If a step after the attachment
+// fails, the interface is detached again, if its port is known.
+func AddInterface(ctx context.Context, client *edgecloud.Client, instanceID string, opts *InterfaceAddOptions) (*edgecloud.InstancePortInterface, error) {
+	if opts == nil || opts.Interface == nil {
+		return nil, fmt.Errorf("%w: interface cannot be nil", ErrInterfaceInvalid)
+	}
+
+	if len(opts.SecurityGroupNames) > 0 && opts.PortSecurity != nil && !*opts.PortSecurity {
+		return nil, fmt.Errorf("%w: security groups require the port security", ErrInterfaceInvalid)
+	}
+
+	before, _, err := client.Instances.InterfaceList(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(before))
+	for _, iface := range before {
+		existing[iface.PortID] = true
+	}
+
+	result, err := ExecuteAndExtractTaskResult(ctx, func(ctx context.Context, req *edgecloud.InstanceAttachInterfaceRequest) (*edgecloud.TaskResponse, *edgecloud.Response, error) {
+		return client.Instances.AttachInterface(ctx, instanceID, req)
+	}, opts.Interface, client, nonZeroTimeouts(opts.Timeout)...)
+	if err != nil {
+		return nil, err
+	}
+
+	portID := opts.Interface.PortID
+	if portID == "" && len(result.Ports) > 0 {
+		portID = result.Ports[0]
+	}
+
+	iface, err := attachedInterface(ctx, client, instanceID, portID, existing, opts.Attempts)
+	if err != nil {
+		if portID != "" {
+			err = errors.Join(err, detachInterface(ctx, client, instanceID, portID, opts.Timeout))
+		}
+		return nil, err
+	}
+
+	if err = configureInterface(ctx, client, instanceID, iface.PortID, opts); err != nil {
+		detachErr := detachInterface(ctx, client, instanceID, iface.PortID, opts.Timeout)
+		return nil, errors.Join(err, detachErr)
+	}
+
+	configured, err := InstanceNetworkInterfaceByID(ctx, client, instanceID, iface.PortID)
+	if err != nil {
+		detachErr := detachInterface(ctx, client, instanceID, iface.PortID, opts.Timeout)
+		return nil, errors.Join(err, detachErr)
+	}
+
+	return configured, nil
+}
+
+// attachedInterface waits for the new interface to appear on the instance. The port is looked up by its ID
+// if known, otherwise it is the port that was not attached before.
+func attachedInterface(ctx context.Context, client *edgecloud.Client, instanceID, portID string, existing map[string]bool, attempts *uint) (*edgecloud.InstancePortInterface, error) {
+	var iface *edgecloud.InstancePortInterface
+
+	err := WithRetry(
+		func() error {
+			ifaces, _, err := client.Instances.InterfaceList(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range ifaces {
+				if portID != "" && i.PortID == portID || portID == "" && !existing[i.PortID] {
+					iface = &i
+					return nil
+				}
+			}
+
+			return fmt.Errorf("%w: instance %s", ErrInterfaceAttachedNotFound, instanceID)
+		},
+		attempts,
+	)
+
+	return iface, err
+}
+
+func configureInterface(ctx context.Context, client *edgecloud.Client, instanceID, portID string, opts *InterfaceAddOptions) error {
+	if opts.PortSecurity != nil {
+		var err error
+		if *opts.PortSecurity {
+			_, _, err = client.Ports.EnablePortSecurity(ctx, portID)
+		} else {
+			_, _, err = client.Ports.DisablePortSecurity(ctx, portID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(opts.SecurityGroupNames) > 0 {
+		_, err := client.Instances.SecurityGroupAssign(ctx, instanceID, &edgecloud.AssignSecurityGroupRequest{
+			PortsSecurityGroupNames: []edgecloud.PortsSecurityGroupNames{{PortID: portID, SecurityGroupNames: opts.SecurityGroupNames}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.FloatingIP == nil {
+		return nil
+	}
+
+	switch opts.FloatingIP.Source {
+	case edgecloud.NewFloatingIP:
+		_, err := ExecuteAndExtractTaskResult(ctx, client.Floatingips.Create, &edgecloud.FloatingIPCreateRequest{PortID: portID}, client, nonZeroTimeouts(opts.Timeout)...)
+		return err
+	case edgecloud.ExistingFloatingIP:
+		_, _, err := client.Floatingips.Assign(ctx, opts.FloatingIP.ExistingFloatingID, &edgecloud.AssignFloatingIPRequest{PortID: portID})
+		return err
+	default:
+		return fmt.Errorf("%w: unknown floating IP source %q", ErrInterfaceInvalid, opts.FloatingIP.Source)
+	}
+}
+
+// InterfaceRemoveOptions specifies the optional parameters to RemoveInterface.
+type InterfaceRemoveOptions struct {
+	// DeleteFloatingIPs deletes the floating IPs of the port instead of only unassigning them.
+	DeleteFloatingIPs bool
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+}
+
+// RemoveInterface releases the floating IPs of the port and detaches it from the instance.
+func RemoveInterface(ctx context.Context, client *edgecloud.Client, instanceID, portID string, opts *InterfaceRemoveOptions) error {
+	if opts == nil {
+		opts = &InterfaceRemoveOptions{}
+	}
+
+	iface, err := InstanceNetworkInterfaceByID(ctx, client, instanceID, portID)
+	if err != nil {
+		return err
+	}
+
+	for _, fip := range iface.FloatingIPDetails {
+		if opts.DeleteFloatingIPs {
+			task, _, err := client.Floatingips.Delete(ctx, fip.ID)
+			if err != nil {
+				return err
+			}
+
+			if err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, _, err = client.Floatingips.UnAssign(ctx, fip.ID); err != nil {
+			return err
+		}
+	}
+
+	return detachInterface(ctx, client, instanceID, portID, opts.Timeout)
+}
+
+func detachInterface(ctx context.Context, client *edgecloud.Client, instanceID, portID string, timeout time.Duration) error {
+	task, _, err := client.Instances.DetachInterface(ctx, instanceID, &edgecloud.InstanceDetachInterfaceRequest{PortID: portID})
+	if err != nil {
+		return err
+	}
+
+	return WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(timeout)...)
+}
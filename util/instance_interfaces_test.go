@@ -0,0 +1,179 @@
+package util
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// fakeInterfacesAPI keeps the interfaces of an instance in memory.
+type fakeInterfacesAPI struct {
+	*fakeAPI
+	interfaces []edgecloud.InstancePortInterface
+	// failSecurityGroups makes the security group assignment fail.
+	failSecurityGroups bool
+}
+
+func newFakeInterfacesAPI(t *testing.T, mux *http.ServeMux) *fakeInterfacesAPI {
+	t.Helper()
+
+	api := &fakeInterfacesAPI{
+		fakeAPI:    newFakeAPI(t, mux),
+		interfaces: []edgecloud.InstancePortInterface{{PortID: testResourceID2, NetworkID: testNetworkID}},
+	}
+
+	URLInstance := resourcePath("/v1/instances", testResourceID)
+	api.handle(path.Join(URLInstance, "interfaces"), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, map[string]interface{}{"results": api.interfaces})
+	})
+	api.handle(path.Join(URLInstance, "attach_interface"), func(w http.ResponseWriter, r *http.Request) {
+		var reqBody edgecloud.InstanceAttachInterfaceRequest
+		api.decode(r, &reqBody)
+		api.call("attach %s", reqBody.SubnetID)
+		api.interfaces = append(api.interfaces, edgecloud.InstancePortInterface{
+			PortID:        testResourceID3,
+			NetworkID:     testNetworkID,
+			IPAssignments: []edgecloud.PortIP{{IPAddress: net.ParseIP("10.0.1.5"), SubnetID: reqBody.SubnetID}},
+		})
+		api.writeTask(w, nil)
+	})
+	api.handle(path.Join(URLInstance, "detach_interface"), func(w http.ResponseWriter, r *http.Request) {
+		var reqBody edgecloud.InstanceDetachInterfaceRequest
+		api.decode(r, &reqBody)
+		api.call("detach %s", reqBody.PortID)
+		for i, iface := range api.interfaces {
+			if iface.PortID == reqBody.PortID {
+				api.interfaces = append(api.interfaces[:i], api.interfaces[i+1:]...)
+				break
+			}
+		}
+		api.writeTask(w, nil)
+	})
+	api.handle(path.Join(URLInstance, "addsecuritygroup"), func(w http.ResponseWriter, r *http.Request) {
+		if api.failSecurityGroups {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var reqBody edgecloud.AssignSecurityGroupRequest
+		api.decode(r, &reqBody)
+		for _, port := range reqBody.PortsSecurityGroupNames {
+			api.call("security groups %s %v", port.PortID, port.SecurityGroupNames)
+		}
+	})
+	api.handle(resourcePath("/v1/ports", testResourceID3, "enable_port_security"), func(w http.ResponseWriter, r *http.Request) {
+		api.call("enable port security %s", testResourceID3)
+		api.interfaces[len(api.interfaces)-1].PortSecurityEnabled = true
+		writeTestJSON(t, w, api.interfaces[len(api.interfaces)-1])
+	})
+
+	URLFloatingIPs := resourcePath("/v1/floatingips")
+	api.handle(URLFloatingIPs, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody edgecloud.FloatingIPCreateRequest
+		api.decode(r, &reqBody)
+		api.call("create floating IP %s", reqBody.PortID)
+		for i := range api.interfaces {
+			if api.interfaces[i].PortID == reqBody.PortID {
+				api.interfaces[i].FloatingIPDetails = []edgecloud.FloatingIP{{ID: testSnapshotID, FloatingIPAddress: "203.0.113.7", PortID: reqBody.PortID}}
+			}
+		}
+		api.writeTask(w, map[string]interface{}{"floatingips": []string{testSnapshotID}})
+	})
+	api.handle(path.Join(URLFloatingIPs, testSnapshotID, "unassign"), func(w http.ResponseWriter, r *http.Request) {
+		api.call("unassign floating IP %s", testSnapshotID)
+		for i := range api.interfaces {
+			api.interfaces[i].FloatingIPDetails = nil
+		}
+		writeTestJSON(t, w, edgecloud.FloatingIP{ID: testSnapshotID})
+	})
+
+	return api
+}
+
+func TestAddInterface(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeInterfacesAPI(t, mux)
+
+	iface, err := AddInterface(context.Background(), newTestClient(server.URL), testResourceID, &InterfaceAddOptions{
+		Interface:          &edgecloud.InstanceAttachInterfaceRequest{Type: edgecloud.InterfaceTypeSubnet, SubnetID: testSubnetID},
+		PortSecurity:       edgecloud.PtrTo(true),
+		SecurityGroupNames: []string{"appliance"},
+		FloatingIP:         &edgecloud.InterfaceFloatingIP{Source: edgecloud.NewFloatingIP},
+		Attempts:           &attempts,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testResourceID3, iface.PortID)
+	assert.True(t, iface.PortSecurityEnabled)
+	assert.Equal(t, "10.0.1.5", iface.IPAssignments[0].IPAddress.String())
+	assert.Equal(t, "203.0.113.7", iface.FloatingIPDetails[0].FloatingIPAddress)
+	assert.Equal(t, []string{
+		"attach " + testSubnetID,
+		"enable port security " + testResourceID3,
+		"security groups " + testResourceID3 + " [appliance]",
+		"create floating IP " + testResourceID3,
+	}, api.calls)
+
+	api.calls = nil
+	err = RemoveInterface(context.Background(), newTestClient(server.URL), testResourceID, testResourceID3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"unassign floating IP " + testSnapshotID,
+		"detach " + testResourceID3,
+	}, api.calls)
+	assert.Len(t, api.interfaces, 1)
+}
+
+func TestAddInterface_DetachOnError(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeInterfacesAPI(t, mux)
+	api.failSecurityGroups = true
+
+	iface, err := AddInterface(context.Background(), newTestClient(server.URL), testResourceID, &InterfaceAddOptions{
+		Interface:          &edgecloud.InstanceAttachInterfaceRequest{Type: edgecloud.InterfaceTypeSubnet, SubnetID: testSubnetID},
+		SecurityGroupNames: []string{"appliance"},
+		Attempts:           &attempts,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, iface)
+	assert.Equal(t, []string{"attach " + testSubnetID, "detach " + testResourceID3}, api.calls)
+	assert.Len(t, api.interfaces, 1)
+
+	_, err = AddInterface(context.Background(), newTestClient(server.URL), testResourceID, &InterfaceAddOptions{
+		Interface:          &edgecloud.InstanceAttachInterfaceRequest{Type: edgecloud.InterfaceTypeSubnet, SubnetID: testSubnetID},
+		PortSecurity:       edgecloud.PtrTo(false),
+		SecurityGroupNames: []string{"appliance"},
+	})
+	assert.ErrorIs(t, err, ErrInterfaceInvalid)
+}
+
+func TestAddInterface_DetachNotFound(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeInterfacesAPI(t, mux)
+	// the fake attaches testResourceID3 instead of the requested port
+	portID := "3e2d1c0b-9a8f-4e7d-8c6b-5a4f3e2d1c0b"
+
+	iface, err := AddInterface(context.Background(), newTestClient(server.URL), testResourceID, &InterfaceAddOptions{
+		Interface: &edgecloud.InstanceAttachInterfaceRequest{Type: edgecloud.InterfaceTypeAnySubnet, PortID: portID},
+		Attempts:  &attempts,
+	})
+	assert.ErrorIs(t, err, ErrInterfaceAttachedNotFound)
+	assert.Nil(t, iface)
+	assert.Equal(t, []string{"attach ", "detach " + portID}, api.calls)
+}
@@ -0,0 +1,180 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var (
+	ErrInstanceResizeSameFlavor       = errors.New("the instance already has the requested flavor")
+	ErrInstanceResizeFlavorNotAllowed = errors.New("the flavor is not available to resize the instance into")
+	ErrInstanceResizeFlavorDisabled   = errors.New("the flavor is disabled")
+	ErrInstanceResizeFlavorNotApplied = errors.New("the instance flavor was not changed after resize")
+	ErrInstanceResizeNotConfirmed     = errors.New("the instance resize is not confirmed yet")
+)
+
+// InstanceResizeOptions specifies the optional parameters to ResizeInstance.
+type InstanceResizeOptions struct {
+	// StopBeforeResize stops a running instance before the flavor change.
+	StopBeforeResize bool
+	// TaskTimeout is the maximum time to wait for the flavor change task.
+	TaskTimeout time.Duration
+	// Attempts is the number of attempts to wait for the instance power state.
+	Attempts *uint
+}
+
+// InstanceResizeResult describes a completed resize.
+type InstanceResizeResult struct {
+	PreviousFlavor *edgecloud.Flavor
+	Instance       *edgecloud.Instance
+	// Stopped is true when the instance was stopped for the resize.
+	Stopped bool
+}
+
+// ResizeInstance changes the flavor of the instance. The target flavor must be in the list returned by
+// AvailableFlavorsToResize, otherwise the resize is refused before any change is made. After the flavor
+// change task is complete, ResizeInstance waits for the resize to be confirmed (the instance leaves VERIFY_RESIZE),
+// the new flavor is verified and the previous power state of the instance is restored.
+func ResizeInstance(ctx context.Context, client *edgecloud.Client, instanceID, flavorID string, opts *InstanceResizeOptions) (*InstanceResizeResult, error) {
+	if opts == nil {
+		opts = &InstanceResizeOptions{}
+	}
+
+	instance, _, err := client.Instances.Get(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkInstanceResizeAllowed(ctx, client, instance, flavorID); err != nil {
+		return nil, err
+	}
+
+	result := &InstanceResizeResult{PreviousFlavor: instance.Flavor}
+	previousStatus := instance.Status
+
+	if opts.StopBeforeResize && previousStatus == edgecloud.InstanceStatusActive {
+		if _, err = InstanceActionAndWait(ctx, client, instanceID, InstanceActionStop, opts.Attempts); err != nil {
+			return nil, err
+		}
+		result.Stopped = true
+	}
+
+	if err = changeInstanceFlavor(ctx, client, instanceID, flavorID, opts.TaskTimeout); err != nil {
+		return nil, errors.Join(err, restoreInstancePowerState(ctx, client, instanceID, previousStatus, opts.Attempts))
+	}
+
+	instance, err = waitForInstanceResized(ctx, client, instanceID, opts.Attempts)
+	if err == nil && (instance.Flavor == nil || instance.Flavor.FlavorID != flavorID) {
+		err = fmt.Errorf("%w: instance %s, expected flavor %s", ErrInstanceResizeFlavorNotApplied, instanceID, flavorID)
+	}
+	if err != nil {
+		return nil, errors.Join(err, restoreInstancePowerState(ctx, client, instanceID, previousStatus, opts.Attempts))
+	}
+
+	if err = restoreInstancePowerState(ctx, client, instanceID, previousStatus, opts.Attempts); err != nil {
+		return nil, err
+	}
+
+	result.Instance, _, err = client.Instances.Get(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func checkInstanceResizeAllowed(ctx context.Context, client *edgecloud.Client, instance *edgecloud.Instance, flavorID string) error {
+	if instance.Flavor != nil && instance.Flavor.FlavorID == flavorID {
+		return fmt.Errorf("%w: instance %s, flavor %s", ErrInstanceResizeSameFlavor, instance.ID, flavorID)
+	}
+
+	flavors, _, err := client.Instances.AvailableFlavorsToResize(ctx, instance.ID, nil)
+	if err != nil {
+		return err
+	}
+
+	allowed := make([]string, 0, len(flavors))
+	for _, f := range flavors {
+		if f.FlavorID != flavorID {
+			if !f.Disabled {
+				allowed = append(allowed, f.FlavorID)
+			}
+			continue
+		}
+
+		if f.Disabled {
+			return fmt.Errorf("%w: flavor %s", ErrInstanceResizeFlavorDisabled, flavorID)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: instance %s, flavor %s, allowed flavors: [%s]",
+		ErrInstanceResizeFlavorNotAllowed, instance.ID, flavorID, strings.Join(allowed, ", "))
+}
+
+func changeInstanceFlavor(ctx context.Context, client *edgecloud.Client, instanceID, flavorID string, timeout time.Duration) error {
+	task, _, err := client.Instances.UpdateFlavor(ctx, instanceID, &edgecloud.InstanceFlavorUpdateRequest{FlavorID: flavorID})
+	if err != nil {
+		return err
+	}
+
+	return WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(timeout)...)
+}
+
+// waitForInstanceResized waits for the instance to leave the resize statuses and returns it.
+func waitForInstanceResized(ctx context.Context, client *edgecloud.Client, instanceID string, attempts *uint) (*edgecloud.Instance, error) {
+	var instance *edgecloud.Instance
+	err := WithRetry(
+		func() error {
+			var err error
+			instance, _, err = client.Instances.Get(ctx, instanceID)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case instance.Status.IsError():
+				return retry.Unrecoverable(fmt.Errorf("%w: instance %s", ErrInstanceErrorState, instanceID))
+			case instance.Status == edgecloud.InstanceStatusResize,
+				instance.Status == edgecloud.InstanceStatusVerifyResize,
+				instance.Status == edgecloud.InstanceStatusRevertResize:
+				return fmt.Errorf("%w: instance %s, status %s", ErrInstanceResizeNotConfirmed, instanceID, instance.Status)
+			}
+
+			return nil
+		},
+		attempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// restoreInstancePowerState brings the instance back to the power state it had before an operation.
+func restoreInstancePowerState(ctx context.Context, client *edgecloud.Client, instanceID string, status edgecloud.InstanceStatus, attempts *uint) error {
+	var action InstanceActionType
+	switch status {
+	case edgecloud.InstanceStatusActive:
+		action = InstanceActionStart
+	case edgecloud.InstanceStatusShutoff:
+		action = InstanceActionStop
+	case edgecloud.InstanceStatusSuspended:
+		action = InstanceActionSuspend
+	default:
+		return nil
+	}
+
+	_, err := InstanceActionAndWait(ctx, client, instanceID, action, attempts)
+
+	return err
+}
@@ -0,0 +1,230 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	testFlavorID    = "g1-standard-1-2"
+	testNewFlavorID = "g1-standard-2-4"
+	testTaskID      = "3c8b3f2a-5d1e-4f6a-8b7c-9d0e1f2a3b4c"
+)
+
+type fakeResizableInstance struct {
+	mu       sync.Mutex
+	instance edgecloud.Instance
+	actions  []string
+	// verifyResizeGets is the number of reads answered with VERIFY_RESIZE after the flavor change.
+	verifyResizeGets int
+	// failGetsAfterResize is the number of reads that fail after the flavor change.
+	failGetsAfterResize int
+	resizedStatus       edgecloud.InstanceStatus
+}
+
+func (f *fakeResizableInstance) register(t *testing.T, mux *http.ServeMux, flavors []edgecloud.Flavor) {
+	t.Helper()
+
+	instancePath := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	writeInstance := func(w http.ResponseWriter) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		resp, err := json.Marshal(f.instance)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	}
+
+	mux.HandleFunc(instancePath, func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		if f.failGetsAfterResize > 0 && slices.Contains(f.actions, "changeflavor") {
+			f.failGetsAfterResize--
+			f.mu.Unlock()
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if f.instance.Status == edgecloud.InstanceStatusVerifyResize {
+			if f.verifyResizeGets--; f.verifyResizeGets <= 0 {
+				f.instance.Status = f.resizedStatus
+			}
+		}
+		f.mu.Unlock()
+		writeInstance(w)
+	})
+	mux.HandleFunc(path.Join(instancePath, "stop"), func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.actions = append(f.actions, "stop")
+		f.instance.Status, f.instance.VMState = edgecloud.InstanceStatusShutoff, edgecloud.InstanceVMStateStopped
+		f.mu.Unlock()
+		writeInstance(w)
+	})
+	mux.HandleFunc(path.Join(instancePath, "start"), func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.actions = append(f.actions, "start")
+		f.instance.Status, f.instance.VMState = edgecloud.InstanceStatusActive, edgecloud.InstanceVMStateActive
+		f.mu.Unlock()
+		writeInstance(w)
+	})
+	mux.HandleFunc(path.Join(instancePath, "available_flavors"), func(w http.ResponseWriter, r *http.Request) {
+		resp, err := json.Marshal(flavors)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprintf(w, `{"results":%s}`, string(resp))
+	})
+	mux.HandleFunc(path.Join(instancePath, "changeflavor"), func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.InstanceFlavorUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		f.mu.Lock()
+		f.actions = append(f.actions, "changeflavor")
+		f.instance.Flavor = &edgecloud.Flavor{FlavorID: req.FlavorID}
+		if f.verifyResizeGets > 0 {
+			f.resizedStatus, f.instance.Status = f.instance.Status, edgecloud.InstanceStatusVerifyResize
+		}
+		f.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `{"tasks":["%s"]}`, testTaskID)
+	})
+	mux.HandleFunc(path.Join("/v1/tasks", testTaskID), func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"id":"%s","state":"%s"}`, testTaskID, edgecloud.TaskStateFinished)
+	})
+}
+
+func TestResizeInstance(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fake := &fakeResizableInstance{instance: edgecloud.Instance{
+		ID:      testResourceID,
+		Status:  edgecloud.InstanceStatusActive,
+		VMState: edgecloud.InstanceVMStateActive,
+		Flavor:  &edgecloud.Flavor{FlavorID: testFlavorID},
+	}}
+	fake.register(t, mux, []edgecloud.Flavor{{FlavorID: testNewFlavorID}})
+
+	client := newTestClient(server.URL)
+
+	result, err := ResizeInstance(context.Background(), client, testResourceID, testNewFlavorID, &InstanceResizeOptions{StopBeforeResize: true, Attempts: &attempts})
+	require.NoError(t, err)
+	assert.True(t, result.Stopped)
+	assert.Equal(t, testFlavorID, result.PreviousFlavor.FlavorID)
+	assert.Equal(t, testNewFlavorID, result.Instance.Flavor.FlavorID)
+	assert.Equal(t, edgecloud.InstanceStatusActive, result.Instance.Status)
+	assert.Equal(t, []string{"stop", "changeflavor", "start"}, fake.actions)
+}
+
+func TestResizeInstance_VerifyResize(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fake := &fakeResizableInstance{
+		instance: edgecloud.Instance{
+			ID:      testResourceID,
+			Status:  edgecloud.InstanceStatusActive,
+			VMState: edgecloud.InstanceVMStateActive,
+			Flavor:  &edgecloud.Flavor{FlavorID: testFlavorID},
+		},
+		verifyResizeGets: 1,
+	}
+	fake.register(t, mux, []edgecloud.Flavor{{FlavorID: testNewFlavorID}})
+
+	client := newTestClient(server.URL)
+
+	result, err := ResizeInstance(context.Background(), client, testResourceID, testNewFlavorID, &InstanceResizeOptions{StopBeforeResize: true, Attempts: &attempts})
+	require.NoError(t, err)
+	assert.Equal(t, testNewFlavorID, result.Instance.Flavor.FlavorID)
+	assert.Equal(t, edgecloud.InstanceStatusActive, result.Instance.Status)
+	assert.Equal(t, []string{"stop", "changeflavor", "start"}, fake.actions)
+}
+
+func TestResizeInstance_GetError(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fake := &fakeResizableInstance{
+		instance: edgecloud.Instance{
+			ID:      testResourceID,
+			Status:  edgecloud.InstanceStatusActive,
+			VMState: edgecloud.InstanceVMStateActive,
+			Flavor:  &edgecloud.Flavor{FlavorID: testFlavorID},
+		},
+		failGetsAfterResize: int(attempts),
+	}
+	fake.register(t, mux, []edgecloud.Flavor{{FlavorID: testNewFlavorID}})
+
+	client := newTestClient(server.URL)
+
+	result, err := ResizeInstance(context.Background(), client, testResourceID, testNewFlavorID, &InstanceResizeOptions{StopBeforeResize: true, Attempts: &attempts})
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, []string{"stop", "changeflavor", "start"}, fake.actions)
+	assert.Equal(t, edgecloud.InstanceStatusActive, fake.instance.Status)
+}
+
+func TestResizeInstance_Refused(t *testing.T) {
+	tests := []struct {
+		name          string
+		flavorID      string
+		flavors       []edgecloud.Flavor
+		expectedError error
+	}{
+		{
+			name:          "same flavor",
+			flavorID:      testFlavorID,
+			expectedError: ErrInstanceResizeSameFlavor,
+		},
+		{
+			name:          "flavor not allowed",
+			flavorID:      testNewFlavorID,
+			flavors:       []edgecloud.Flavor{{FlavorID: "g1-standard-4-8"}},
+			expectedError: ErrInstanceResizeFlavorNotAllowed,
+		},
+		{
+			name:          "flavor disabled",
+			flavorID:      testNewFlavorID,
+			flavors:       []edgecloud.Flavor{{FlavorID: testNewFlavorID, Disabled: true}},
+			expectedError: ErrInstanceResizeFlavorDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			fake := &fakeResizableInstance{instance: edgecloud.Instance{
+				ID:      testResourceID,
+				Status:  edgecloud.InstanceStatusActive,
+				VMState: edgecloud.InstanceVMStateActive,
+				Flavor:  &edgecloud.Flavor{FlavorID: testFlavorID},
+			}}
+			fake.register(t, mux, tt.flavors)
+
+			client := newTestClient(server.URL)
+
+			result, err := ResizeInstance(context.Background(), client, testResourceID, tt.flavorID, &InstanceResizeOptions{StopBeforeResize: true})
+			assert.ErrorIs(t, err, tt.expectedError)
+			assert.Nil(t, result)
+			assert.Empty(t, fake.actions)
+		})
+	}
+}
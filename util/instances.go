@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
 )
 
-const InstanceShutoffStatus = "SHUTOFF"
+// Deprecated: use edgecloud.InstanceStatusShutoff.
+const InstanceShutoffStatus = edgecloud.InstanceStatusShutoff
 
 var (
 	ErrInstanceNotShutOff        = errors.New("the instance is not shut off")
@@ -29,7 +31,7 @@ func WaitForInstanceShutoff(ctx context.Context, client *edgecloud.Client, insta
 				return err
 			}
 
-			if instance.Status == InstanceShutoffStatus {
+			if instance.Status == edgecloud.InstanceStatusShutoff {
 				return nil
 			}
 
@@ -66,3 +68,21 @@ func InstanceNetworkPortByID(ctx context.Context, client *edgecloud.Client, inst
 
 	return nil, fmt.Errorf("%w :there is no port with id %s in instance with id %s", ErrInstancePortNotFound, portID, instanceID)
 }
+
+// DeleteInstanceAndWait deletes the instance together with the volumes that are deleted on termination
+// and waits for the deletion task to complete.
+func DeleteInstanceAndWait(ctx context.Context, client *edgecloud.Client, instance *edgecloud.Instance, timeouts ...time.Duration) error {
+	opts := &edgecloud.InstanceDeleteOptions{}
+	for _, volume := range instance.Volumes {
+		if volume.DeleteOnTermination {
+			opts.Volumes = append(opts.Volumes, volume.ID)
+		}
+	}
+
+	task, _, err := client.Instances.Delete(ctx, instance.ID, opts)
+	if err != nil {
+		return err
+	}
+
+	return WaitForTaskComplete(ctx, client, task.Tasks[0], timeouts...)
+}
@@ -32,7 +32,7 @@ func TestWaitForInstanceShutoff(t *testing.T) {
 		_, _ = fmt.Fprint(w, string(resp))
 	})
 
-	expectedRespGet := edgecloud.Instance{ID: testResourceID, Status: InstanceShutoffStatus}
+	expectedRespGet := edgecloud.Instance{ID: testResourceID, Status: edgecloud.InstanceStatusShutoff}
 	URLGet := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
 	mux.HandleFunc(URLGet, func(w http.ResponseWriter, r *http.Request) {
 		resp, err := json.Marshal(expectedRespGet)
@@ -166,3 +166,30 @@ func TestInstanceNetworkInterfaceByID(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, testResourceID2, iface.PortID)
 }
+
+func TestDeleteInstanceAndWait(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URLDelete := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URLDelete, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, testVolumeID, r.URL.Query().Get("volumes"))
+		_, _ = fmt.Fprintf(w, `{"tasks":["%s"]}`, testTaskID)
+	})
+
+	URLTask := path.Join("/v1/tasks", testTaskID)
+	mux.HandleFunc(URLTask, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Task{ID: testTaskID, State: edgecloud.TaskStateFinished})
+	})
+
+	client := newTestClient(server.URL)
+
+	instance := &edgecloud.Instance{
+		ID:      testResourceID,
+		Volumes: []edgecloud.InstanceVolume{{ID: testVolumeID, DeleteOnTermination: true}, {ID: testResourceID3}},
+	}
+	err := DeleteInstanceAndWait(context.Background(), client, instance)
+	require.NoError(t, err)
+}
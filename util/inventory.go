@@ -0,0 +1,409 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	InventoryGroupBaremetal = "baremetal"
+
+	inventoryGroupAll       = "all"
+	inventoryGroupUngrouped = "ungrouped"
+	inventoryHostIDSuffix   = 8
+)
+
+var (
+	inventoryGroupNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	// sshHostNameReplacer matches the characters ssh_config treats as pattern syntax or separators in a Host line.
+	sshHostNameReplacer = regexp.MustCompile(`[\s*?!,"#]+`)
+)
+
+// InventoryOptions specifies the optional parameters to BuildInventory.
+type InventoryOptions struct {
+	// ListOptions filters the listed instances.
+	ListOptions *edgecloud.InstanceListOptions
+	// IncludeBaremetal adds bare metal servers to the inventory and to the baremetal group.
+	IncludeBaremetal bool
+	// GroupByMetadata is the list of metadata keys to group hosts by. Groups are named meta_<key>_<value>.
+	GroupByMetadata []string
+	// GroupByFlavor groups hosts by flavor name into flavor_<name> groups.
+	GroupByFlavor bool
+	// GroupBySecurityGroup groups hosts by security group name into security_group_<name> groups.
+	GroupBySecurityGroup bool
+	// GroupByServerGroup groups hosts by server group name into server_group_<name> groups.
+	GroupByServerGroup bool
+	// User is set as ansible_user and as User in the ssh config.
+	User string
+	// IdentityFiles maps keypair names to private key paths used as IdentityFile in the ssh config.
+	IdentityFiles map[string]string
+}
+
+// InventoryHost is a single host of the inventory.
+type InventoryHost struct {
+	Name           string
+	InstanceID     string
+	Address        net.IP
+	Floating       bool
+	FixedIPs       []net.IP
+	FloatingIPs    []net.IP
+	Flavor         string
+	KeypairName    string
+	Metadata       edgecloud.Metadata
+	SecurityGroups []string
+	ServerGroup    string
+	Status         edgecloud.InstanceStatus
+	Baremetal      bool
+}
+
+// Inventory is a set of hosts and the groups they belong to.
+type Inventory struct {
+	Hosts  []InventoryHost
+	Groups map[string][]string
+
+	opts InventoryOptions
+}
+
+// BuildInventory lists the instances of the project and groups them according to the options.
+func BuildInventory(ctx context.Context, client *edgecloud.Client, opts *InventoryOptions) (*Inventory, error) {
+	if opts == nil {
+		opts = &InventoryOptions{}
+	}
+
+	instances, _, err := client.Instances.List(ctx, opts.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var baremetal []edgecloud.Instance
+	if opts.IncludeBaremetal {
+		baremetal, _, err = client.Instances.BareMetalListInstances(ctx, baremetalListOptions(opts.ListOptions))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var serverGroups []edgecloud.ServerGroup
+	if opts.GroupByServerGroup {
+		serverGroups, _, err = client.ServerGroups.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewInventory(instances, baremetal, serverGroups, opts), nil
+}
+
+// baremetalListOptions applies the instance filters supported by the bare metal list to it.
+func baremetalListOptions(opts *edgecloud.InstanceListOptions) *edgecloud.BareMetalInstancesListOpts {
+	if opts == nil {
+		return &edgecloud.BareMetalInstancesListOpts{}
+	}
+
+	return &edgecloud.BareMetalInstancesListOpts{
+		Name:       opts.Name,
+		FlavorID:   opts.FlavorID,
+		Status:     opts.Status,
+		IP:         opts.IP,
+		UUID:       opts.UUID,
+		MetadataKV: opts.MetadataKV,
+		MetadataK:  opts.MetadataK,
+	}
+}
+
+// NewInventory builds the inventory from already listed instances, bare metal servers and server groups.
+func NewInventory(instances, baremetal []edgecloud.Instance, serverGroups []edgecloud.ServerGroup, opts *InventoryOptions) *Inventory {
+	if opts == nil {
+		opts = &InventoryOptions{}
+	}
+
+	serverGroupByInstance := make(map[string]string)
+	for _, sg := range serverGroups {
+		for _, instance := range sg.Instances {
+			serverGroupByInstance[instance.InstanceID] = sg.Name
+		}
+	}
+
+	inv := &Inventory{Groups: make(map[string][]string), opts: *opts}
+	seen := make(map[string]bool)
+	names := make(map[string]int)
+
+	add := func(instance edgecloud.Instance, isBaremetal bool) {
+		if seen[instance.ID] {
+			return
+		}
+		seen[instance.ID] = true
+		names[instance.Name]++
+
+		host := newInventoryHost(instance, serverGroupByInstance[instance.ID], isBaremetal)
+		inv.Hosts = append(inv.Hosts, host)
+	}
+
+	for _, instance := range instances {
+		add(instance, false)
+	}
+	for _, instance := range baremetal {
+		add(instance, true)
+	}
+
+	// Hosts sharing a name get a short ID suffix; the full ID is used if the short one does not tell them apart.
+	shortNames := make(map[string]string)
+	shortNameCount := make(map[string]int)
+	for _, host := range inv.Hosts {
+		if names[host.Name] > 1 {
+			shortNames[host.InstanceID] = fmt.Sprintf("%s-%s", host.Name, host.InstanceID[:min(len(host.InstanceID), inventoryHostIDSuffix)])
+			shortNameCount[shortNames[host.InstanceID]]++
+		}
+	}
+
+	for i := range inv.Hosts {
+		host := &inv.Hosts[i]
+		if shortName, ok := shortNames[host.InstanceID]; ok {
+			if shortNameCount[shortName] > 1 {
+				host.Name = fmt.Sprintf("%s-%s", host.Name, host.InstanceID)
+			} else {
+				host.Name = shortName
+			}
+		}
+		inv.addHostToGroups(*host)
+	}
+
+	for group := range inv.Groups {
+		sort.Strings(inv.Groups[group])
+	}
+
+	return inv
+}
+
+func newInventoryHost(instance edgecloud.Instance, serverGroup string, isBaremetal bool) InventoryHost {
+	host := InventoryHost{
+		Name:        instance.Name,
+		InstanceID:  instance.ID,
+		KeypairName: instance.KeypairName,
+		Metadata:    instance.Metadata,
+		ServerGroup: serverGroup,
+		Status:      instance.Status,
+		Baremetal:   isBaremetal,
+	}
+
+	if instance.Flavor != nil {
+		host.Flavor = instance.Flavor.FlavorName
+	}
+
+	for _, sg := range instance.SecurityGroups {
+		host.SecurityGroups = append(host.SecurityGroups, sg.Name)
+	}
+
+	for _, addr := range instanceAddresses(instance) {
+		if addr.Type == string(edgecloud.AddressTypeFloating) {
+			host.FloatingIPs = append(host.FloatingIPs, addr.Address)
+		} else {
+			host.FixedIPs = append(host.FixedIPs, addr.Address)
+		}
+	}
+
+	host.Address, host.Floating = InstanceAccessAddress(instance)
+
+	return host
+}
+
+// instanceAddresses returns the addresses of the instance ordered by network name.
+func instanceAddresses(instance edgecloud.Instance) []edgecloud.InstanceAddress {
+	networks := make([]string, 0, len(instance.Addresses))
+	for network := range instance.Addresses {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	var addresses []edgecloud.InstanceAddress
+	for _, network := range networks {
+		addresses = append(addresses, instance.Addresses[network]...)
+	}
+
+	return addresses
+}
+
+// InstanceAccessAddress returns the address to reach the instance at. IPv4 addresses are preferred over IPv6 ones,
+// and within an IP version a floating IP is preferred over a fixed one. The second return value reports whether
+// the address is floating.
+func InstanceAccessAddress(instance edgecloud.Instance) (net.IP, bool) {
+	var fixed, fixedV6, floatingV6 net.IP
+	for _, addr := range instanceAddresses(instance) {
+		if addr.Address == nil {
+			continue
+		}
+
+		isV4 := addr.Address.To4() != nil
+		switch {
+		case addr.Type == string(edgecloud.AddressTypeFloating) && isV4:
+			return addr.Address, true
+		case addr.Type == string(edgecloud.AddressTypeFloating) && floatingV6 == nil:
+			floatingV6 = addr.Address
+		case addr.Type != string(edgecloud.AddressTypeFloating) && isV4 && fixed == nil:
+			fixed = addr.Address
+		case addr.Type != string(edgecloud.AddressTypeFloating) && !isV4 && fixedV6 == nil:
+			fixedV6 = addr.Address
+		}
+	}
+
+	switch {
+	case fixed != nil:
+		return fixed, false
+	case floatingV6 != nil:
+		return floatingV6, true
+	default:
+		return fixedV6, false
+	}
+}
+
+func inventoryGroupName(parts ...string) string {
+	return inventoryGroupNameReplacer.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+func (inv *Inventory) addHostToGroups(host InventoryHost) {
+	var groups []string
+
+	for _, key := range inv.opts.GroupByMetadata {
+		if value, ok := host.Metadata[key]; ok {
+			groups = append(groups, inventoryGroupName("meta", key, value))
+		}
+	}
+
+	if inv.opts.GroupByFlavor && host.Flavor != "" {
+		groups = append(groups, inventoryGroupName("flavor", host.Flavor))
+	}
+
+	if inv.opts.GroupBySecurityGroup {
+		for _, sg := range host.SecurityGroups {
+			groups = append(groups, inventoryGroupName("security_group", sg))
+		}
+	}
+
+	if inv.opts.GroupByServerGroup && host.ServerGroup != "" {
+		groups = append(groups, inventoryGroupName("server_group", host.ServerGroup))
+	}
+
+	if host.Baremetal {
+		groups = append(groups, InventoryGroupBaremetal)
+	}
+
+	if len(groups) == 0 {
+		groups = append(groups, inventoryGroupUngrouped)
+	}
+
+	for _, group := range groups {
+		inv.Groups[group] = append(inv.Groups[group], host.Name)
+	}
+}
+
+func ipsToStrings(ips []net.IP) []string {
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+
+	return result
+}
+
+func (inv *Inventory) hostVars(host InventoryHost) map[string]interface{} {
+	vars := map[string]interface{}{
+		"edgecloud_instance_id":     host.InstanceID,
+		"edgecloud_flavor":          host.Flavor,
+		"edgecloud_status":          host.Status,
+		"edgecloud_baremetal":       host.Baremetal,
+		"edgecloud_fixed_ips":       ipsToStrings(host.FixedIPs),
+		"edgecloud_floating_ips":    ipsToStrings(host.FloatingIPs),
+		"edgecloud_security_groups": host.SecurityGroups,
+		"edgecloud_metadata":        host.Metadata,
+	}
+
+	if host.Address != nil {
+		vars["ansible_host"] = host.Address.String()
+	}
+	if inv.opts.User != "" {
+		vars["ansible_user"] = inv.opts.User
+	}
+	if host.KeypairName != "" {
+		vars["edgecloud_keypair"] = host.KeypairName
+		if identityFile, ok := inv.opts.IdentityFiles[host.KeypairName]; ok {
+			vars["ansible_ssh_private_key_file"] = identityFile
+		}
+	}
+	if host.ServerGroup != "" {
+		vars["edgecloud_server_group"] = host.ServerGroup
+	}
+
+	return vars
+}
+
+// AnsibleJSON returns the inventory in the Ansible dynamic inventory JSON format.
+func (inv *Inventory) AnsibleJSON() ([]byte, error) {
+	hostVars := make(map[string]interface{}, len(inv.Hosts))
+	for _, host := range inv.Hosts {
+		hostVars[host.Name] = inv.hostVars(host)
+	}
+
+	groupNames := make([]string, 0, len(inv.Groups))
+	for group := range inv.Groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
+	result := map[string]interface{}{
+		"_meta":           map[string]interface{}{"hostvars": hostVars},
+		inventoryGroupAll: map[string]interface{}{"children": groupNames},
+	}
+	for group, hosts := range inv.Groups {
+		result[group] = map[string]interface{}{"hosts": hosts}
+	}
+
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// WriteAnsible writes the inventory in the Ansible dynamic inventory JSON format.
+func (inv *Inventory) WriteAnsible(w io.Writer) error {
+	data, err := inv.AnsibleJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+
+	return err
+}
+
+// WriteSSHConfig writes an ssh_config entry for every host with an address. Whitespace and pattern characters
+// in host names are replaced with dashes.
+func (inv *Inventory) WriteSSHConfig(w io.Writer) error {
+	for _, host := range inv.Hosts {
+		if host.Address == nil {
+			continue
+		}
+
+		lines := []string{
+			fmt.Sprintf("# %s", host.InstanceID),
+			fmt.Sprintf("Host %s", sshHostNameReplacer.ReplaceAllString(host.Name, "-")),
+			fmt.Sprintf("    HostName %s", host.Address),
+		}
+		if inv.opts.User != "" {
+			lines = append(lines, fmt.Sprintf("    User %s", inv.opts.User))
+		}
+		if identityFile, ok := inv.opts.IdentityFiles[host.KeypairName]; ok && host.KeypairName != "" {
+			lines = append(lines, fmt.Sprintf("    IdentityFile %s", identityFile), "    IdentitiesOnly yes")
+		}
+
+		if _, err := fmt.Fprintf(w, "%s\n\n", strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+func testInventoryInstances() []edgecloud.Instance {
+	return []edgecloud.Instance{
+		{
+			ID:   testResourceID,
+			Name: "web-1",
+			Addresses: map[string][]edgecloud.InstanceAddress{
+				"private": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.0.5")}},
+				"public":  {{Type: string(edgecloud.AddressTypeFloating), Address: net.ParseIP("203.0.113.10")}},
+			},
+			Flavor:         &edgecloud.Flavor{FlavorName: "g1-standard-2-4"},
+			KeypairName:    "deploy",
+			Metadata:       edgecloud.Metadata{"role": "web", "env": "prod"},
+			SecurityGroups: []edgecloud.Name{{Name: "default"}},
+		},
+		{
+			ID:   testInstanceID2,
+			Name: "db-1",
+			Addresses: map[string][]edgecloud.InstanceAddress{
+				"private": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.0.6")}},
+			},
+			Flavor:   &edgecloud.Flavor{FlavorName: "g1-standard-4-8"},
+			Metadata: edgecloud.Metadata{"role": "db.primary"},
+		},
+	}
+}
+
+func TestInstanceAccessAddress(t *testing.T) {
+	instances := testInventoryInstances()
+
+	addr, floating := InstanceAccessAddress(instances[0])
+	assert.Equal(t, "203.0.113.10", addr.String())
+	assert.True(t, floating)
+
+	addr, floating = InstanceAccessAddress(instances[1])
+	assert.Equal(t, "10.0.0.6", addr.String())
+	assert.False(t, floating)
+
+	addr, floating = InstanceAccessAddress(edgecloud.Instance{Addresses: map[string][]edgecloud.InstanceAddress{
+		"private": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.0.8")}},
+		"public":  {{Type: string(edgecloud.AddressTypeFloating), Address: net.ParseIP("2001:db8::10")}},
+	}})
+	assert.Equal(t, "10.0.0.8", addr.String())
+	assert.False(t, floating)
+
+	addr, _ = InstanceAccessAddress(edgecloud.Instance{})
+	assert.Nil(t, addr)
+}
+
+func TestBuildInventory(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	instances := testInventoryInstances()
+	baremetal := []edgecloud.Instance{
+		{
+			ID:   testResourceID3,
+			Name: "web-1",
+			Addresses: map[string][]edgecloud.InstanceAddress{
+				"private": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.0.7")}},
+			},
+		},
+	}
+	sgs := []edgecloud.ServerGroup{{Name: "db-anti", Instances: []edgecloud.ServerGroupInstance{{InstanceID: testInstanceID2}}}}
+
+	for URL, items := range map[string]interface{}{
+		path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID)):    instances,
+		path.Join("/v1/bminstances", strconv.Itoa(projectID), strconv.Itoa(regionID)):  baremetal,
+		path.Join("/v1/servergroups", strconv.Itoa(projectID), strconv.Itoa(regionID)): sgs,
+	} {
+		items := items
+		mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+			resp, err := json.Marshal(items)
+			if err != nil {
+				t.Fatalf("failed to marshal JSON: %v", err)
+			}
+			_, _ = fmt.Fprintf(w, `{"results":%s}`, string(resp))
+		})
+	}
+
+	client := newTestClient(server.URL)
+
+	inv, err := BuildInventory(context.Background(), client, &InventoryOptions{
+		IncludeBaremetal:     true,
+		GroupByMetadata:      []string{"role"},
+		GroupByFlavor:        true,
+		GroupBySecurityGroup: true,
+		GroupByServerGroup:   true,
+		User:                 "ubuntu",
+		IdentityFiles:        map[string]string{"deploy": "~/.ssh/deploy"},
+	})
+	require.NoError(t, err)
+	require.Len(t, inv.Hosts, 3)
+
+	assert.Equal(t, []string{"web-1-f0d19cec"}, inv.Groups["meta_role_web"])
+	assert.Equal(t, []string{"db-1"}, inv.Groups["meta_role_db_primary"])
+	assert.Equal(t, []string{"db-1"}, inv.Groups["flavor_g1_standard_4_8"])
+	assert.Equal(t, []string{"web-1-f0d19cec"}, inv.Groups["security_group_default"])
+	assert.Equal(t, []string{"db-1"}, inv.Groups["server_group_db_anti"])
+	assert.Equal(t, []string{"web-1-2b1a7c3e"}, inv.Groups[InventoryGroupBaremetal])
+
+	data, err := inv.AnsibleJSON()
+	require.NoError(t, err)
+
+	var ansible struct {
+		Meta struct {
+			HostVars map[string]map[string]interface{} `json:"hostvars"`
+		} `json:"_meta"`
+		All struct {
+			Children []string `json:"children"`
+		} `json:"all"`
+	}
+	require.NoError(t, json.Unmarshal(data, &ansible))
+	assert.Equal(t, "203.0.113.10", ansible.Meta.HostVars["web-1-f0d19cec"]["ansible_host"])
+	assert.Equal(t, "~/.ssh/deploy", ansible.Meta.HostVars["web-1-f0d19cec"]["ansible_ssh_private_key_file"])
+	assert.Equal(t, "10.0.0.6", ansible.Meta.HostVars["db-1"]["ansible_host"])
+	assert.Equal(t, "ubuntu", ansible.Meta.HostVars["db-1"]["ansible_user"])
+	assert.Contains(t, ansible.All.Children, InventoryGroupBaremetal)
+
+	var buf bytes.Buffer
+	require.NoError(t, inv.WriteSSHConfig(&buf))
+	assert.Contains(t, buf.String(), "Host web-1-f0d19cec\n    HostName 203.0.113.10\n    User ubuntu\n    IdentityFile ~/.ssh/deploy\n    IdentitiesOnly yes\n")
+	assert.Contains(t, buf.String(), "Host db-1\n    HostName 10.0.0.6\n    User ubuntu\n\n")
+}
+
+func TestBuildInventory_HostNames(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	address := map[string][]edgecloud.InstanceAddress{
+		"private": {{Type: string(edgecloud.AddressTypeFixed), Address: net.ParseIP("10.0.0.5")}},
+	}
+	instances := []edgecloud.Instance{
+		{ID: "1", Name: "app", Addresses: address},
+		{ID: "2", Name: "app", Addresses: address},
+		{ID: "12345678-a", Name: "worker", Addresses: address},
+		{ID: "12345678-b", Name: "worker", Addresses: address},
+		{ID: testResourceID, Name: "my app*", Addresses: address},
+	}
+
+	URL := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := json.Marshal(instances)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprintf(w, `{"results":%s}`, string(resp))
+	})
+
+	client := newTestClient(server.URL)
+
+	inv, err := BuildInventory(context.Background(), client, nil)
+	require.NoError(t, err)
+
+	var names []string
+	for _, host := range inv.Hosts {
+		names = append(names, host.Name)
+	}
+	assert.Equal(t, []string{"app-1", "app-2", "worker-12345678-a", "worker-12345678-b", "my app*"}, names)
+
+	var buf bytes.Buffer
+	require.NoError(t, inv.WriteSSHConfig(&buf))
+	assert.Contains(t, buf.String(), "Host my-app-\n")
+	assert.NotContains(t, buf.String(), "Host my app")
+}
+
+func TestBuildInventory_Error(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	URL := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	client := newTestClient(server.URL)
+
+	inv, err := BuildInventory(context.Background(), client, nil)
+	assert.Error(t, err)
+	assert.Nil(t, inv)
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/shopspring/decimal"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// LifeCyclePolicyScheduleCost is the worst case usage of a schedule of a lifecycle policy.
+type LifeCyclePolicyScheduleCost struct {
+	PolicyID     int                                   `json:"policy_id"`
+	PolicyName   string                                `json:"policy_name"`
+	ScheduleID   string                                `json:"schedule_id"`
+	ScheduleType edgecloud.LifeCyclePolicyScheduleType `json:"schedule_type"`
+	VolumeIDs    []string                              `json:"volume_ids"`
+	// Usage is nil if the policy has no volumes.
+	Usage *edgecloud.LifeCyclePolicyMaxPolicyUsage `json:"usage"`
+}
+
+// LifeCyclePolicyCostTotal is the sum of the worst case usage of the schedules priced in a currency.
+type LifeCyclePolicyCostTotal struct {
+	// Currency is the currency code, empty if the API did not report one.
+	Currency      string          `json:"currency"`
+	SnapshotCount int             `json:"max_volume_snapshot_count"`
+	SnapshotSize  int             `json:"max_volume_snapshot_size"`
+	PricePerHour  decimal.Decimal `json:"price_per_hour"`
+	PricePerMonth decimal.Decimal `json:"price_per_month"`
+}
+
+// LifeCyclePolicyCostReport is the worst case cost of the lifecycle policies of a project.
+type LifeCyclePolicyCostReport struct {
+	Schedules []LifeCyclePolicyScheduleCost `json:"schedules"`
+	// Totals are ordered by currency.
+	Totals []LifeCyclePolicyCostTotal `json:"totals"`
+}
+
+// BuildLifeCyclePolicyCostReport estimates every schedule of every lifecycle policy of the client project and
+// region with the volumes of its policy, and sums the maximum snapshot count, size and price by currency.
+// Paused policies are included, as they can be resumed at any time. Schedules that fail to be estimated are
+// left out of the totals and the errors are joined.
+func BuildLifeCyclePolicyCostReport(ctx context.Context, client *edgecloud.Client) (*LifeCyclePolicyCostReport, error) {
+	policies, _, err := client.LifeCyclePolicies.List(ctx, &edgecloud.LifeCyclePolicyListOptions{NeedVolumes: true})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LifeCyclePolicyCostReport{}
+	var errs []error
+	for _, policy := range policies {
+		volumeIDs := make([]string, 0, len(policy.Volumes))
+		for _, volume := range policy.Volumes {
+			volumeIDs = append(volumeIDs, volume.ID)
+		}
+
+		for _, schedule := range policy.Schedules {
+			common := schedule.GetCommonSchedule()
+			cost := LifeCyclePolicyScheduleCost{
+				PolicyID:     policy.ID,
+				PolicyName:   policy.Name,
+				ScheduleID:   common.ID,
+				ScheduleType: common.Type,
+				VolumeIDs:    volumeIDs,
+			}
+
+			if len(volumeIDs) > 0 {
+				cost.Usage, err = estimateLifeCyclePolicySchedule(ctx, client, policy, volumeIDs, schedule)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("policy %d, schedule %s: %w", policy.ID, common.ID, err))
+					continue
+				}
+			}
+
+			report.Schedules = append(report.Schedules, cost)
+		}
+	}
+
+	report.Totals = lifeCyclePolicyCostTotals(report.Schedules)
+
+	return report, errors.Join(errs...)
+}
+
+func estimateLifeCyclePolicySchedule(ctx context.Context, client *edgecloud.Client, policy edgecloud.LifeCyclePolicy, volumeIDs []string, schedule edgecloud.LifeCyclePolicySchedule) (
+	*edgecloud.LifeCyclePolicyMaxPolicyUsage, error,
+) {
+	opts := edgecloud.LifeCyclePolicyEstimateOpts{
+		Name:      policy.Name,
+		VolumeIds: volumeIDs,
+		Status:    policy.Status,
+		Action:    policy.Action,
+	}
+
+	common := schedule.GetCommonSchedule()
+	commonReq := edgecloud.LifeCyclePolicyCommonCreateScheduleRequest{
+		Type:                 common.Type,
+		ResourceNameTemplate: common.ResourceNameTemplate,
+		MaxQuantity:          common.MaxQuantity,
+		RetentionTime:        common.RetentionTime,
+	}
+
+	var usage *edgecloud.LifeCyclePolicyMaxPolicyUsage
+	var err error
+	switch s := schedule.(type) {
+	case edgecloud.LifeCyclePolicyCronSchedule:
+		req := &edgecloud.LifeCyclePolicyCreateCronScheduleRequest{
+			LifeCyclePolicyCommonCreateScheduleRequest: commonReq,
+			Timezone:  s.Timezone,
+			Week:      s.Week,
+			DayOfWeek: s.DayOfWeek,
+			Month:     s.Month,
+			Day:       s.Day,
+			Hour:      s.Hour,
+			Minute:    s.Minute,
+		}
+		usage, _, err = client.LifeCyclePolicies.EstimateCronMaxPolicyUsage(ctx, &edgecloud.LifeCyclePolicyEstimateCronRequest{
+			LifeCyclePolicyEstimateOpts: opts,
+			Schedules:                   []edgecloud.LifeCyclePolicyCreateScheduleRequest{req},
+		})
+	case edgecloud.LifeCyclePolicyIntervalSchedule:
+		req := edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest{
+			LifeCyclePolicyCommonCreateScheduleRequest: commonReq,
+			Weeks:   s.Weeks,
+			Days:    s.Days,
+			Hours:   s.Hours,
+			Minutes: s.Minutes,
+		}
+		usage, _, err = client.LifeCyclePolicies.EstimateIntervalMaxPolicyUsage(ctx, &edgecloud.LifeCyclePolicyEstimateIntervalRequest{
+			LifeCyclePolicyEstimateOpts: opts,
+			Schedules:                   []edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest{req},
+		})
+	default:
+		err = fmt.Errorf("%w: %T", ErrLifeCyclePolicyScheduleUnknown, schedule)
+	}
+
+	return usage, err
+}
+
+func lifeCyclePolicyCostTotals(schedules []LifeCyclePolicyScheduleCost) []LifeCyclePolicyCostTotal {
+	byCurrency := make(map[string]*LifeCyclePolicyCostTotal)
+	for _, cost := range schedules {
+		if cost.Usage == nil {
+			continue
+		}
+
+		code := lifeCyclePolicyCurrencyCode(cost.Usage.MaxCost.CurrencyCode)
+		total, ok := byCurrency[code]
+		if !ok {
+			total = &LifeCyclePolicyCostTotal{Currency: code}
+			byCurrency[code] = total
+		}
+
+		total.SnapshotCount += cost.Usage.CountUsage
+		total.SnapshotSize += cost.Usage.SizeUsage
+		total.PricePerHour = total.PricePerHour.Add(cost.Usage.MaxCost.PricePerHour)
+		total.PricePerMonth = total.PricePerMonth.Add(cost.Usage.MaxCost.PricePerMonth)
+	}
+
+	totals := make([]LifeCyclePolicyCostTotal, 0, len(byCurrency))
+	for _, code := range sortedKeys(byCurrency) {
+		totals = append(totals, *byCurrency[code])
+	}
+
+	return totals
+}
+
+func lifeCyclePolicyCurrencyCode(c edgecloud.LifeCyclePolicyCurrency) string {
+	if c.Currency == nil {
+		return ""
+	}
+
+	return c.String()
+}
+
+// WriteTable writes a row per schedule followed by the totals by currency.
+func (r *LifeCyclePolicyCostReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "POLICY\tSCHEDULE\tTYPE\tVOLUMES\tSNAPSHOTS\tSIZE\tPER HOUR\tPER MONTH\tCURRENCY")
+	for _, cost := range r.Schedules {
+		usage := cost.Usage
+		if usage == nil {
+			usage = &edgecloud.LifeCyclePolicyMaxPolicyUsage{}
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s (%d)\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			cost.PolicyName, cost.PolicyID, cost.ScheduleID, cost.ScheduleType, len(cost.VolumeIDs),
+			usage.CountUsage, usage.SizeUsage, usage.MaxCost.PricePerHour, usage.MaxCost.PricePerMonth,
+			lifeCyclePolicyCurrencyCode(usage.MaxCost.CurrencyCode))
+	}
+
+	for _, total := range r.Totals {
+		_, _ = fmt.Fprintf(tw, "TOTAL\t\t\t\t%d\t%d\t%s\t%s\t%s\n",
+			total.SnapshotCount, total.SnapshotSize, total.PricePerHour, total.PricePerMonth, total.Currency)
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *LifeCyclePolicyCostReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
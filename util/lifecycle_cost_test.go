@@ -0,0 +1,93 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildLifeCyclePolicyCostReport(t *testing.T) {
+	base := path.Join("/v1/lifecycle_policies", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	policies := []map[string]interface{}{
+		{
+			"id":      1,
+			"name":    "daily",
+			"action":  "volume_snapshot",
+			"status":  "active",
+			"volumes": []map[string]string{{"volume_id": testVolumeID}},
+			"schedules": []map[string]interface{}{
+				{"type": "cron", "id": "cron-id", "max_quantity": 7, "hour": "3"},
+				{"type": "interval", "id": "interval-id", "max_quantity": 4, "hours": 6},
+			},
+		},
+		{
+			"id":        2,
+			"name":      "empty",
+			"action":    "volume_snapshot",
+			"status":    "paused",
+			"schedules": []map[string]interface{}{{"type": "interval", "id": "unused-id", "max_quantity": 1, "days": 1}},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(base, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("need_volumes"))
+		writeTestJSON(t, w, map[string]interface{}{"count": len(policies), "results": policies})
+	})
+	mux.HandleFunc(path.Join(base, "estimate_max_policy_usage"), func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name      string                   `json:"name"`
+			VolumeIDs []string                 `json:"volume_ids"`
+			Schedules []map[string]interface{} `json:"schedules"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "daily", req.Name)
+		assert.Equal(t, []string{testVolumeID}, req.VolumeIDs)
+		require.Len(t, req.Schedules, 1)
+
+		if req.Schedules[0]["type"] == "cron" {
+			assert.Equal(t, "3", req.Schedules[0]["hour"])
+			_, _ = w.Write([]byte(`{"max_volume_snapshot_count_usage":7,"max_volume_snapshot_size_usage":70,` +
+				`"max_cost":{"currency_code":"RUB","price_per_hour":"0.5","price_per_month":"360"}}`))
+			return
+		}
+		assert.InDelta(t, 6, req.Schedules[0]["hours"], 0)
+		_, _ = w.Write([]byte(`{"max_volume_snapshot_count_usage":4,"max_volume_snapshot_size_usage":40,` +
+			`"max_cost":{"currency_code":"RUB","price_per_hour":"0.25","price_per_month":"180"}}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	report, err := BuildLifeCyclePolicyCostReport(context.Background(), newTestClient(server.URL))
+	require.NoError(t, err)
+	require.Len(t, report.Schedules, 3)
+	assert.Nil(t, report.Schedules[2].Usage)
+
+	require.Len(t, report.Totals, 1)
+	total := report.Totals[0]
+	assert.Equal(t, "RUB", total.Currency)
+	assert.Equal(t, 11, total.SnapshotCount)
+	assert.Equal(t, 110, total.SnapshotSize)
+	assert.Equal(t, "0.75", total.PricePerHour.String())
+	assert.Equal(t, "540", total.PricePerMonth.String())
+
+	var table bytes.Buffer
+	require.NoError(t, report.WriteTable(&table))
+	assert.Contains(t, table.String(), "daily (1)  cron-id")
+	assert.Regexp(t, `TOTAL\s+11\s+110\s+0.75\s+540\s+RUB`, table.String())
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteJSON(&out))
+	var decoded LifeCyclePolicyCostReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, report.Totals, decoded.Totals)
+}
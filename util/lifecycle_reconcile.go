@@ -0,0 +1,330 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var (
+	ErrLifeCyclePolicyAmbiguous    = errors.New("several lifecycle policies have the name")
+	ErrLifeCyclePolicyActionChange = errors.New("the action of a lifecycle policy cannot be changed")
+)
+
+// LifeCyclePolicySpec is the desired state of a lifecycle policy, identified by its name.
+type LifeCyclePolicySpec struct {
+	Name string
+	// Status is active if empty.
+	Status edgecloud.LifeCyclePolicyStatus
+	// Action is volume_snapshot if empty.
+	Action    edgecloud.LifeCyclePolicyAction
+	Schedules []edgecloud.LifeCyclePolicyCreateScheduleRequest
+	// VolumeSelector selects the volumes of the policy, the ones with all the metadata key-value pairs.
+	// The volumes of the policy are left as they are if nil. An empty non-nil selector is refused, it would
+	// select every volume of the project.
+	VolumeSelector edgecloud.Metadata
+}
+
+// LifeCyclePolicyDiff is the set of changes turning the existing policy into the desired one.
+type LifeCyclePolicyDiff struct {
+	// PolicyID is the ID of the policy, 0 if it is to be created.
+	PolicyID int
+	// Create is true if no policy has the name.
+	Create bool
+	// Status is the new status, empty if unchanged.
+	Status edgecloud.LifeCyclePolicyStatus
+	// AddSchedules are the desired schedules without a matching existing schedule.
+	AddSchedules []edgecloud.LifeCyclePolicyCreateScheduleRequest
+	// RemoveScheduleIDs are the existing schedules without a matching desired schedule.
+	RemoveScheduleIDs []string
+	AddVolumeIDs      []string
+	RemoveVolumeIDs   []string
+}
+
+// Empty reports whether the policy is already in the desired state.
+func (d *LifeCyclePolicyDiff) Empty() bool {
+	return !d.Create && d.Status == "" && len(d.AddSchedules) == 0 && len(d.RemoveScheduleIDs) == 0 &&
+		len(d.AddVolumeIDs) == 0 && len(d.RemoveVolumeIDs) == 0
+}
+
+// PlanLifeCyclePolicy compares the desired policy with the existing policy of the same name and returns the
+// changes without applying them. Schedules are matched semantically: a cron schedule matches if it fires at
+// the same times in the same timezone, an interval schedule if its interval is the same, and both must keep
+// the same quantity for the same retention time. The server-assigned IDs are ignored, and so is the resource
+// name template unless the desired schedule sets it.
+func PlanLifeCyclePolicy(ctx context.Context, client *edgecloud.Client, desired *LifeCyclePolicySpec) (*LifeCyclePolicyDiff, error) {
+	if desired == nil {
+		return nil, edgecloud.NewArgError("desired", "cannot be nil")
+	}
+	if desired.VolumeSelector != nil && len(desired.VolumeSelector) == 0 {
+		return nil, edgecloud.NewArgError("desired.VolumeSelector", "cannot be empty, use nil to leave the volumes unchanged")
+	}
+
+	action := desired.Action
+	if action == "" {
+		action = edgecloud.LifeCyclePolicyActionVolumeSnapshot
+	}
+	status := desired.Status
+	if status == "" {
+		status = edgecloud.LifeCyclePolicyStatusActive
+	}
+
+	existing, err := lifeCyclePolicyByName(ctx, client, desired.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var volumeIDs []string
+	if desired.VolumeSelector != nil {
+		if volumeIDs, err = selectVolumeIDs(ctx, client, desired.VolumeSelector); err != nil {
+			return nil, err
+		}
+	}
+
+	if existing == nil {
+		return &LifeCyclePolicyDiff{Create: true, Status: status, AddSchedules: desired.Schedules, AddVolumeIDs: volumeIDs}, nil
+	}
+
+	if existing.Action != action {
+		return nil, fmt.Errorf("%w: policy %d has %s, not %s", ErrLifeCyclePolicyActionChange, existing.ID, existing.Action, action)
+	}
+
+	diff := &LifeCyclePolicyDiff{PolicyID: existing.ID}
+	if existing.Status != status {
+		diff.Status = status
+	}
+
+	if diff.AddSchedules, diff.RemoveScheduleIDs, err = diffLifeCyclePolicySchedules(existing.Schedules, desired.Schedules); err != nil {
+		return nil, err
+	}
+
+	if desired.VolumeSelector != nil {
+		current := make([]string, 0, len(existing.Volumes))
+		for _, volume := range existing.Volumes {
+			current = append(current, volume.ID)
+		}
+		diff.AddVolumeIDs, diff.RemoveVolumeIDs = diffIDs(current, volumeIDs)
+	}
+
+	return diff, nil
+}
+
+// ReconcileLifeCyclePolicy brings the policy with the desired name to the desired state with the fewest calls:
+// it creates the policy if it does not exist, otherwise it updates the status, adds the missing schedules
+// before removing the extra ones, and adds and removes volumes. It returns the applied changes.
+func ReconcileLifeCyclePolicy(ctx context.Context, client *edgecloud.Client, desired *LifeCyclePolicySpec) (*LifeCyclePolicyDiff, error) {
+	diff, err := PlanLifeCyclePolicy(ctx, client, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if diff.Create {
+		action := desired.Action
+		if action == "" {
+			action = edgecloud.LifeCyclePolicyActionVolumeSnapshot
+		}
+
+		policy, _, err := client.LifeCyclePolicies.Create(ctx, &edgecloud.LifeCyclePolicyCreateRequest{
+			Name:      desired.Name,
+			Status:    diff.Status,
+			Action:    action,
+			Schedules: diff.AddSchedules,
+			VolumeIds: diff.AddVolumeIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		diff.PolicyID = policy.ID
+
+		return diff, nil
+	}
+
+	if diff.Status != "" {
+		if _, _, err = client.LifeCyclePolicies.Update(ctx, diff.PolicyID, &edgecloud.LifeCyclePolicyUpdateRequest{Name: desired.Name, Status: diff.Status}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(diff.AddSchedules) > 0 {
+		if _, _, err = client.LifeCyclePolicies.AddSchedules(ctx, diff.PolicyID, &edgecloud.LifeCyclePolicyAddSchedulesRequest{Schedules: diff.AddSchedules}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(diff.RemoveScheduleIDs) > 0 {
+		if _, _, err = client.LifeCyclePolicies.RemoveSchedules(ctx, diff.PolicyID, &edgecloud.LifeCyclePolicyRemoveSchedulesRequest{ScheduleIDs: diff.RemoveScheduleIDs}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(diff.AddVolumeIDs) > 0 {
+		if _, _, err = client.LifeCyclePolicies.AddVolumes(ctx, diff.PolicyID, &edgecloud.LifeCyclePolicyAddVolumesRequest{VolumeIds: diff.AddVolumeIDs}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(diff.RemoveVolumeIDs) > 0 {
+		if _, _, err = client.LifeCyclePolicies.RemoveVolumes(ctx, diff.PolicyID, &edgecloud.LifeCyclePolicyRemoveVolumesRequest{VolumeIds: diff.RemoveVolumeIDs}); err != nil {
+			return nil, err
+		}
+	}
+
+	return diff, nil
+}
+
+// lifeCyclePolicyByName returns the policy with the name and its volumes, or nil if there is none.
+func lifeCyclePolicyByName(ctx context.Context, client *edgecloud.Client, name string) (*edgecloud.LifeCyclePolicy, error) {
+	policies, _, err := client.LifeCyclePolicies.List(ctx, &edgecloud.LifeCyclePolicyListOptions{NeedVolumes: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var found *edgecloud.LifeCyclePolicy
+	for i := range policies {
+		if policies[i].Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: %s", ErrLifeCyclePolicyAmbiguous, name)
+		}
+		found = &policies[i]
+	}
+
+	return found, nil
+}
+
+// selectVolumeIDs returns the sorted IDs of the volumes with all the metadata key-value pairs.
+func selectVolumeIDs(ctx context.Context, client *edgecloud.Client, selector edgecloud.Metadata) ([]string, error) {
+	metadataKV, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, _, err := client.Volumes.List(ctx, &edgecloud.VolumeListOptions{MetadataKV: string(metadataKV)})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		matches := true
+		for k, v := range selector {
+			if volume.Metadata[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			ids = append(ids, volume.ID)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// diffLifeCyclePolicySchedules pairs every desired schedule with a different matching existing schedule.
+func diffLifeCyclePolicySchedules(existing []edgecloud.LifeCyclePolicySchedule, desired []edgecloud.LifeCyclePolicyCreateScheduleRequest) (
+	[]edgecloud.LifeCyclePolicyCreateScheduleRequest, []string, error,
+) {
+	matched := make([]bool, len(existing))
+	var add []edgecloud.LifeCyclePolicyCreateScheduleRequest
+
+	for _, req := range desired {
+		found := false
+		for i, schedule := range existing {
+			if matched[i] {
+				continue
+			}
+
+			ok, err := lifeCyclePolicyScheduleMatches(schedule, req)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				matched[i], found = true, true
+				break
+			}
+		}
+
+		if !found {
+			add = append(add, req)
+		}
+	}
+
+	var remove []string
+	for i, schedule := range existing {
+		if !matched[i] {
+			remove = append(remove, schedule.GetCommonSchedule().ID)
+		}
+	}
+
+	return add, remove, nil
+}
+
+// lifeCyclePolicyScheduleMatches reports whether the existing schedule is semantically the desired one.
+func lifeCyclePolicyScheduleMatches(schedule edgecloud.LifeCyclePolicySchedule, req edgecloud.LifeCyclePolicyCreateScheduleRequest) (bool, error) {
+	have, err := NewLifeCyclePolicyScheduleEvaluator(schedule)
+	if err != nil {
+		return false, err
+	}
+
+	want, err := NewLifeCyclePolicyScheduleRequestEvaluator(req)
+	if err != nil {
+		return false, err
+	}
+
+	if have.Type != want.Type || have.Interval != want.Interval || have.MaxQuantity != want.MaxQuantity ||
+		have.RetentionTime != want.RetentionTime {
+		return false, nil
+	}
+
+	if have.cron != nil && !have.cron.Equal(want.cron) {
+		return false, nil
+	}
+
+	template := createScheduleCommon(req).ResourceNameTemplate
+
+	return template == "" || template == schedule.GetCommonSchedule().ResourceNameTemplate, nil
+}
+
+func createScheduleCommon(req edgecloud.LifeCyclePolicyCreateScheduleRequest) edgecloud.LifeCyclePolicyCommonCreateScheduleRequest {
+	switch r := req.(type) {
+	case *edgecloud.LifeCyclePolicyCreateCronScheduleRequest:
+		return r.LifeCyclePolicyCommonCreateScheduleRequest
+	case *edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest:
+		return r.LifeCyclePolicyCommonCreateScheduleRequest
+	default:
+		return edgecloud.LifeCyclePolicyCommonCreateScheduleRequest{}
+	}
+}
+
+// diffIDs returns the desired IDs missing from the current ones and the current IDs that are not desired.
+func diffIDs(current, desired []string) ([]string, []string) {
+	currentSet := make(map[string]bool, len(current))
+	for _, id := range current {
+		currentSet[id] = true
+	}
+
+	desiredSet := make(map[string]bool, len(desired))
+	var add []string
+	for _, id := range desired {
+		desiredSet[id] = true
+		if !currentSet[id] {
+			add = append(add, id)
+		}
+	}
+
+	var remove []string
+	for _, id := range current {
+		if !desiredSet[id] {
+			remove = append(remove, id)
+		}
+	}
+
+	return add, remove
+}
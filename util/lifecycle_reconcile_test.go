@@ -0,0 +1,166 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const testLifeCyclePolicyName = "backups"
+
+type fakeLifeCyclePoliciesAPI struct {
+	mu       sync.Mutex
+	policies []map[string]interface{}
+	volumes  []edgecloud.Volume
+	calls    []string
+	bodies   map[string]map[string]interface{}
+}
+
+func (f *fakeLifeCyclePoliciesAPI) handler(t *testing.T) http.Handler {
+	t.Helper()
+	base := path.Join("/v1/lifecycle_policies", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	policy := map[string]interface{}{"id": 999, "name": testLifeCyclePolicyName, "schedules": []interface{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(base, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeTestJSON(t, w, map[string]interface{}{"count": len(f.policies), "results": f.policies})
+			return
+		}
+		f.record(t, r, "create")
+		writeTestJSON(t, w, policy)
+	})
+	for _, action := range []string{"", "add_schedules", "remove_schedules", "add_volumes_to_policy", "remove_volumes_from_policy"} {
+		name := action
+		if name == "" {
+			name = "update"
+		}
+		mux.HandleFunc(path.Join(base, "999", action), func(w http.ResponseWriter, r *http.Request) {
+			f.record(t, r, name)
+			writeTestJSON(t, w, policy)
+		})
+	}
+	mux.HandleFunc(path.Join("/v1/volumes", strconv.Itoa(projectID), strconv.Itoa(regionID)), func(w http.ResponseWriter, r *http.Request) {
+		assert.JSONEq(t, `{"tier":"gold"}`, r.URL.Query().Get("metadata_kv"))
+		writeTestJSON(t, w, map[string]interface{}{"count": len(f.volumes), "results": f.volumes})
+	})
+
+	return mux
+}
+
+func (f *fakeLifeCyclePoliciesAPI) record(t *testing.T, r *http.Request, name string) {
+	t.Helper()
+	body := make(map[string]interface{})
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, name)
+	f.bodies[name] = body
+}
+
+func TestReconcileLifeCyclePolicy(t *testing.T) {
+	fake := &fakeLifeCyclePoliciesAPI{
+		policies: []map[string]interface{}{{
+			"id":     999,
+			"name":   testLifeCyclePolicyName,
+			"action": "volume_snapshot",
+			"status": "paused",
+			"volumes": []map[string]string{
+				{"volume_id": testVolumeID},
+				{"volume_id": testResourceID2},
+			},
+			"schedules": []map[string]interface{}{
+				{"type": "cron", "id": "cron-id", "max_quantity": 7, "timezone": "UTC", "day_of_week": "mon-fri", "hour": "3", "minute": "0"},
+				{"type": "interval", "id": "interval-id", "max_quantity": 5, "days": 1},
+			},
+		}},
+		volumes: []edgecloud.Volume{
+			{ID: testVolumeID, Metadata: edgecloud.Metadata{"tier": "gold"}},
+			{ID: testResourceID3, Metadata: edgecloud.Metadata{"tier": "gold"}},
+			{ID: testResourceID, Metadata: edgecloud.Metadata{"tier": "silver"}},
+		},
+		bodies: make(map[string]map[string]interface{}),
+	}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	interval := &edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest{Hours: 12}
+	interval.SetCommonCreateScheduleOpts(edgecloud.LifeCyclePolicyCommonCreateScheduleRequest{
+		Type:        edgecloud.LifeCyclePolicyScheduleTypeInterval,
+		MaxQuantity: 5,
+	})
+	cronSchedule := &edgecloud.LifeCyclePolicyCreateCronScheduleRequest{DayOfWeek: "0-4", Hour: "3"}
+	cronSchedule.SetCommonCreateScheduleOpts(edgecloud.LifeCyclePolicyCommonCreateScheduleRequest{
+		Type:        edgecloud.LifeCyclePolicyScheduleTypeCron,
+		MaxQuantity: 7,
+	})
+	desired := &LifeCyclePolicySpec{
+		Name:           testLifeCyclePolicyName,
+		Schedules:      []edgecloud.LifeCyclePolicyCreateScheduleRequest{cronSchedule, interval},
+		VolumeSelector: edgecloud.Metadata{"tier": "gold"},
+	}
+
+	diff, err := ReconcileLifeCyclePolicy(context.Background(), newTestClient(server.URL), desired)
+	require.NoError(t, err)
+	assert.Equal(t, &LifeCyclePolicyDiff{
+		PolicyID:          999,
+		Status:            edgecloud.LifeCyclePolicyStatusActive,
+		AddSchedules:      []edgecloud.LifeCyclePolicyCreateScheduleRequest{interval},
+		RemoveScheduleIDs: []string{"interval-id"},
+		AddVolumeIDs:      []string{testResourceID3},
+		RemoveVolumeIDs:   []string{testResourceID2},
+	}, diff)
+	assert.Equal(t, []string{"update", "add_schedules", "remove_schedules", "add_volumes_to_policy", "remove_volumes_from_policy"}, fake.calls)
+	assert.Equal(t, []interface{}{"interval-id"}, fake.bodies["remove_schedules"]["schedule_ids"])
+
+	t.Run("in sync", func(t *testing.T) {
+		fake.calls = nil
+		fake.policies[0]["status"] = "active"
+		fake.policies[0]["volumes"] = []map[string]string{{"volume_id": testVolumeID}, {"volume_id": testResourceID3}}
+		fake.policies[0]["schedules"] = []map[string]interface{}{
+			{"type": "interval", "id": "new-id", "max_quantity": 5, "minutes": 720},
+			{"type": "cron", "id": "cron-id", "max_quantity": 7, "timezone": "UTC", "day_of_week": "mon-fri", "hour": "3", "minute": "0"},
+		}
+
+		diff, err := ReconcileLifeCyclePolicy(context.Background(), newTestClient(server.URL), desired)
+		require.NoError(t, err)
+		assert.True(t, diff.Empty())
+		assert.Empty(t, fake.calls)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		fake.policies = nil
+
+		diff, err := ReconcileLifeCyclePolicy(context.Background(), newTestClient(server.URL), desired)
+		require.NoError(t, err)
+		assert.True(t, diff.Create)
+		assert.Equal(t, 999, diff.PolicyID)
+		assert.Equal(t, []string{"create"}, fake.calls)
+		assert.Equal(t, []interface{}{testResourceID3, testVolumeID}, fake.bodies["create"]["volume_ids"])
+		assert.Len(t, fake.bodies["create"]["schedules"], 2)
+	})
+}
+
+func TestPlanLifeCyclePolicy_EmptyVolumeSelector(t *testing.T) {
+	fake := &fakeLifeCyclePoliciesAPI{bodies: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(fake.handler(t))
+	defer server.Close()
+
+	desired := &LifeCyclePolicySpec{Name: testLifeCyclePolicyName, VolumeSelector: edgecloud.Metadata{}}
+
+	diff, err := PlanLifeCyclePolicy(context.Background(), newTestClient(server.URL), desired)
+	assert.Equal(t, edgecloud.NewArgError("desired.VolumeSelector", "cannot be empty, use nil to leave the volumes unchanged"), err)
+	assert.Nil(t, diff)
+	assert.Empty(t, fake.calls)
+}
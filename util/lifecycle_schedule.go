@@ -0,0 +1,252 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+	"github.com/Edge-Center/edgecentercloud-go/v2/cron"
+)
+
+const (
+	// LifeCyclePolicyScheduleMinPeriod is the shortest period between snapshots that is not reported as too frequent.
+	LifeCyclePolicyScheduleMinPeriod = time.Hour
+
+	// lifeCyclePolicySampleFires limits the fire times sampled to check and estimate a cron schedule.
+	lifeCyclePolicySampleFires = 100000
+)
+
+var (
+	ErrLifeCyclePolicyScheduleUnknown    = errors.New("unknown lifecycle policy schedule type")
+	ErrLifeCyclePolicyScheduleNeverFires = errors.New("the lifecycle policy schedule never fires")
+	ErrLifeCyclePolicyScheduleUnbounded  = errors.New("the lifecycle policy schedule limits neither the quantity nor the retention time")
+)
+
+// LifeCyclePolicyScheduleEvaluator computes the fire times of a cron or interval lifecycle policy schedule locally.
+type LifeCyclePolicyScheduleEvaluator struct {
+	// Type is the type of the schedule.
+	Type edgecloud.LifeCyclePolicyScheduleType
+	// Interval is the period of an interval schedule.
+	Interval time.Duration
+	// Start is the time an interval schedule is counted from. The API does not report it, so it is the time
+	// passed to Next if zero.
+	Start time.Time
+	// MaxQuantity is the maximum number of snapshots kept by the schedule, 0 if not limited.
+	MaxQuantity int
+	// RetentionTime is the age snapshots are deleted at, 0 if not limited.
+	RetentionTime time.Duration
+
+	cron *cron.Schedule
+}
+
+// NewLifeCyclePolicyScheduleEvaluator parses a schedule of an existing lifecycle policy.
+func NewLifeCyclePolicyScheduleEvaluator(schedule edgecloud.LifeCyclePolicySchedule) (*LifeCyclePolicyScheduleEvaluator, error) {
+	switch s := schedule.(type) {
+	case edgecloud.LifeCyclePolicyCronSchedule:
+		spec, err := s.Spec()
+		if err != nil {
+			return nil, err
+		}
+
+		return newCronScheduleEvaluator(spec, s.LifeCyclePolicyCommonSchedule.MaxQuantity, s.RetentionTime)
+	case edgecloud.LifeCyclePolicyIntervalSchedule:
+		return newIntervalScheduleEvaluator(s.Duration(), s.MaxQuantity, s.RetentionTime)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrLifeCyclePolicyScheduleUnknown, schedule)
+	}
+}
+
+// NewLifeCyclePolicyScheduleRequestEvaluator parses a schedule to be created.
+func NewLifeCyclePolicyScheduleRequestEvaluator(req edgecloud.LifeCyclePolicyCreateScheduleRequest) (*LifeCyclePolicyScheduleEvaluator, error) {
+	switch r := req.(type) {
+	case *edgecloud.LifeCyclePolicyCreateCronScheduleRequest:
+		spec, err := r.Spec()
+		if err != nil {
+			return nil, err
+		}
+
+		return newCronScheduleEvaluator(spec, r.MaxQuantity, r.RetentionTime)
+	case *edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest:
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+
+		return newIntervalScheduleEvaluator(r.Duration(), r.MaxQuantity, r.RetentionTime)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrLifeCyclePolicyScheduleUnknown, req)
+	}
+}
+
+func newCronScheduleEvaluator(spec cron.Spec, maxQuantity int, retention *edgecloud.LifeCyclePolicyRetentionTimer) (*LifeCyclePolicyScheduleEvaluator, error) {
+	schedule, err := spec.Schedule()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &LifeCyclePolicyScheduleEvaluator{Type: edgecloud.LifeCyclePolicyScheduleTypeCron, MaxQuantity: maxQuantity, cron: schedule}
+	if retention != nil {
+		e.RetentionTime = retention.Duration()
+	}
+
+	return e, nil
+}
+
+func newIntervalScheduleEvaluator(interval time.Duration, maxQuantity int, retention *edgecloud.LifeCyclePolicyRetentionTimer) (*LifeCyclePolicyScheduleEvaluator, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: %v", edgecloud.ErrLifeCyclePolicyInvalidInterval, interval)
+	}
+
+	e := &LifeCyclePolicyScheduleEvaluator{Type: edgecloud.LifeCyclePolicyScheduleTypeInterval, Interval: interval, MaxQuantity: maxQuantity}
+	if retention != nil {
+		e.RetentionTime = retention.Duration()
+	}
+
+	return e, nil
+}
+
+// Location returns the timezone of the fire times.
+func (e *LifeCyclePolicyScheduleEvaluator) Location() *time.Location {
+	if e.cron != nil {
+		return e.cron.Location()
+	}
+
+	return time.UTC
+}
+
+// Next returns the first fire time after t in the timezone of the schedule, or the zero time if the schedule
+// does not fire within the next years.
+func (e *LifeCyclePolicyScheduleEvaluator) Next(t time.Time) time.Time {
+	if e.cron != nil {
+		return e.cron.Next(t)
+	}
+
+	start := e.Start
+	if start.IsZero() {
+		start = t
+	}
+	if start.After(t) {
+		return start.UTC()
+	}
+
+	periods := t.Sub(start)/e.Interval + 1
+
+	return start.Add(periods * e.Interval).UTC()
+}
+
+// NextN returns up to n fire times after t.
+func (e *LifeCyclePolicyScheduleEvaluator) NextN(t time.Time, n int) []time.Time {
+	times := make([]time.Time, 0, n)
+	for len(times) < n {
+		if t = e.Next(t); t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+
+	return times
+}
+
+// Check returns an error if the schedule never fires, and warnings if it fires more often than
+// LifeCyclePolicyScheduleMinPeriod or does not limit the number of snapshots.
+func (e *LifeCyclePolicyScheduleEvaluator) Check(t time.Time) ([]string, error) {
+	if e.Next(t).IsZero() {
+		return nil, ErrLifeCyclePolicyScheduleNeverFires
+	}
+
+	var warnings []string
+	if period := e.minPeriod(t); period < LifeCyclePolicyScheduleMinPeriod {
+		warnings = append(warnings, fmt.Sprintf("the schedule fires every %v", period))
+	}
+
+	if e.MaxQuantity <= 0 && e.RetentionTime <= 0 {
+		warnings = append(warnings, ErrLifeCyclePolicyScheduleUnbounded.Error())
+	}
+
+	return warnings, nil
+}
+
+// minPeriod returns the shortest time between two fire times within a year after t.
+func (e *LifeCyclePolicyScheduleEvaluator) minPeriod(t time.Time) time.Duration {
+	if e.cron == nil {
+		return e.Interval
+	}
+
+	fires := e.fires(t, t.AddDate(1, 0, 0))
+	period := time.Duration(0)
+	for i := 1; i < len(fires); i++ {
+		if d := fires[i].Sub(fires[i-1]); period == 0 || d < period {
+			period = d
+		}
+	}
+
+	if period == 0 {
+		// the schedule fires at most once a year
+		return 365 * 24 * time.Hour
+	}
+
+	return period
+}
+
+// fires returns the fire times after t up to the end time, limited by lifeCyclePolicySampleFires.
+func (e *LifeCyclePolicyScheduleEvaluator) fires(t, end time.Time) []time.Time {
+	var fires []time.Time
+	for len(fires) < lifeCyclePolicySampleFires {
+		if t = e.Next(t); t.IsZero() || t.After(end) {
+			break
+		}
+		fires = append(fires, t)
+	}
+
+	return fires
+}
+
+// LifeCyclePolicySnapshotEstimate is the number of snapshots a schedule keeps per volume at steady state.
+type LifeCyclePolicySnapshotEstimate struct {
+	// Count is the maximum number of snapshots of a volume, 0 if Unbounded.
+	Count int
+	// LimitedBy is max_quantity or retention_time, whichever limits the count.
+	LimitedBy string
+	// Unbounded is true if the number of snapshots grows without a limit.
+	Unbounded bool
+}
+
+// EstimateSnapshots estimates the number of snapshots of a volume kept by the schedule at steady state: the
+// maximum number of fire times within the retention time, limited by MaxQuantity. Cron schedules are sampled
+// for a year after the retention time after t.
+func (e *LifeCyclePolicyScheduleEvaluator) EstimateSnapshots(t time.Time) LifeCyclePolicySnapshotEstimate {
+	if e.RetentionTime <= 0 {
+		if e.MaxQuantity <= 0 {
+			return LifeCyclePolicySnapshotEstimate{Unbounded: true}
+		}
+
+		return LifeCyclePolicySnapshotEstimate{Count: e.MaxQuantity, LimitedBy: "max_quantity"}
+	}
+
+	var count int
+	if e.cron == nil {
+		// the snapshots younger than the retention time
+		count = int((e.RetentionTime + e.Interval - 1) / e.Interval)
+	} else {
+		count = maxFiresInWindow(e.fires(t, t.Add(e.RetentionTime).AddDate(1, 0, 0)), e.RetentionTime)
+	}
+
+	if e.MaxQuantity > 0 && e.MaxQuantity <= count {
+		return LifeCyclePolicySnapshotEstimate{Count: e.MaxQuantity, LimitedBy: "max_quantity"}
+	}
+
+	return LifeCyclePolicySnapshotEstimate{Count: count, LimitedBy: "retention_time"}
+}
+
+// maxFiresInWindow returns the maximum number of the fire times within (f - window, f] for any fire time f.
+func maxFiresInWindow(fires []time.Time, window time.Duration) int {
+	result, start := 0, 0
+	for i, fire := range fires {
+		for !fires[start].After(fire.Add(-window)) {
+			start++
+		}
+		result = max(result, i-start+1)
+	}
+
+	return result
+}
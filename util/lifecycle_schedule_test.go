@@ -0,0 +1,90 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+func TestLifeCyclePolicyScheduleEvaluator(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC) // Friday
+
+	t.Run("cron in timezone", func(t *testing.T) {
+		e, err := NewLifeCyclePolicyScheduleEvaluator(edgecloud.LifeCyclePolicyCronSchedule{
+			LifeCyclePolicyCommonSchedule: edgecloud.LifeCyclePolicyCommonSchedule{MaxQuantity: 30},
+			Timezone:                      "Europe/Moscow",
+			DayOfWeek:                     "0-4",
+			Hour:                          "3",
+		})
+		require.NoError(t, err)
+
+		moscow, err := time.LoadLocation("Europe/Moscow")
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{
+			time.Date(2024, 3, 4, 3, 0, 0, 0, moscow),
+			time.Date(2024, 3, 5, 3, 0, 0, 0, moscow),
+			time.Date(2024, 3, 6, 3, 0, 0, 0, moscow),
+		}, e.NextN(now, 3))
+
+		warnings, err := e.Check(now)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, LifeCyclePolicySnapshotEstimate{Count: 30, LimitedBy: "max_quantity"}, e.EstimateSnapshots(now))
+	})
+
+	t.Run("cron limited by retention", func(t *testing.T) {
+		e, err := NewLifeCyclePolicyScheduleRequestEvaluator(&edgecloud.LifeCyclePolicyCreateCronScheduleRequest{
+			LifeCyclePolicyCommonCreateScheduleRequest: edgecloud.LifeCyclePolicyCommonCreateScheduleRequest{
+				MaxQuantity:   100,
+				RetentionTime: &edgecloud.LifeCyclePolicyRetentionTimer{Weeks: 2},
+			},
+			DayOfWeek: "0-4",
+			Hour:      "3",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, LifeCyclePolicySnapshotEstimate{Count: 10, LimitedBy: "retention_time"}, e.EstimateSnapshots(now))
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		e, err := NewLifeCyclePolicyScheduleRequestEvaluator(&edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest{
+			LifeCyclePolicyCommonCreateScheduleRequest: edgecloud.LifeCyclePolicyCommonCreateScheduleRequest{
+				MaxQuantity:   100,
+				RetentionTime: &edgecloud.LifeCyclePolicyRetentionTimer{Days: 1},
+			},
+			Hours: 5,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{now.Add(5 * time.Hour), now.Add(10 * time.Hour)}, e.NextN(now, 2))
+		assert.Equal(t, LifeCyclePolicySnapshotEstimate{Count: 5, LimitedBy: "retention_time"}, e.EstimateSnapshots(now))
+
+		e.Start = now.Add(-7 * time.Hour)
+		assert.Equal(t, now.Add(3*time.Hour), e.Next(now))
+	})
+
+	t.Run("warnings", func(t *testing.T) {
+		e, err := NewLifeCyclePolicyScheduleRequestEvaluator(&edgecloud.LifeCyclePolicyCreateCronScheduleRequest{})
+		require.NoError(t, err)
+
+		warnings, err := e.Check(now)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the schedule fires every 1m0s", ErrLifeCyclePolicyScheduleUnbounded.Error()}, warnings)
+		assert.True(t, e.EstimateSnapshots(now).Unbounded)
+	})
+
+	t.Run("never fires", func(t *testing.T) {
+		e, err := NewLifeCyclePolicyScheduleRequestEvaluator(&edgecloud.LifeCyclePolicyCreateCronScheduleRequest{Month: "2", Day: "30"})
+		require.NoError(t, err)
+
+		_, err = e.Check(now)
+		require.ErrorIs(t, err, ErrLifeCyclePolicyScheduleNeverFires)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewLifeCyclePolicyScheduleRequestEvaluator(&edgecloud.LifeCyclePolicyCreateIntervalScheduleRequest{})
+		require.ErrorIs(t, err, edgecloud.ErrLifeCyclePolicyInvalidInterval)
+	})
+}
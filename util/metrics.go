@@ -0,0 +1,353 @@
+package util
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	MetricLabelInstanceID     = "instance_id"
+	MetricLabelLoadbalancerID = "loadbalancer_id"
+	MetricLabelDisk           = "disk"
+
+	metricPercentile = 0.95
+)
+
+var ErrMetricTimeFormat = errors.New("unsupported metric time format")
+
+// MetricSample is a single metric value at a point in time.
+type MetricSample struct {
+	Time  time.Time
+	Value float64
+}
+
+// MetricSeries is a time series of a single metric sorted by time.
+type MetricSeries struct {
+	Name    string
+	Labels  map[string]string
+	Samples []MetricSample
+}
+
+// MetricAggregates are the aggregates of a metric series.
+type MetricAggregates struct {
+	Count int
+	Min   float64
+	Max   float64
+	Avg   float64
+	P95   float64
+	// Rate is the average change of the value per second between the first and the last sample.
+	Rate float64
+}
+
+// ParseMetricTime parses a timestamp returned by the metrics API.
+func ParseMetricTime(s string) (time.Time, error) {
+	t, err := parseAPITime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrMetricTimeFormat, s)
+	}
+
+	return t, nil
+}
+
+// Aggregate computes the aggregates of the series.
+func (s MetricSeries) Aggregate() MetricAggregates {
+	agg := MetricAggregates{Count: len(s.Samples)}
+	if agg.Count == 0 {
+		return agg
+	}
+
+	values := make([]float64, 0, agg.Count)
+	var sum float64
+	for _, sample := range s.Samples {
+		values = append(values, sample.Value)
+		sum += sample.Value
+	}
+	sort.Float64s(values)
+
+	agg.Min = values[0]
+	agg.Max = values[len(values)-1]
+	agg.Avg = sum / float64(agg.Count)
+	agg.P95 = values[int(math.Ceil(metricPercentile*float64(agg.Count)))-1]
+
+	first, last := s.Samples[0], s.Samples[len(s.Samples)-1]
+	if elapsed := last.Time.Sub(first.Time).Seconds(); elapsed > 0 {
+		agg.Rate = (last.Value - first.Value) / elapsed
+	}
+
+	return agg
+}
+
+// labelsKey returns a stable representation of the series labels.
+func (s MetricSeries) labelsKey() string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+s.Labels[k])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// metricSeriesSet collects samples into series keyed by name and labels.
+type metricSeriesSet struct {
+	order  []string
+	series map[string]*MetricSeries
+}
+
+func newMetricSeriesSet() *metricSeriesSet {
+	return &metricSeriesSet{series: make(map[string]*MetricSeries)}
+}
+
+func (m *metricSeriesSet) add(name string, labels map[string]string, t time.Time, value int) {
+	s := MetricSeries{Name: name, Labels: labels}
+	key := name + "{" + s.labelsKey() + "}"
+
+	existing, ok := m.series[key]
+	if !ok {
+		existing = &s
+		m.series[key] = existing
+		m.order = append(m.order, key)
+	}
+	existing.Samples = append(existing.Samples, MetricSample{Time: t, Value: float64(value)})
+}
+
+func (m *metricSeriesSet) list() []MetricSeries {
+	result := make([]MetricSeries, 0, len(m.order))
+	for _, key := range m.order {
+		s := *m.series[key]
+		sort.SliceStable(s.Samples, func(i, j int) bool { return s.Samples[i].Time.Before(s.Samples[j].Time) })
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// InstanceMetricSeries converts raw instance metrics into time series. Disk metrics are split
+// into a series per disk with the disk label set to DiskMetrics.DiskName.
+func InstanceMetricSeries(instanceID string, metrics []edgecloud.InstanceMetrics) ([]MetricSeries, error) {
+	set := newMetricSeriesSet()
+	labels := map[string]string{MetricLabelInstanceID: instanceID}
+
+	for _, m := range metrics {
+		t, err := ParseMetricTime(m.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		set.add("instance_cpu_util", labels, t, m.CPUUtil)
+		set.add("instance_memory_util", labels, t, m.MemoryUtil)
+		set.add("instance_network_Bps_ingress", labels, t, m.NetworkBpsIngress)
+		set.add("instance_network_Bps_egress", labels, t, m.NetworkBpsEgress)
+		set.add("instance_network_pps_ingress", labels, t, m.NetworkPpsIngress)
+		set.add("instance_network_pps_egress", labels, t, m.NetworkPpsEgress)
+
+		for _, disk := range m.Disks {
+			diskLabels := map[string]string{MetricLabelInstanceID: instanceID, MetricLabelDisk: disk.DiskName}
+			set.add("instance_disk_iops_read", diskLabels, t, disk.DiskIOpsRead)
+			set.add("instance_disk_iops_write", diskLabels, t, disk.DiskIOpsWrite)
+			set.add("instance_disk_Bps_read", diskLabels, t, disk.DiskBpsRead)
+			set.add("instance_disk_Bps_write", diskLabels, t, disk.DiskBpsWrite)
+		}
+	}
+
+	return set.list(), nil
+}
+
+// LoadbalancerMetricSeries converts raw load balancer metrics into time series.
+func LoadbalancerMetricSeries(loadbalancerID string, metrics []edgecloud.LoadbalancerMetrics) ([]MetricSeries, error) {
+	set := newMetricSeriesSet()
+	labels := map[string]string{MetricLabelLoadbalancerID: loadbalancerID}
+
+	for _, m := range metrics {
+		t, err := ParseMetricTime(m.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		set.add("loadbalancer_cpu_util", labels, t, m.CPUUtil)
+		set.add("loadbalancer_memory_util", labels, t, m.MemoryUtil)
+		set.add("loadbalancer_network_Bps_ingress", labels, t, m.NetworkBpsIngress)
+		set.add("loadbalancer_network_Bps_egress", labels, t, m.NetworkBpsEgress)
+		set.add("loadbalancer_network_pps_ingress", labels, t, m.NetworkPpsIngress)
+		set.add("loadbalancer_network_pps_egress", labels, t, m.NetworkPpsEgress)
+	}
+
+	return set.list(), nil
+}
+
+// InstanceMetricSeriesList gets the instance metrics and converts them into time series.
+func InstanceMetricSeriesList(ctx context.Context, client *edgecloud.Client, instanceID string, reqBody *edgecloud.InstanceMetricsListRequest) ([]MetricSeries, error) {
+	metrics, _, err := client.Instances.MetricsList(ctx, instanceID, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return InstanceMetricSeries(instanceID, metrics)
+}
+
+// LoadbalancerMetricSeriesList gets the load balancer metrics and converts them into time series.
+func LoadbalancerMetricSeriesList(ctx context.Context, client *edgecloud.Client, loadbalancerID string, reqBody *edgecloud.LoadbalancerMetricsListRequest) ([]MetricSeries, error) {
+	metrics, _, err := client.Loadbalancers.MetricsList(ctx, loadbalancerID, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadbalancerMetricSeries(loadbalancerID, metrics)
+}
+
+func metricLabelNames(series []MetricSeries) []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for _, s := range series {
+		for k := range s.Labels {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				names = append(names, k)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// WriteMetricsCSV writes the series as CSV with a row per sample. The columns are time, metric,
+// one column per label name used by any of the series, and value.
+func WriteMetricsCSV(w io.Writer, series []MetricSeries) error {
+	labelNames := metricLabelNames(series)
+
+	cw := csv.NewWriter(w)
+	header := append([]string{"time", "metric"}, labelNames...)
+	if err := cw.Write(append(header, "value")); err != nil {
+		return err
+	}
+
+	for _, s := range series {
+		for _, sample := range s.Samples {
+			record := make([]string, 0, len(labelNames)+3)
+			record = append(record, sample.Time.UTC().Format(time.RFC3339), s.Name)
+			for _, name := range labelNames {
+				record = append(record, s.Labels[name])
+			}
+			record = append(record, formatMetricValue(sample.Value))
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+type metricJSONLine struct {
+	Time   time.Time         `json:"time"`
+	Metric string            `json:"metric"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// WriteMetricsJSONLines writes the series as JSON lines with an object per sample.
+func WriteMetricsJSONLines(w io.Writer, series []MetricSeries) error {
+	enc := json.NewEncoder(w)
+	for _, s := range series {
+		for _, sample := range s.Samples {
+			line := metricJSONLine{Time: sample.Time.UTC(), Metric: s.Name, Labels: s.Labels, Value: sample.Value}
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+var prometheusLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteMetricsPrometheus writes the series in the Prometheus text exposition format. Only the latest sample
+// of every series is written, as a gauge with its timestamp, since a series can appear only once in an
+// exposition. The metric names are prefixed with the namespace if it is set.
+func WriteMetricsPrometheus(w io.Writer, namespace string, series []MetricSeries) error {
+	// latest samples by metric name and labels
+	latest := make(map[string]map[string]MetricSample)
+	for _, s := range series {
+		if len(s.Samples) == 0 {
+			continue
+		}
+
+		sample := s.Samples[0]
+		for _, other := range s.Samples[1:] {
+			if other.Time.After(sample.Time) {
+				sample = other
+			}
+		}
+
+		if latest[s.Name] == nil {
+			latest[s.Name] = make(map[string]MetricSample)
+		}
+		labels := prometheusLabels(s.Labels)
+		if current, ok := latest[s.Name][labels]; !ok || sample.Time.After(current.Time) {
+			latest[s.Name][labels] = sample
+		}
+	}
+
+	for _, name := range sortedKeys(latest) {
+		fullName := name
+		if namespace != "" {
+			fullName = namespace + "_" + name
+		}
+
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n", fullName); err != nil {
+			return err
+		}
+
+		for _, labels := range sortedKeys(latest[name]) {
+			sample := latest[name][labels]
+			if _, err := fmt.Fprintf(w, "%s%s %s %d\n", fullName, labels, formatMetricValue(sample.Value), sample.Time.UnixMilli()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func prometheusLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, prometheusLabelValueReplacer.Replace(labels[k])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
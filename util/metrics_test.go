@@ -0,0 +1,177 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var testInstanceMetrics = []edgecloud.InstanceMetrics{
+	{
+		Time:       "2024-05-01T10:02:00+0000",
+		CPUUtil:    60,
+		MemoryUtil: 40,
+		Disks:      []edgecloud.DiskMetrics{{DiskName: "vda", DiskIOpsRead: 30}},
+	},
+	{
+		Time:       "2024-05-01T10:00:00+0000",
+		CPUUtil:    20,
+		MemoryUtil: 40,
+		Disks:      []edgecloud.DiskMetrics{{DiskName: "vda", DiskIOpsRead: 10}},
+	},
+	{
+		Time:       "2024-05-01T10:01:00+0000",
+		CPUUtil:    40,
+		MemoryUtil: 40,
+		Disks:      []edgecloud.DiskMetrics{{DiskName: "vda", DiskIOpsRead: 20}},
+	},
+}
+
+func findSeries(t *testing.T, series []MetricSeries, name string) MetricSeries {
+	t.Helper()
+
+	for _, s := range series {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("series %s not found", name)
+
+	return MetricSeries{}
+}
+
+func TestParseMetricTime(t *testing.T) {
+	expected := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, s := range []string{"2024-05-01T10:00:00Z", "2024-05-01T10:00:00+0000", "2024-05-01T10:00:00", "2024-05-01 10:00:00"} {
+		parsed, err := ParseMetricTime(s)
+		require.NoError(t, err, s)
+		assert.True(t, expected.Equal(parsed), s)
+	}
+
+	_, err := ParseMetricTime("yesterday")
+	assert.ErrorIs(t, err, ErrMetricTimeFormat)
+}
+
+func TestInstanceMetricSeries(t *testing.T) {
+	series, err := InstanceMetricSeries(testResourceID, testInstanceMetrics)
+	require.NoError(t, err)
+	assert.Len(t, series, 10)
+
+	cpu := findSeries(t, series, "instance_cpu_util")
+	require.Len(t, cpu.Samples, 3)
+	assert.Equal(t, []float64{20, 40, 60}, []float64{cpu.Samples[0].Value, cpu.Samples[1].Value, cpu.Samples[2].Value})
+	assert.Equal(t, testResourceID, cpu.Labels[MetricLabelInstanceID])
+
+	agg := cpu.Aggregate()
+	assert.Equal(t, 3, agg.Count)
+	assert.InDelta(t, 40, agg.Avg, 0.001)
+	assert.InDelta(t, 20, agg.Min, 0.001)
+	assert.InDelta(t, 60, agg.Max, 0.001)
+	assert.InDelta(t, 60, agg.P95, 0.001)
+	assert.InDelta(t, 40.0/120, agg.Rate, 0.001)
+
+	disk := findSeries(t, series, "instance_disk_iops_read")
+	assert.Equal(t, "vda", disk.Labels[MetricLabelDisk])
+	assert.InDelta(t, 20, disk.Aggregate().Avg, 0.001)
+}
+
+func TestInstanceMetricSeries_TimeFormatError(t *testing.T) {
+	_, err := InstanceMetricSeries(testResourceID, []edgecloud.InstanceMetrics{{Time: "now"}})
+	assert.ErrorIs(t, err, ErrMetricTimeFormat)
+}
+
+func TestMetricSeries_Aggregate_Empty(t *testing.T) {
+	assert.Equal(t, MetricAggregates{}, MetricSeries{Name: "empty"}.Aggregate())
+}
+
+func TestLoadbalancerMetricSeriesList(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	metrics := []edgecloud.LoadbalancerMetrics{{Time: "2024-05-01T10:00:00Z", CPUUtil: 5}}
+	URL := path.Join("/v1/loadbalancers", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID, "metrics")
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		resp, err := json.Marshal(metrics)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprintf(w, `{"results":%s}`, string(resp))
+	})
+
+	client := newTestClient(server.URL)
+
+	series, err := LoadbalancerMetricSeriesList(context.Background(), client, testResourceID, &edgecloud.LoadbalancerMetricsListRequest{TimeInterval: 1, TimeUnit: edgecloud.TimeUnitHour})
+	require.NoError(t, err)
+	cpu := findSeries(t, series, "loadbalancer_cpu_util")
+	assert.Equal(t, testResourceID, cpu.Labels[MetricLabelLoadbalancerID])
+	assert.InDelta(t, 5, cpu.Samples[0].Value, 0.001)
+}
+
+func TestWriteMetrics(t *testing.T) {
+	series := []MetricSeries{
+		{
+			Name:    "instance_disk_iops_read",
+			Labels:  map[string]string{MetricLabelInstanceID: testResourceID, MetricLabelDisk: `v"da`},
+			Samples: []MetricSample{{Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Value: 1.5}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMetricsCSV(&buf, series))
+	assert.Equal(t, "time,metric,disk,instance_id,value\n"+
+		`2024-05-01T10:00:00Z,instance_disk_iops_read,"v""da",`+testResourceID+",1.5\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteMetricsJSONLines(&buf, series))
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "instance_disk_iops_read", line["metric"])
+	assert.InDelta(t, 1.5, line["value"], 0.001)
+
+	buf.Reset()
+	require.NoError(t, WriteMetricsPrometheus(&buf, "edgecloud", series))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "# TYPE edgecloud_instance_disk_iops_read gauge", lines[0])
+	assert.Equal(t, `edgecloud_instance_disk_iops_read{disk="v\"da",instance_id="`+testResourceID+`"} 1.5 1714557600000`, lines[1])
+}
+
+func TestWriteMetricsPrometheus_LatestSample(t *testing.T) {
+	labels := map[string]string{MetricLabelInstanceID: testResourceID}
+	series := []MetricSeries{
+		{
+			Name:   "instance_cpu_util",
+			Labels: labels,
+			Samples: []MetricSample{
+				{Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Value: 10},
+				{Time: time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC), Value: 20},
+			},
+		},
+		{
+			Name:    "instance_cpu_util",
+			Labels:  labels,
+			Samples: []MetricSample{{Time: time.Date(2024, 5, 1, 10, 2, 0, 0, time.UTC), Value: 30}},
+		},
+		{Name: "instance_memory_util", Labels: labels},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMetricsPrometheus(&buf, "", series))
+	assert.Equal(t, "# TYPE instance_cpu_util gauge\n"+
+		`instance_cpu_util{instance_id="`+testResourceID+`"} 20 1714557900000`+"\n", buf.String())
+}
@@ -0,0 +1,298 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const (
+	defaultPoolDrainTimeout      = 5 * time.Minute
+	defaultPoolDrainPollInterval = 5 * time.Second
+)
+
+var (
+	ErrPoolHasNoLoadbalancer     = errors.New("the pool is not attached to a loadbalancer")
+	ErrPoolMemberNotOnline       = errors.New("the pool member is not online")
+	ErrPoolRollingUpdateNoUpdate = errors.New("the update function is not specified")
+)
+
+// PoolMemberUpdateFunc rebuilds or replaces the instance behind the pool member while the member is out of the pool.
+// It returns the member to add back to the pool, or nil to add back the same member.
+type PoolMemberUpdateFunc func(ctx context.Context, member edgecloud.PoolMember) (*edgecloud.PoolMemberCreateRequest, error)
+
+// PoolRollingUpdateOptions specifies the parameters to PoolRollingUpdate.
+type PoolRollingUpdateOptions struct {
+	// Update is called for every member of the pool. Required.
+	Update PoolMemberUpdateFunc
+	// BatchSize is the number of members taken out of the pool at once, 1 by default.
+	BatchSize int
+	// DrainThreshold is the number of active connections of the pool listeners to wait for after the batch
+	// is removed from the pool. The loadbalancer reports connections per listener, not per member, so the
+	// threshold must allow for the connections of the members left in the pool. There is no waiting if nil.
+	DrainThreshold *int
+	// DrainTimeout is the maximum time to wait for the connections to drain, 5 minutes by default.
+	// The update proceeds when it expires.
+	DrainTimeout time.Duration
+	// DrainPollInterval is the interval between the listener statistics requests, 5 seconds by default.
+	DrainPollInterval time.Duration
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for the loadbalancer and the members to become active.
+	Attempts *uint
+}
+
+// PoolRollingUpdateResult describes the members processed by PoolRollingUpdate.
+type PoolRollingUpdateResult struct {
+	// Updated are the members added back to the pool, in the order they were updated.
+	Updated []edgecloud.PoolMemberCreateRequest
+	// RolledBack are the original members restored after a failed batch.
+	RolledBack []edgecloud.PoolMemberCreateRequest
+}
+
+// PoolRollingUpdate updates the members of the pool in batches. For every batch the members are removed from
+// the pool, the listener connections are drained, the members are updated by opts.Update, added back to the
+// pool and must become online. On error the pool membership of the current batch is restored and the update
+// stops. Instances changed by opts.Update are not rolled back. Every change of the pool waits for the
+// loadbalancer to leave the PENDING_UPDATE status, since it rejects changes until then.
+//
+// Setting the member weight to 0 is not used to drain a member, since a zero weight is omitted from the request.
+func PoolRollingUpdate(ctx context.Context, client *edgecloud.Client, poolID string, opts *PoolRollingUpdateOptions) (*PoolRollingUpdateResult, error) {
+	if opts == nil || opts.Update == nil {
+		return nil, ErrPoolRollingUpdateNoUpdate
+	}
+
+	pool, _, err := client.Loadbalancers.PoolGet(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pool.Loadbalancers) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPoolHasNoLoadbalancer, poolID)
+	}
+
+	u := &poolRollingUpdate{
+		client:         client,
+		pool:           pool,
+		loadbalancerID: pool.Loadbalancers[0].ID,
+		opts:           opts,
+		result:         &PoolRollingUpdateResult{},
+	}
+
+	batchSize := max(opts.BatchSize, 1)
+	for start := 0; start < len(pool.Members); start += batchSize {
+		batch := pool.Members[start:min(start+batchSize, len(pool.Members))]
+		if err = u.updateBatch(ctx, batch); err != nil {
+			return u.result, err
+		}
+	}
+
+	return u.result, nil
+}
+
+type poolRollingUpdate struct {
+	client         *edgecloud.Client
+	pool           *edgecloud.Pool
+	loadbalancerID string
+	opts           *PoolRollingUpdateOptions
+	result         *PoolRollingUpdateResult
+}
+
+func (u *poolRollingUpdate) updateBatch(ctx context.Context, batch []edgecloud.PoolMember) error {
+	// members of the batch out of the pool with their original spec
+	removed := make([]edgecloud.PoolMemberCreateRequest, 0, len(batch))
+	// members added back to the pool, which differ from the original
+	var added []edgecloud.PoolMemberCreateRequest
+
+	rollback := func(err error) error {
+		return errors.Join(err, u.rollback(ctx, removed, added))
+	}
+
+	for _, member := range batch {
+		if err := u.deleteMember(ctx, member.ID); err != nil {
+			return rollback(err)
+		}
+		removed = append(removed, poolMemberSpec(member))
+	}
+
+	if err := u.drain(ctx); err != nil {
+		return rollback(err)
+	}
+
+	for _, member := range batch {
+		spec, err := u.opts.Update(ctx, member)
+		if err != nil {
+			return rollback(err)
+		}
+
+		if spec == nil {
+			spec = &edgecloud.PoolMemberCreateRequest{}
+			*spec = poolMemberSpec(member)
+		}
+
+		if err = u.createMember(ctx, spec); err != nil {
+			return rollback(err)
+		}
+		added = append(added, *spec)
+	}
+
+	for _, spec := range added {
+		if err := u.waitMemberOnline(ctx, spec); err != nil {
+			return rollback(err)
+		}
+	}
+
+	u.result.Updated = append(u.result.Updated, added...)
+
+	return nil
+}
+
+// rollback removes the added members and adds back the removed ones.
+func (u *poolRollingUpdate) rollback(ctx context.Context, removed, added []edgecloud.PoolMemberCreateRequest) error {
+	pool, _, err := u.client.Loadbalancers.PoolGet(ctx, u.pool.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range added {
+		if member := poolMemberBySpec(pool, spec); member != nil {
+			if err = u.deleteMember(ctx, member.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, spec := range removed {
+		spec := spec
+		if err = u.createMember(ctx, &spec); err != nil {
+			return err
+		}
+		u.result.RolledBack = append(u.result.RolledBack, spec)
+	}
+
+	return nil
+}
+
+func (u *poolRollingUpdate) deleteMember(ctx context.Context, memberID string) error {
+	if err := WaitLoadbalancerProvisioningStatusActive(ctx, u.client, u.loadbalancerID, u.opts.Attempts); err != nil {
+		return err
+	}
+
+	task, _, err := u.client.Loadbalancers.PoolMemberDelete(ctx, u.pool.ID, memberID)
+	if err != nil {
+		return err
+	}
+
+	return WaitForTaskComplete(ctx, u.client, task.Tasks[0], nonZeroTimeouts(u.opts.Timeout)...)
+}
+
+func (u *poolRollingUpdate) createMember(ctx context.Context, spec *edgecloud.PoolMemberCreateRequest) error {
+	if err := WaitLoadbalancerProvisioningStatusActive(ctx, u.client, u.loadbalancerID, u.opts.Attempts); err != nil {
+		return err
+	}
+
+	task, _, err := u.client.Loadbalancers.PoolMemberCreate(ctx, u.pool.ID, spec)
+	if err != nil {
+		return err
+	}
+
+	return WaitForTaskComplete(ctx, u.client, task.Tasks[0], nonZeroTimeouts(u.opts.Timeout)...)
+}
+
+// drain waits until the active connections of the pool listeners drop to the threshold or the drain timeout expires.
+func (u *poolRollingUpdate) drain(ctx context.Context) error {
+	if u.opts.DrainThreshold == nil || len(u.pool.Listeners) == 0 {
+		return nil
+	}
+
+	listenerIDs := make(map[string]bool, len(u.pool.Listeners))
+	for _, listener := range u.pool.Listeners {
+		listenerIDs[listener.ID] = true
+	}
+
+	timeout, interval := u.opts.DrainTimeout, u.opts.DrainPollInterval
+	if timeout == 0 {
+		timeout = defaultPoolDrainTimeout
+	}
+	if interval == 0 {
+		interval = defaultPoolDrainPollInterval
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		listeners, _, err := u.client.Loadbalancers.ListenerList(drainCtx, &edgecloud.ListenerListOptions{
+			ShowStats:      true,
+			LoadbalancerID: u.loadbalancerID,
+		})
+		if err != nil {
+			if drainCtx.Err() != nil && ctx.Err() == nil {
+				return nil
+			}
+
+			return err
+		}
+
+		connections := 0
+		for _, listener := range listeners {
+			if listenerIDs[listener.ID] {
+				connections += listener.Stats.ActiveConnections
+			}
+		}
+
+		if connections <= *u.opts.DrainThreshold {
+			return nil
+		}
+
+		select {
+		case <-drainCtx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (u *poolRollingUpdate) waitMemberOnline(ctx context.Context, spec edgecloud.PoolMemberCreateRequest) error {
+	return WithRetry(
+		func() error {
+			pool, _, err := u.client.Loadbalancers.PoolGet(ctx, u.pool.ID)
+			if err != nil {
+				return err
+			}
+
+			member := poolMemberBySpec(pool, spec)
+			switch {
+			case member == nil:
+				return fmt.Errorf("%w: %s", ErrLoadbalancerPoolsMemberNotFound, spec.Address)
+			case member.OperatingStatus == edgecloud.OperatingStatusOnline,
+				member.OperatingStatus == edgecloud.OperatingStatusNoMonitor && pool.HealthMonitor == nil:
+				return nil
+			default:
+				return fmt.Errorf("%w: %s, status %s", ErrPoolMemberNotOnline, spec.Address, member.OperatingStatus)
+			}
+		},
+		u.opts.Attempts,
+	)
+}
+
+func poolMemberSpec(member edgecloud.PoolMember) edgecloud.PoolMemberCreateRequest {
+	spec := member.PoolMemberCreateRequest
+	spec.ID = ""
+
+	return spec
+}
+
+func poolMemberBySpec(pool *edgecloud.Pool, spec edgecloud.PoolMemberCreateRequest) *edgecloud.PoolMember {
+	for _, member := range pool.Members {
+		if net.IP.Equal(member.Address, spec.Address) && member.ProtocolPort == spec.ProtocolPort && member.SubnetID == spec.SubnetID {
+			return &member
+		}
+	}
+
+	return nil
+}
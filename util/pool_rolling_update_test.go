@@ -0,0 +1,267 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// fakePoolAPI keeps a loadbalancer pool in memory and serves the pool, member, listener and loadbalancer endpoints.
+type fakePoolAPI struct {
+	*fakeAPI
+	pool edgecloud.Pool
+	// memberStatus returns the operating status of a member added to the pool.
+	memberStatus func(address net.IP) edgecloud.OperatingStatus
+	connections  int
+	// failListeners makes the listener list fail.
+	failListeners bool
+}
+
+// newFakePoolAPI records the pool changes as "delete <address>" and "create <address>" calls.
+func newFakePoolAPI(t *testing.T, mux *http.ServeMux, pool edgecloud.Pool) *fakePoolAPI {
+	t.Helper()
+
+	api := &fakePoolAPI{fakeAPI: newFakeAPI(t, mux), pool: pool, connections: 10}
+	api.memberStatus = func(net.IP) edgecloud.OperatingStatus { return edgecloud.OperatingStatusOnline }
+
+	URLPool := resourcePath("/v1/lbpools", pool.ID)
+	api.handle(URLPool, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, api.pool)
+	})
+	api.handle(URLPool+"/member", api.createMember)
+	api.handle(URLPool+"/member/", api.deleteMember)
+	api.handle(resourcePath("/v1/loadbalancers", pool.Loadbalancers[0].ID), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Loadbalancer{ID: pool.Loadbalancers[0].ID, ProvisioningStatus: edgecloud.ProvisioningStatusActive})
+	})
+	api.handle(resourcePath("/v1/lblisteners"), api.listListeners)
+
+	return api
+}
+
+func (api *fakePoolAPI) createMember(w http.ResponseWriter, r *http.Request) {
+	var spec edgecloud.PoolMemberCreateRequest
+	api.decode(r, &spec)
+
+	api.pool.Members = append(api.pool.Members, edgecloud.PoolMember{
+		ID:                      uuid.NewString(),
+		OperatingStatus:         api.memberStatus(spec.Address),
+		PoolMemberCreateRequest: spec,
+	})
+	api.call("create %s", spec.Address)
+	api.writeTask(w, nil)
+}
+
+func (api *fakePoolAPI) deleteMember(w http.ResponseWriter, r *http.Request) {
+	memberID := path.Base(r.URL.Path)
+	for i, member := range api.pool.Members {
+		if member.ID == memberID {
+			api.pool.Members = append(api.pool.Members[:i], api.pool.Members[i+1:]...)
+			api.call("delete %s", member.Address)
+			api.writeTask(w, nil)
+
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (api *fakePoolAPI) listListeners(w http.ResponseWriter, r *http.Request) {
+	if api.failListeners {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// connections drain by 5 on every request
+	api.connections = max(api.connections-5, 0)
+	listeners := []edgecloud.Listener{
+		{ID: api.pool.Listeners[0].ID, Stats: edgecloud.LoadbalancerStats{ActiveConnections: api.connections}},
+		{ID: uuid.NewString(), Stats: edgecloud.LoadbalancerStats{ActiveConnections: 100}},
+	}
+	writeTestJSON(api.t, w, map[string]interface{}{"results": listeners})
+}
+
+func (api *fakePoolAPI) addresses() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	addresses := make([]string, 0, len(api.pool.Members))
+	for _, member := range api.pool.Members {
+		addresses = append(addresses, member.Address.String())
+	}
+
+	return addresses
+}
+
+func testPool() edgecloud.Pool {
+	pool := edgecloud.Pool{
+		ID:            testResourceID,
+		Loadbalancers: []edgecloud.ID{{ID: testResourceID3}},
+		Listeners:     []edgecloud.ID{{ID: testInstanceID2}},
+	}
+	for _, address := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		pool.Members = append(pool.Members, edgecloud.PoolMember{
+			ID:              uuid.NewString(),
+			OperatingStatus: edgecloud.OperatingStatusOnline,
+			PoolMemberCreateRequest: edgecloud.PoolMemberCreateRequest{
+				Address:      net.ParseIP(address),
+				ProtocolPort: 80,
+				SubnetID:     testSubnetID,
+				Weight:       1,
+			},
+		})
+	}
+
+	return pool
+}
+
+// replaceAddress returns an update function which moves the members to the 10.0.1.0/24 network.
+func replaceAddress(updated *[]string) PoolMemberUpdateFunc {
+	return func(ctx context.Context, member edgecloud.PoolMember) (*edgecloud.PoolMemberCreateRequest, error) {
+		*updated = append(*updated, member.Address.String())
+		spec := member.PoolMemberCreateRequest
+		spec.Address = net.ParseIP(strings.Replace(member.Address.String(), "10.0.0.", "10.0.1.", 1))
+
+		return &spec, nil
+	}
+}
+
+func TestPoolRollingUpdate(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakePoolAPI(t, mux, testPool())
+	client := newTestClient(server.URL)
+
+	var updated []string
+	result, err := PoolRollingUpdate(context.Background(), client, testResourceID, &PoolRollingUpdateOptions{
+		Update:            replaceAddress(&updated),
+		BatchSize:         2,
+		DrainThreshold:    edgecloud.PtrTo(0),
+		DrainPollInterval: time.Millisecond,
+		Attempts:          &attempts,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, updated)
+	require.Len(t, result.Updated, 3)
+	assert.Empty(t, result.RolledBack)
+	assert.Equal(t, []string{"10.0.1.1", "10.0.1.2", "10.0.1.3"}, api.addresses())
+	assert.Equal(t, []string{
+		"delete 10.0.0.1", "delete 10.0.0.2", "create 10.0.1.1", "create 10.0.1.2",
+		"delete 10.0.0.3", "create 10.0.1.3",
+	}, api.calls)
+	assert.Zero(t, api.connections)
+}
+
+func TestPoolRollingUpdate_SameMember(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakePoolAPI(t, mux, testPool())
+	client := newTestClient(server.URL)
+
+	result, err := PoolRollingUpdate(context.Background(), client, testResourceID, &PoolRollingUpdateOptions{
+		Update: func(ctx context.Context, member edgecloud.PoolMember) (*edgecloud.PoolMemberCreateRequest, error) {
+			return nil, nil
+		},
+		DrainPollInterval: time.Millisecond,
+		Attempts:          &attempts,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Updated, 3)
+	assert.Empty(t, result.Updated[0].ID)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, api.addresses())
+}
+
+func TestPoolRollingUpdate_RollbackUnhealthy(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakePoolAPI(t, mux, testPool())
+	api.memberStatus = func(address net.IP) edgecloud.OperatingStatus {
+		if address.Equal(net.ParseIP("10.0.1.2")) {
+			return edgecloud.OperatingStatusError
+		}
+		return edgecloud.OperatingStatusOnline
+	}
+	client := newTestClient(server.URL)
+
+	var updated []string
+	result, err := PoolRollingUpdate(context.Background(), client, testResourceID, &PoolRollingUpdateOptions{
+		Update:            replaceAddress(&updated),
+		DrainPollInterval: time.Millisecond,
+		Attempts:          &attempts,
+	})
+	assert.ErrorIs(t, err, ErrPoolMemberNotOnline)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, updated)
+	require.Len(t, result.Updated, 1)
+	require.Len(t, result.RolledBack, 1)
+	assert.Equal(t, "10.0.0.2", result.RolledBack[0].Address.String())
+	assert.ElementsMatch(t, []string{"10.0.1.1", "10.0.0.2", "10.0.0.3"}, api.addresses())
+}
+
+func TestPoolRollingUpdate_RollbackUpdateError(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakePoolAPI(t, mux, testPool())
+	client := newTestClient(server.URL)
+
+	errRebuild := errors.New("rebuild failed")
+	result, err := PoolRollingUpdate(context.Background(), client, testResourceID, &PoolRollingUpdateOptions{
+		Update: func(ctx context.Context, member edgecloud.PoolMember) (*edgecloud.PoolMemberCreateRequest, error) {
+			return nil, errRebuild
+		},
+		BatchSize:         3,
+		DrainPollInterval: time.Millisecond,
+		Attempts:          &attempts,
+	})
+	assert.ErrorIs(t, err, errRebuild)
+	assert.Empty(t, result.Updated)
+	assert.Len(t, result.RolledBack, 3)
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, api.addresses())
+}
+
+func TestPoolRollingUpdate_DrainError(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakePoolAPI(t, mux, testPool())
+	api.failListeners = true
+	client := newTestClient(server.URL)
+
+	var updated []string
+	result, err := PoolRollingUpdate(context.Background(), client, testResourceID, &PoolRollingUpdateOptions{
+		Update:            replaceAddress(&updated),
+		DrainThreshold:    edgecloud.PtrTo(0),
+		DrainPollInterval: time.Millisecond,
+		Attempts:          &attempts,
+	})
+	assert.Error(t, err)
+	assert.Empty(t, updated)
+	assert.Empty(t, result.Updated)
+	require.Len(t, result.RolledBack, 1)
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, api.addresses())
+}
+
+func TestPoolRollingUpdate_NoUpdate(t *testing.T) {
+	result, err := PoolRollingUpdate(context.Background(), edgecloud.NewClient(nil), testResourceID, nil)
+	assert.ErrorIs(t, err, ErrPoolRollingUpdateNoUpdate)
+	assert.Nil(t, result)
+}
@@ -9,8 +9,10 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"sync"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 
 	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
@@ -19,11 +21,90 @@ import (
 const (
 	testResourceID  = "f0d19cec-5c3f-4853-886e-304915960ff6"
 	testResourceID2 = "40d19cec-5c3f-4853-886e-a6g6g3k95543"
+	testResourceID3 = "2b1a7c3e-6f5d-4e8a-9c0b-7a6d5e4f3c2b"
 	testName        = "test-name"
 	projectID       = 2750
 	regionID        = 8
 )
 
+func newTestClient(serverURL string) *edgecloud.Client {
+	client := edgecloud.NewClient(nil)
+	baseURL, _ := url.Parse(serverURL)
+	client.BaseURL = baseURL
+	client.Project = projectID
+	client.Region = regionID
+
+	return client
+}
+
+func writeTestJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	t.Helper()
+	resp, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal JSON: %v", err)
+	}
+	_, _ = fmt.Fprint(w, string(resp))
+}
+
+// fakeAPI is the in-memory cloud API shared by the tests of the functions making several requests.
+// The handlers registered with handle run one at a time, so they can change the state of the test freely.
+type fakeAPI struct {
+	t   *testing.T
+	mux *http.ServeMux
+	mu  sync.Mutex
+	// calls are the changes made through the API, in the order they were made.
+	calls []string
+	// tasks are the resources created by every task.
+	tasks map[string]map[string]interface{}
+}
+
+// newFakeAPI serves the tasks on mux, every task is finished.
+func newFakeAPI(t *testing.T, mux *http.ServeMux) *fakeAPI {
+	t.Helper()
+
+	api := &fakeAPI{t: t, mux: mux, tasks: map[string]map[string]interface{}{}}
+	api.handle("/v1/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		taskID := path.Base(r.URL.Path)
+		writeTestJSON(t, w, edgecloud.Task{ID: taskID, State: edgecloud.TaskStateFinished, CreatedResources: api.tasks[taskID]})
+	})
+
+	return api
+}
+
+// handle registers the handler for the pattern, see http.ServeMux.
+func (api *fakeAPI) handle(pattern string, handler http.HandlerFunc) {
+	api.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+
+		handler(w, r)
+	})
+}
+
+// call records a call made to the API.
+func (api *fakeAPI) call(format string, a ...interface{}) {
+	api.calls = append(api.calls, fmt.Sprintf(format, a...))
+}
+
+// decode decodes the body of the request into v.
+func (api *fakeAPI) decode(r *http.Request, v interface{}) {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		api.t.Errorf("failed to decode request: %v", err)
+	}
+}
+
+// writeTask writes a new task creating the resources.
+func (api *fakeAPI) writeTask(w http.ResponseWriter, resources map[string]interface{}) {
+	taskID := uuid.NewString()
+	api.tasks[taskID] = resources
+	writeTestJSON(api.t, w, edgecloud.TaskResponse{Tasks: []string{taskID}})
+}
+
+// resourcePath returns the path of the resources of the test project and region.
+func resourcePath(resource string, elem ...string) string {
+	return path.Join(append([]string{resource, strconv.Itoa(projectID), strconv.Itoa(regionID)}, elem...)...)
+}
+
 func TestResourceIsDeleted(t *testing.T) {
 	tests := []struct {
 		name       string
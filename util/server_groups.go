@@ -3,25 +3,368 @@ package util
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"time"
 
 	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
 )
 
-var ErrServerGroupNotFound = errors.New("no server group was found for the specified search criteria")
+// ServerGroupMetadataKey is the instance metadata key with the ID or the name of the server group the instance should be in.
+const ServerGroupMetadataKey = "server_group"
 
+var (
+	ErrServerGroupNotFound  = errors.New("no server group was found for the specified search criteria")
+	ErrServerGroupAmbiguous = errors.New("several server groups have the same name")
+)
+
+// ServerGroupGetByInstance returns the server group of the instance. It lists all the server groups,
+// use ServerGroupIndex to look up several instances.
 func ServerGroupGetByInstance(ctx context.Context, client *edgecloud.Client, instanceID string) (*edgecloud.ServerGroup, error) {
+	index, err := BuildServerGroupIndex(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	sg, ok := index.ByInstance(instanceID)
+	if !ok {
+		return nil, ErrServerGroupNotFound
+	}
+
+	return sg, nil
+}
+
+// ServerGroupIndex looks up server groups by ID, name and member instance.
+type ServerGroupIndex struct {
+	groups     []edgecloud.ServerGroup
+	byID       map[string]int
+	byName     map[string][]int
+	byInstance map[string]int
+}
+
+// BuildServerGroupIndex lists the server groups of the project and indexes them.
+func BuildServerGroupIndex(ctx context.Context, client *edgecloud.Client) (*ServerGroupIndex, error) {
 	sgs, _, err := client.ServerGroups.List(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, sg := range sgs {
+	return NewServerGroupIndex(sgs), nil
+}
+
+// NewServerGroupIndex indexes already listed server groups.
+func NewServerGroupIndex(sgs []edgecloud.ServerGroup) *ServerGroupIndex {
+	index := &ServerGroupIndex{
+		groups:     sgs,
+		byID:       make(map[string]int, len(sgs)),
+		byName:     make(map[string][]int, len(sgs)),
+		byInstance: make(map[string]int),
+	}
+
+	for i, sg := range sgs {
+		index.byID[sg.ID] = i
+		index.byName[sg.Name] = append(index.byName[sg.Name], i)
 		for _, instance := range sg.Instances {
-			if instance.InstanceID == instanceID {
-				return &sg, nil
+			index.byInstance[instance.InstanceID] = i
+		}
+	}
+
+	return index
+}
+
+// Groups returns the indexed server groups.
+func (idx *ServerGroupIndex) Groups() []edgecloud.ServerGroup {
+	return idx.groups
+}
+
+// ByID returns the server group with the ID.
+func (idx *ServerGroupIndex) ByID(id string) (*edgecloud.ServerGroup, bool) {
+	i, ok := idx.byID[id]
+	if !ok {
+		return nil, false
+	}
+
+	return &idx.groups[i], true
+}
+
+// ByInstance returns the server group the instance is a member of.
+func (idx *ServerGroupIndex) ByInstance(instanceID string) (*edgecloud.ServerGroup, bool) {
+	i, ok := idx.byInstance[instanceID]
+	if !ok {
+		return nil, false
+	}
+
+	return &idx.groups[i], true
+}
+
+// Resolve returns the server group with the ID or, failing that, with the name.
+func (idx *ServerGroupIndex) Resolve(idOrName string) (*edgecloud.ServerGroup, error) {
+	if sg, ok := idx.ByID(idOrName); ok {
+		return sg, nil
+	}
+
+	switch found := idx.byName[idOrName]; len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrServerGroupNotFound, idOrName)
+	case 1:
+		return &idx.groups[found[0]], nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrServerGroupAmbiguous, idOrName)
+	}
+}
+
+// ServerGroupIssueKind is the kind of a problem found by the server group audit.
+type ServerGroupIssueKind string
+
+const (
+	// ServerGroupIssueTooManyMembers is a server group with more members than allowed for its policy.
+	ServerGroupIssueTooManyMembers ServerGroupIssueKind = "too_many_members"
+	// ServerGroupIssueUngrouped is an instance that is in no server group, but should be by its metadata.
+	ServerGroupIssueUngrouped ServerGroupIssueKind = "ungrouped"
+	// ServerGroupIssueMisplaced is an instance that is in another server group than its metadata says.
+	ServerGroupIssueMisplaced ServerGroupIssueKind = "misplaced"
+	// ServerGroupIssueUnknownGroup is an instance whose metadata refers to a missing or ambiguous server group.
+	ServerGroupIssueUnknownGroup ServerGroupIssueKind = "unknown_group"
+	// ServerGroupIssueOrphaned is a server group without members.
+	ServerGroupIssueOrphaned ServerGroupIssueKind = "orphaned"
+	// ServerGroupIssueStaleMember is a member of a server group that is not in the instance list.
+	ServerGroupIssueStaleMember ServerGroupIssueKind = "stale_member"
+)
+
+// ServerGroupIssue is a problem found by the server group audit.
+type ServerGroupIssue struct {
+	Kind          ServerGroupIssueKind
+	ServerGroupID string
+	InstanceID    string
+	// TargetServerGroupID is the server group the instance should be in, for ungrouped and misplaced instances.
+	TargetServerGroupID string
+	Detail              string
+}
+
+func (i ServerGroupIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Kind, i.Detail)
+}
+
+// ServerGroupAuditOptions specifies the optional parameters to AuditServerGroups.
+type ServerGroupAuditOptions struct {
+	// MetadataKey is the instance metadata key with the server group ID or name, ServerGroupMetadataKey by default.
+	MetadataKey string
+	// MaxMembers limits the number of members of the server groups by policy, for example by the number
+	// of hypervisors an anti-affinity group can spread over. Policies without a limit are not checked.
+	MaxMembers map[edgecloud.ServerGroupPolicy]int
+}
+
+// ServerGroupAuditReport is the result of the server group audit.
+type ServerGroupAuditReport struct {
+	Issues []ServerGroupIssue
+
+	index      *ServerGroupIndex
+	maxMembers map[edgecloud.ServerGroupPolicy]int
+}
+
+// OK reports whether no issues were found.
+func (r *ServerGroupAuditReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// AuditServerGroups lists the server groups and the instances of the project and audits them.
+func AuditServerGroups(ctx context.Context, client *edgecloud.Client, opts *ServerGroupAuditOptions) (*ServerGroupAuditReport, error) {
+	index, err := BuildServerGroupIndex(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, _, err := client.Instances.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewServerGroupAudit(index, instances, opts), nil
+}
+
+// NewServerGroupAudit audits the indexed server groups against their policies and the instance metadata.
+// The instances must be all the instances of the project, members missing from them are reported as stale.
+func NewServerGroupAudit(index *ServerGroupIndex, instances []edgecloud.Instance, opts *ServerGroupAuditOptions) *ServerGroupAuditReport {
+	if opts == nil {
+		opts = &ServerGroupAuditOptions{}
+	}
+
+	metadataKey := opts.MetadataKey
+	if metadataKey == "" {
+		metadataKey = ServerGroupMetadataKey
+	}
+
+	report := &ServerGroupAuditReport{index: index, maxMembers: opts.MaxMembers}
+
+	listed := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		listed[instance.ID] = true
+	}
+
+	for _, sg := range index.Groups() {
+		if len(sg.Instances) == 0 {
+			report.Issues = append(report.Issues, ServerGroupIssue{
+				Kind:          ServerGroupIssueOrphaned,
+				ServerGroupID: sg.ID,
+				Detail:        fmt.Sprintf("server group %s (%s) has no members", sg.Name, sg.ID),
+			})
+			continue
+		}
+
+		if limit, ok := opts.MaxMembers[sg.Policy]; ok && len(sg.Instances) > limit {
+			report.Issues = append(report.Issues, ServerGroupIssue{
+				Kind:          ServerGroupIssueTooManyMembers,
+				ServerGroupID: sg.ID,
+				Detail:        fmt.Sprintf("%s server group %s (%s) has %d members, the limit is %d", sg.Policy, sg.Name, sg.ID, len(sg.Instances), limit),
+			})
+		}
+
+		for _, member := range sg.Instances {
+			if !listed[member.InstanceID] {
+				report.Issues = append(report.Issues, ServerGroupIssue{
+					Kind:          ServerGroupIssueStaleMember,
+					ServerGroupID: sg.ID,
+					InstanceID:    member.InstanceID,
+					Detail:        fmt.Sprintf("server group %s (%s) lists the missing instance %s", sg.Name, sg.ID, member.InstanceID),
+				})
 			}
 		}
 	}
 
-	return nil, ErrServerGroupNotFound
+	for _, instance := range instances {
+		target, ok := instance.Metadata[metadataKey]
+		if !ok || target == "" {
+			continue
+		}
+
+		issue := ServerGroupIssue{InstanceID: instance.ID}
+		current, grouped := index.ByInstance(instance.ID)
+		if grouped {
+			issue.ServerGroupID = current.ID
+		}
+
+		want, err := index.Resolve(target)
+		switch {
+		case err != nil:
+			issue.Kind = ServerGroupIssueUnknownGroup
+			issue.Detail = fmt.Sprintf("instance %s (%s): %v", instance.Name, instance.ID, err)
+		case !grouped:
+			issue.Kind = ServerGroupIssueUngrouped
+			issue.TargetServerGroupID = want.ID
+			issue.Detail = fmt.Sprintf("instance %s (%s) is not in the server group %s", instance.Name, instance.ID, want.Name)
+		case current.ID != want.ID:
+			issue.Kind = ServerGroupIssueMisplaced
+			issue.TargetServerGroupID = want.ID
+			issue.Detail = fmt.Sprintf("instance %s (%s) is in the server group %s instead of %s", instance.Name, instance.ID, current.Name, want.Name)
+		default:
+			continue
+		}
+
+		report.Issues = append(report.Issues, issue)
+	}
+
+	return report
+}
+
+// ServerGroupMove moves an instance between server groups. An empty From puts an ungrouped instance
+// into a group, an empty To removes the instance from its group.
+type ServerGroupMove struct {
+	InstanceID string
+	From       string
+	To         string
+}
+
+// PlanMoves returns the moves fixing the ungrouped and misplaced instances of the report. Moves that would
+// exceed the member limit of the target group are not planned, and the issues of over-populated groups
+// are left to the caller, since the audit cannot tell which members to give up.
+func (r *ServerGroupAuditReport) PlanMoves() []ServerGroupMove {
+	members := make(map[string]int, len(r.index.Groups()))
+	for _, sg := range r.index.Groups() {
+		members[sg.ID] = len(sg.Instances)
+	}
+
+	var moves []ServerGroupMove
+	for _, issue := range r.Issues {
+		if issue.Kind != ServerGroupIssueUngrouped && issue.Kind != ServerGroupIssueMisplaced {
+			continue
+		}
+
+		target, _ := r.index.ByID(issue.TargetServerGroupID)
+		if limit, ok := r.maxMembers[target.Policy]; ok && members[target.ID] >= limit {
+			continue
+		}
+
+		moves = append(moves, ServerGroupMove{InstanceID: issue.InstanceID, From: issue.ServerGroupID, To: target.ID})
+		members[target.ID]++
+		if issue.ServerGroupID != "" {
+			members[issue.ServerGroupID]--
+		}
+	}
+
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].To < moves[j].To
+	})
+
+	return moves
+}
+
+// ServerGroupMoveOptions specifies the optional parameters to ServerGroupMovesExecute.
+type ServerGroupMoveOptions struct {
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for the instance to return to its status.
+	Attempts *uint
+}
+
+// ServerGroupMovesExecute runs the moves one by one and stops on the first error. It returns the completed moves.
+func ServerGroupMovesExecute(ctx context.Context, client *edgecloud.Client, moves []ServerGroupMove, opts *ServerGroupMoveOptions) ([]ServerGroupMove, error) {
+	done := make([]ServerGroupMove, 0, len(moves))
+	for _, move := range moves {
+		if err := ServerGroupMoveAndWait(ctx, client, move, opts); err != nil {
+			return done, fmt.Errorf("instance %s: %w", move.InstanceID, err)
+		}
+		done = append(done, move)
+	}
+
+	return done, nil
+}
+
+// ServerGroupMoveAndWait removes the instance from its server group, puts it into the new one and waits
+// for the instance to return to the status it had before the move.
+func ServerGroupMoveAndWait(ctx context.Context, client *edgecloud.Client, move ServerGroupMove, opts *ServerGroupMoveOptions) error {
+	if opts == nil {
+		opts = &ServerGroupMoveOptions{}
+	}
+
+	instance, _, err := client.Instances.Get(ctx, move.InstanceID)
+	if err != nil {
+		return err
+	}
+	target := InstanceTargetState{Status: instance.Status, VMState: instance.VMState}
+
+	if move.From != "" {
+		task, _, err := client.Instances.RemoveFromServerGroup(ctx, move.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...); err != nil {
+			return err
+		}
+	}
+
+	if move.To != "" {
+		task, _, err := client.Instances.PutIntoServerGroup(ctx, move.InstanceID, &edgecloud.InstancePutIntoServerGroupRequest{ServerGroupID: move.To})
+		if err != nil {
+			return err
+		}
+
+		if err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...); err != nil {
+			return err
+		}
+	}
+
+	_, err = WaitForInstanceState(ctx, client, move.InstanceID, target, opts.Attempts)
+
+	return err
 }
@@ -9,9 +9,11 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
 )
@@ -95,3 +97,132 @@ func TestServerGroupGetByInstance_CustomError(t *testing.T) {
 	assert.ErrorIs(t, err, ErrServerGroupNotFound)
 	assert.Nil(t, sgs)
 }
+
+func testServerGroups() []edgecloud.ServerGroup {
+	return []edgecloud.ServerGroup{
+		{
+			ID:     testResourceID,
+			Name:   "web",
+			Policy: edgecloud.ServerGroupPolicyAntiAffinity,
+			Instances: []edgecloud.ServerGroupInstance{
+				{InstanceID: testInstanceID2},
+				{InstanceID: testResourceID3},
+			},
+		},
+		{
+			ID:        testResourceID2,
+			Name:      "db",
+			Policy:    edgecloud.ServerGroupPolicyAffinity,
+			Instances: []edgecloud.ServerGroupInstance{{InstanceID: testVolumeID}},
+		},
+		{ID: testSnapshotID, Name: "unused", Policy: edgecloud.ServerGroupPolicyAffinity},
+	}
+}
+
+func TestServerGroupIndex(t *testing.T) {
+	index := NewServerGroupIndex(append(testServerGroups(), edgecloud.ServerGroup{ID: testSnapshotID2, Name: "web"}))
+
+	sg, ok := index.ByInstance(testResourceID3)
+	require.True(t, ok)
+	assert.Equal(t, testResourceID, sg.ID)
+
+	_, ok = index.ByInstance(testResourceID)
+	assert.False(t, ok)
+
+	sg, err := index.Resolve("db")
+	require.NoError(t, err)
+	assert.Equal(t, testResourceID2, sg.ID)
+
+	sg, err = index.Resolve(testResourceID)
+	require.NoError(t, err)
+	assert.Equal(t, "web", sg.Name)
+
+	_, err = index.Resolve("web")
+	assert.ErrorIs(t, err, ErrServerGroupAmbiguous)
+
+	_, err = index.Resolve("cache")
+	assert.ErrorIs(t, err, ErrServerGroupNotFound)
+}
+
+func TestNewServerGroupAudit(t *testing.T) {
+	instances := []edgecloud.Instance{
+		{ID: testInstanceID2, Name: "web-1", Metadata: edgecloud.Metadata{ServerGroupMetadataKey: "web"}},
+		{ID: testResourceID3, Name: "web-2", Metadata: edgecloud.Metadata{ServerGroupMetadataKey: testResourceID}},
+		{ID: testResourceID, Name: "web-3", Metadata: edgecloud.Metadata{ServerGroupMetadataKey: "web"}},
+		{ID: testSubnetID, Name: "db-1", Metadata: edgecloud.Metadata{ServerGroupMetadataKey: "db"}},
+		{ID: testNetworkID, Name: "cache-1", Metadata: edgecloud.Metadata{ServerGroupMetadataKey: "cache"}},
+		{ID: testFlavorID, Name: "other"},
+	}
+
+	groups := testServerGroups()
+	groups[0].Instances = append(groups[0].Instances, edgecloud.ServerGroupInstance{InstanceID: testSubnetID})
+	report := NewServerGroupAudit(NewServerGroupIndex(groups), instances, &ServerGroupAuditOptions{
+		MaxMembers: map[edgecloud.ServerGroupPolicy]int{edgecloud.ServerGroupPolicyAntiAffinity: 3},
+	})
+
+	kinds := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		kinds = append(kinds, fmt.Sprintf("%s %s %s", issue.Kind, issue.InstanceID, issue.TargetServerGroupID))
+	}
+	assert.Equal(t, []string{
+		"stale_member " + testVolumeID + " ",
+		"orphaned  ",
+		"ungrouped " + testResourceID + " " + testResourceID,
+		"misplaced " + testSubnetID + " " + testResourceID2,
+		"unknown_group " + testNetworkID + " ",
+	}, kinds)
+	assert.False(t, report.OK())
+
+	// web-3 does not fit into the anti-affinity group until db-1 leaves it
+	assert.Equal(t, []ServerGroupMove{
+		{InstanceID: testSubnetID, From: testResourceID, To: testResourceID2},
+	}, report.PlanMoves())
+
+	report = NewServerGroupAudit(NewServerGroupIndex(groups), instances, &ServerGroupAuditOptions{
+		MaxMembers: map[edgecloud.ServerGroupPolicy]int{edgecloud.ServerGroupPolicyAntiAffinity: 2},
+	})
+	assert.Equal(t, ServerGroupIssueTooManyMembers, report.Issues[0].Kind)
+	assert.Equal(t, []ServerGroupMove{
+		{InstanceID: testSubnetID, From: testResourceID, To: testResourceID2},
+	}, report.PlanMoves())
+}
+
+func TestServerGroupMovesExecute(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeInstancesAPI(t, mux, []edgecloud.Instance{
+		{ID: testInstanceID2, Status: edgecloud.InstanceStatusActive},
+		{ID: testResourceID3, Status: edgecloud.InstanceStatusShutoff},
+	})
+
+	var calls []string
+	URLList := path.Join("/v1/instances", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	for _, id := range []string{testInstanceID2, testResourceID3} {
+		for _, action := range []string{"remove_from_servergroup", "put_into_servergroup"} {
+			id, action := id, action
+			mux.HandleFunc(path.Join(URLList, id, action), func(w http.ResponseWriter, r *http.Request) {
+				api.mu.Lock()
+				defer api.mu.Unlock()
+
+				var reqBody edgecloud.InstancePutIntoServerGroupRequest
+				_ = json.NewDecoder(r.Body).Decode(&reqBody)
+				calls = append(calls, strings.TrimSpace(fmt.Sprintf("%s %s %s", action, id, reqBody.ServerGroupID)))
+				api.writeTask(w, nil)
+			})
+		}
+	}
+
+	done, err := ServerGroupMovesExecute(context.Background(), newTestClient(server.URL), []ServerGroupMove{
+		{InstanceID: testInstanceID2, To: testResourceID},
+		{InstanceID: testResourceID3, From: testResourceID, To: testResourceID2},
+	}, &ServerGroupMoveOptions{Attempts: &attempts})
+	require.NoError(t, err)
+	assert.Len(t, done, 2)
+	assert.Equal(t, []string{
+		"put_into_servergroup " + testInstanceID2 + " " + testResourceID,
+		"remove_from_servergroup " + testResourceID3,
+		"put_into_servergroup " + testResourceID3 + " " + testResourceID2,
+	}, calls)
+}
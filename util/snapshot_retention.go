@@ -0,0 +1,261 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var ErrSnapshotRetentionEmpty = errors.New("the retention policy keeps no snapshots")
+
+// SnapshotRetentionPolicy is a grandfather-father-son retention policy. For every period kind the newest
+// snapshot of each of the last N periods with a snapshot is kept. A snapshot can be kept by several rules.
+type SnapshotRetentionPolicy struct {
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+	// Location is the timezone of the period boundaries, UTC by default.
+	Location *time.Location
+}
+
+// snapshotRetentionRule is a period kind of the policy.
+type snapshotRetentionRule struct {
+	name   string
+	count  int
+	bucket func(t time.Time) string
+}
+
+func (p SnapshotRetentionPolicy) rules() []snapshotRetentionRule {
+	return []snapshotRetentionRule{
+		{name: "hourly", count: p.Hourly, bucket: func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{name: "daily", count: p.Daily, bucket: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{name: "weekly", count: p.Weekly, bucket: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{name: "monthly", count: p.Monthly, bucket: func(t time.Time) string { return t.Format("2006-01") }},
+		{name: "yearly", count: p.Yearly, bucket: func(t time.Time) string { return t.Format("2006") }},
+	}
+}
+
+// Validate checks that the policy keeps at least one period.
+func (p SnapshotRetentionPolicy) Validate() error {
+	if p.Hourly < 0 || p.Daily < 0 || p.Weekly < 0 || p.Monthly < 0 || p.Yearly < 0 {
+		return fmt.Errorf("%w: negative count", ErrSnapshotRetentionEmpty)
+	}
+
+	if p.Hourly+p.Daily+p.Weekly+p.Monthly+p.Yearly == 0 {
+		return ErrSnapshotRetentionEmpty
+	}
+
+	return nil
+}
+
+// SnapshotRetentionDecision tells whether a snapshot is kept and why.
+type SnapshotRetentionDecision struct {
+	Snapshot edgecloud.Snapshot
+	// Created is the parsed creation time of the snapshot, zero if it cannot be parsed.
+	Created time.Time
+	Keep    bool
+	// Reasons are the rules keeping the snapshot.
+	Reasons []string
+}
+
+// PlanSnapshotRetention applies the policy to the snapshots of every volume separately. The newest snapshot of
+// every volume is always kept, as well as the snapshots that are not available or whose creation time cannot
+// be parsed. The decisions are ordered by volume and from the newest to the oldest snapshot.
+func PlanSnapshotRetention(snapshots []edgecloud.Snapshot, policy SnapshotRetentionPolicy) ([]SnapshotRetentionDecision, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	location := policy.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	byVolume := make(map[string][]SnapshotRetentionDecision)
+	for _, snapshot := range snapshots {
+		decision := SnapshotRetentionDecision{Snapshot: snapshot}
+		created, err := parseAPITime(snapshot.CreatedAt)
+		switch {
+		case err != nil:
+			decision.Keep, decision.Reasons = true, []string{"unknown creation time"}
+		case snapshot.Status != edgecloud.SnapshotStatusAvailable:
+			decision.Created = created.In(location)
+			decision.Keep, decision.Reasons = true, []string{"status " + snapshot.Status.String()}
+		default:
+			decision.Created = created.In(location)
+		}
+		byVolume[snapshot.VolumeID] = append(byVolume[snapshot.VolumeID], decision)
+	}
+
+	decisions := make([]SnapshotRetentionDecision, 0, len(snapshots))
+	for _, volumeID := range sortedKeys(byVolume) {
+		volumeDecisions := byVolume[volumeID]
+		sort.SliceStable(volumeDecisions, func(i, j int) bool {
+			return volumeDecisions[i].Created.After(volumeDecisions[j].Created)
+		})
+
+		planVolumeRetention(volumeDecisions, policy)
+		decisions = append(decisions, volumeDecisions...)
+	}
+
+	return decisions, nil
+}
+
+// planVolumeRetention marks the kept snapshots of a volume, ordered from the newest.
+func planVolumeRetention(decisions []SnapshotRetentionDecision, policy SnapshotRetentionPolicy) {
+	newest := true
+	for i := range decisions {
+		if !decisions[i].Created.IsZero() && newest {
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, "newest")
+			newest = false
+		}
+	}
+
+	for _, rule := range policy.rules() {
+		if rule.count == 0 {
+			continue
+		}
+
+		kept, last := 0, ""
+		for i := range decisions {
+			d := &decisions[i]
+			if d.Created.IsZero() || d.Snapshot.Status != edgecloud.SnapshotStatusAvailable {
+				continue
+			}
+
+			bucket := rule.bucket(d.Created)
+			if bucket == last {
+				continue
+			}
+
+			last = bucket
+			d.Keep = true
+			d.Reasons = append(d.Reasons, rule.name)
+			if kept++; kept == rule.count {
+				break
+			}
+		}
+	}
+}
+
+// SnapshotRetentionOptions specifies the parameters to PruneSnapshots.
+type SnapshotRetentionOptions struct {
+	Policy SnapshotRetentionPolicy
+	// NamePrefix limits the pruned snapshots to the ones with the name prefix.
+	NamePrefix string
+	// Metadata limits the pruned snapshots to the ones with all the metadata key-value pairs.
+	Metadata edgecloud.Metadata
+	// DryRun only plans the retention without deleting any snapshot.
+	DryRun bool
+	// Timeout is the maximum time to wait for each deletion task.
+	Timeout time.Duration
+}
+
+// SnapshotRetentionResult is the result of PruneSnapshots.
+type SnapshotRetentionResult struct {
+	Decisions []SnapshotRetentionDecision
+	DryRun    bool
+	// Deleted are the IDs of the deleted snapshots, empty in dry-run mode.
+	Deleted []string
+}
+
+// PruneSnapshots lists the snapshots of the volume, or of all the volumes if volumeID is empty, applies the
+// retention policy to the ones matching the filters and deletes the rest. The deletion continues after
+// an error, the errors are joined.
+func PruneSnapshots(ctx context.Context, client *edgecloud.Client, volumeID string, opts *SnapshotRetentionOptions) (*SnapshotRetentionResult, error) {
+	if opts == nil {
+		return nil, ErrSnapshotRetentionEmpty
+	}
+
+	snapshots, _, err := client.Snapshots.List(ctx, &edgecloud.SnapshotListOptions{VolumeID: volumeID})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]edgecloud.Snapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if snapshotMatches(snapshot, opts.NamePrefix, opts.Metadata) {
+			selected = append(selected, snapshot)
+		}
+	}
+
+	decisions, err := PlanSnapshotRetention(selected, opts.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SnapshotRetentionResult{Decisions: decisions, DryRun: opts.DryRun}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	var errs []error
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+
+		task, _, err := client.Snapshots.Delete(ctx, d.Snapshot.ID)
+		if err == nil {
+			err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("snapshot %s: %w", d.Snapshot.ID, err))
+			continue
+		}
+		result.Deleted = append(result.Deleted, d.Snapshot.ID)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// Write writes a line per snapshot with the decision and its reasons.
+func (r *SnapshotRetentionResult) Write(w io.Writer) error {
+	for _, d := range r.Decisions {
+		action := "keep"
+		switch {
+		case d.Keep:
+		case r.DryRun:
+			action = "would delete"
+		default:
+			action = "delete"
+		}
+
+		line := fmt.Sprintf("%s\t%s\t%s\t%s", d.Snapshot.VolumeID, d.Snapshot.ID, d.Snapshot.CreatedAt, action)
+		if len(d.Reasons) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(d.Reasons, ", "))
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func snapshotMatches(snapshot edgecloud.Snapshot, namePrefix string, metadata edgecloud.Metadata) bool {
+	if !strings.HasPrefix(snapshot.Name, namePrefix) {
+		return false
+	}
+
+	for k, v := range metadata {
+		if snapshot.Metadata[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
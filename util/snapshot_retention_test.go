@@ -0,0 +1,130 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// retentionSnapshotID returns a UUID ending with the short name of the test snapshot.
+func retentionSnapshotID(name string) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012x", name)
+}
+
+func retentionSnapshotIDs(names ...string) []string {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		ids = append(ids, retentionSnapshotID(name))
+	}
+
+	return ids
+}
+
+func testRetentionSnapshots() []edgecloud.Snapshot {
+	snapshot := func(id, volumeID, createdAt string, status edgecloud.SnapshotStatus) edgecloud.Snapshot {
+		return edgecloud.Snapshot{ID: retentionSnapshotID(id), VolumeID: volumeID, Name: "backup-" + id, CreatedAt: createdAt, Status: status, Metadata: edgecloud.Metadata{"policy": "compliance"}}
+	}
+
+	return []edgecloud.Snapshot{
+		snapshot("s6", testVolumeID, "2024-01-02T12:00:00Z", edgecloud.SnapshotStatusAvailable),
+		snapshot("s1", testVolumeID, "2024-01-10T12:00:00Z", edgecloud.SnapshotStatusAvailable),
+		snapshot("s2", testVolumeID, "2024-01-10 03:00:00", edgecloud.SnapshotStatusAvailable),
+		snapshot("s3", testVolumeID, "2024-01-09T12:00:00+0000", edgecloud.SnapshotStatusAvailable),
+		snapshot("s4", testVolumeID, "2024-01-08T12:00:00Z", edgecloud.SnapshotStatusAvailable),
+		snapshot("s5", testVolumeID, "2024-01-07T12:00:00Z", edgecloud.SnapshotStatusAvailable),
+		snapshot("s7", testVolumeID, "2023-12-31T12:00:00Z", edgecloud.SnapshotStatusAvailable),
+		snapshot("s8", testVolumeID, "yesterday", edgecloud.SnapshotStatusAvailable),
+		snapshot("s9", testVolumeID, "2023-12-01T12:00:00Z", "error"),
+		snapshot("t1", testResourceID3, "2020-01-01T00:00:00Z", edgecloud.SnapshotStatusAvailable),
+	}
+}
+
+func TestPlanSnapshotRetention(t *testing.T) {
+	decisions, err := PlanSnapshotRetention(testRetentionSnapshots(), SnapshotRetentionPolicy{Daily: 2, Weekly: 2})
+	require.NoError(t, err)
+
+	actual := make(map[string][]string, len(decisions))
+	var deleted []string
+	for _, d := range decisions {
+		if !d.Keep {
+			deleted = append(deleted, d.Snapshot.Name)
+			continue
+		}
+		actual[d.Snapshot.Name] = d.Reasons
+	}
+
+	assert.Equal(t, []string{"backup-s2", "backup-s4", "backup-s6", "backup-s7"}, deleted)
+	assert.Equal(t, map[string][]string{
+		"backup-s1": {"newest", "daily", "weekly"},
+		"backup-s3": {"daily"},
+		"backup-s5": {"weekly"},
+		"backup-s8": {"unknown creation time"},
+		"backup-s9": {"status error"},
+		"backup-t1": {"newest", "daily", "weekly"},
+	}, actual)
+
+	_, err = PlanSnapshotRetention(testRetentionSnapshots(), SnapshotRetentionPolicy{})
+	assert.ErrorIs(t, err, ErrSnapshotRetentionEmpty)
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var deleted []string
+	// the snapshots of the volume and a manual snapshot without the name prefix
+	snapshots := append(testRetentionSnapshots()[:9], edgecloud.Snapshot{ID: testSnapshotID, Name: "manual", VolumeID: testVolumeID, CreatedAt: "2020-01-01T00:00:00Z", Status: edgecloud.SnapshotStatusAvailable})
+
+	URL := path.Join("/v1/snapshots", strconv.Itoa(projectID), strconv.Itoa(regionID))
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, testVolumeID, r.URL.Query().Get("volume_id"))
+		writeTestJSON(t, w, map[string]interface{}{"results": snapshots})
+	})
+	mux.HandleFunc(URL+"/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, http.MethodDelete, r.Method)
+		deleted = append(deleted, path.Base(r.URL.Path))
+		writeTestJSON(t, w, edgecloud.TaskResponse{Tasks: []string{testTaskID}})
+	})
+	mux.HandleFunc(path.Join("/v1/tasks", testTaskID), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Task{ID: testTaskID, State: edgecloud.TaskStateFinished})
+	})
+
+	opts := &SnapshotRetentionOptions{
+		Policy:     SnapshotRetentionPolicy{Daily: 2, Weekly: 2},
+		NamePrefix: "backup-",
+		Metadata:   edgecloud.Metadata{"policy": "compliance"},
+		DryRun:     true,
+	}
+
+	result, err := PruneSnapshots(context.Background(), newTestClient(server.URL), testVolumeID, opts)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+	assert.Empty(t, result.Deleted)
+
+	var out bytes.Buffer
+	require.NoError(t, result.Write(&out))
+	assert.Contains(t, out.String(), testVolumeID+"\t"+retentionSnapshotID("s1")+"\t2024-01-10T12:00:00Z\tkeep (newest, daily, weekly)\n")
+	assert.Contains(t, out.String(), testVolumeID+"\t"+retentionSnapshotID("s2")+"\t2024-01-10 03:00:00\twould delete\n")
+
+	opts.DryRun = false
+	result, err = PruneSnapshots(context.Background(), newTestClient(server.URL), testVolumeID, opts)
+	require.NoError(t, err)
+	assert.Equal(t, retentionSnapshotIDs("s2", "s4", "s6", "s7"), deleted)
+	assert.Equal(t, deleted, result.Deleted)
+}
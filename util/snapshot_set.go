@@ -0,0 +1,305 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// Metadata keys of the snapshots of a snapshot set.
+const (
+	SnapshotSetMetadataKey           = "snapshot_set"
+	SnapshotSetInstanceMetadataKey   = "snapshot_set_instance"
+	SnapshotSetDeviceMetadataKey     = "snapshot_set_device"
+	SnapshotSetVolumeNameMetadataKey = "snapshot_set_volume_name"
+	SnapshotSetVolumeTypeMetadataKey = "snapshot_set_volume_type"
+	SnapshotSetBootableMetadataKey   = "snapshot_set_bootable"
+)
+
+var (
+	ErrSnapshotSetNotFound       = errors.New("snapshot set not found")
+	ErrSnapshotSetInvalidQuiesce = errors.New("the instance can only be stopped or suspended for a snapshot set")
+)
+
+// snapshotSetQuiesceActions map the actions quiescing the instance to the actions bringing it back.
+var snapshotSetQuiesceActions = map[InstanceActionType]InstanceActionType{
+	InstanceActionStop:    InstanceActionStart,
+	InstanceActionSuspend: InstanceActionResume,
+}
+
+// SnapshotSetMember is a snapshot of a volume in a snapshot set.
+type SnapshotSetMember struct {
+	SnapshotID string
+	VolumeID   string
+	VolumeName string
+	VolumeType edgecloud.VolumeType
+	// Device is the device of the volume in the instance at the time of the snapshot.
+	Device   string
+	Bootable bool
+	Size     int
+}
+
+// SnapshotSet is a set of snapshots of all the volumes of an instance taken together.
+type SnapshotSet struct {
+	ID         string
+	InstanceID string
+	// Snapshots are ordered by device.
+	Snapshots []SnapshotSetMember
+}
+
+// SnapshotSetOptions specifies the optional parameters to CreateSnapshotSet.
+type SnapshotSetOptions struct {
+	// Quiesce is the action applied to the instance while the snapshots are taken, stop or suspend.
+	// The instance is started or resumed afterwards, if it was active. The instance keeps running if empty.
+	Quiesce InstanceActionType
+	// Name is the prefix of the snapshot names, the instance name by default.
+	Name string
+	// Metadata is added to the metadata of every snapshot.
+	Metadata edgecloud.Metadata
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for the instance state and the snapshots.
+	Attempts *uint
+}
+
+// CreateSnapshotSet snapshots all the volumes of the instance in parallel and tags the snapshots with a shared
+// set ID. With opts.Quiesce the instance is stopped or suspended until all the snapshots are ready, so that the
+// volumes are consistent with each other. If any snapshot fails, the snapshots of the set are deleted.
+func CreateSnapshotSet(ctx context.Context, client *edgecloud.Client, instanceID string, opts *SnapshotSetOptions) (set *SnapshotSet, err error) {
+	if opts == nil {
+		opts = &SnapshotSetOptions{}
+	}
+
+	resumeAction, ok := snapshotSetQuiesceActions[opts.Quiesce]
+	if opts.Quiesce != "" && !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotSetInvalidQuiesce, opts.Quiesce)
+	}
+
+	instance, _, err := client.Instances.Get(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, _, err := client.Volumes.List(ctx, &edgecloud.VolumeListOptions{InstanceID: instanceID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceHasNoVolumes, instanceID)
+	}
+
+	if opts.Quiesce != "" && instance.Status == edgecloud.InstanceStatusActive {
+		if _, err = InstanceActionAndWait(ctx, client, instanceID, opts.Quiesce, opts.Attempts); err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			_, resumeErr := InstanceActionAndWait(ctx, client, instanceID, resumeAction, opts.Attempts)
+			err = errors.Join(err, resumeErr)
+		}()
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = instance.Name
+	}
+
+	set = &SnapshotSet{ID: uuid.NewString(), InstanceID: instanceID, Snapshots: make([]SnapshotSetMember, len(volumes))}
+	errs := make([]error, len(volumes))
+
+	var wg sync.WaitGroup
+	for i, volume := range volumes {
+		wg.Add(1)
+		go func(i int, volume edgecloud.Volume) {
+			defer wg.Done()
+
+			member := SnapshotSetMember{
+				VolumeID:   volume.ID,
+				VolumeName: volume.Name,
+				VolumeType: volume.VolumeType,
+				Bootable:   volume.Bootable,
+				Size:       volume.Size,
+			}
+			if attachment := volumeAttachment(&volume, instanceID); attachment != nil {
+				member.Device = attachment.Device
+			}
+
+			member.SnapshotID, errs[i] = CreateSnapshotAndWait(ctx, client, &edgecloud.SnapshotCreateRequest{
+				VolumeID:    volume.ID,
+				Name:        fmt.Sprintf("%s-%s", name, volume.Name),
+				Description: fmt.Sprintf("snapshot set %s of instance %s", set.ID, instanceID),
+				Metadata:    snapshotSetMetadata(set, member, opts.Metadata),
+			}, opts.Attempts, nonZeroTimeouts(opts.Timeout)...)
+			set.Snapshots[i] = member
+		}(i, volume)
+	}
+	wg.Wait()
+
+	if err = errors.Join(errs...); err != nil {
+		return nil, errors.Join(err, deleteSnapshotSet(ctx, client, set, opts.Timeout))
+	}
+
+	sortSnapshotSet(set)
+
+	return set, nil
+}
+
+func snapshotSetMetadata(set *SnapshotSet, member SnapshotSetMember, extra edgecloud.Metadata) edgecloud.Metadata {
+	metadata := make(edgecloud.Metadata, len(extra)+6)
+	for k, v := range extra {
+		metadata[k] = v
+	}
+
+	metadata[SnapshotSetMetadataKey] = set.ID
+	metadata[SnapshotSetInstanceMetadataKey] = set.InstanceID
+	metadata[SnapshotSetDeviceMetadataKey] = member.Device
+	metadata[SnapshotSetVolumeNameMetadataKey] = member.VolumeName
+	metadata[SnapshotSetVolumeTypeMetadataKey] = string(member.VolumeType)
+	metadata[SnapshotSetBootableMetadataKey] = strconv.FormatBool(member.Bootable)
+
+	return metadata
+}
+
+// deleteSnapshotSet deletes the created snapshots of the set.
+func deleteSnapshotSet(ctx context.Context, client *edgecloud.Client, set *SnapshotSet, timeout time.Duration) error {
+	var errs []error
+	for _, member := range set.Snapshots {
+		if member.SnapshotID == "" {
+			continue
+		}
+
+		task, _, err := client.Snapshots.Delete(ctx, member.SnapshotID)
+		if err == nil {
+			err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(timeout)...)
+		}
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func sortSnapshotSet(set *SnapshotSet) {
+	sort.SliceStable(set.Snapshots, func(i, j int) bool {
+		return set.Snapshots[i].Device < set.Snapshots[j].Device
+	})
+}
+
+// SnapshotSetGet finds the snapshots of the set by their metadata.
+func SnapshotSetGet(ctx context.Context, client *edgecloud.Client, setID string) (*SnapshotSet, error) {
+	snapshots, _, err := client.Snapshots.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &SnapshotSet{ID: setID}
+	for _, snapshot := range snapshots {
+		if snapshot.Metadata[SnapshotSetMetadataKey] != setID {
+			continue
+		}
+
+		set.InstanceID = snapshot.Metadata[SnapshotSetInstanceMetadataKey]
+		bootable, _ := strconv.ParseBool(snapshot.Metadata[SnapshotSetBootableMetadataKey])
+		set.Snapshots = append(set.Snapshots, SnapshotSetMember{
+			SnapshotID: snapshot.ID,
+			VolumeID:   snapshot.VolumeID,
+			VolumeName: snapshot.Metadata[SnapshotSetVolumeNameMetadataKey],
+			VolumeType: edgecloud.VolumeType(snapshot.Metadata[SnapshotSetVolumeTypeMetadataKey]),
+			Device:     snapshot.Metadata[SnapshotSetDeviceMetadataKey],
+			Bootable:   bootable,
+			Size:       snapshot.Size,
+		})
+	}
+
+	if len(set.Snapshots) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotSetNotFound, setID)
+	}
+
+	sortSnapshotSet(set)
+
+	return set, nil
+}
+
+// RestoredVolume is a volume created from a snapshot of a snapshot set.
+type RestoredVolume struct {
+	SnapshotSetMember
+	// RestoredVolumeID is the ID of the new volume.
+	RestoredVolumeID string
+	// Attachment is set if the volume was attached to an instance.
+	Attachment *VolumeAttachment
+}
+
+// SnapshotSetRestoreOptions specifies the optional parameters to RestoreSnapshotSet.
+type SnapshotSetRestoreOptions struct {
+	// Name is the prefix of the volume names, the original volume names are used if empty.
+	Name string
+	// AttachTo is the instance the restored data volumes are attached to, in the order of their original devices.
+	// The volumes of the boot device are not attached, they are used to create an instance with an existing-volume source.
+	AttachTo string
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for the volume attachments.
+	Attempts *uint
+}
+
+// RestoreSnapshotSet creates a volume from every snapshot of the set. It returns the volumes restored so far on error.
+func RestoreSnapshotSet(ctx context.Context, client *edgecloud.Client, setID string, opts *SnapshotSetRestoreOptions) ([]RestoredVolume, error) {
+	if opts == nil {
+		opts = &SnapshotSetRestoreOptions{}
+	}
+
+	set, err := SnapshotSetGet(ctx, client, setID)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]RestoredVolume, 0, len(set.Snapshots))
+	for _, member := range set.Snapshots {
+		name := member.VolumeName
+		if opts.Name != "" {
+			name = fmt.Sprintf("%s-%s", opts.Name, member.VolumeName)
+		}
+
+		result, err := ExecuteAndExtractTaskResult(ctx, client.Volumes.Create, &edgecloud.VolumeCreateRequest{
+			Name:       name,
+			Source:     edgecloud.VolumeSourceSnapshot,
+			SnapshotID: member.SnapshotID,
+			TypeName:   member.VolumeType,
+		}, client, nonZeroTimeouts(opts.Timeout)...)
+		if err != nil {
+			return restored, err
+		}
+
+		if len(result.Volumes) == 0 {
+			return restored, fmt.Errorf("%w: volumes", ErrTaskResultHasNoResources)
+		}
+
+		restored = append(restored, RestoredVolume{SnapshotSetMember: member, RestoredVolumeID: result.Volumes[0]})
+	}
+
+	if opts.AttachTo == "" {
+		return restored, nil
+	}
+
+	for i := range restored {
+		if instanceBootDevices[restored[i].Device] {
+			continue
+		}
+
+		attachment, err := AttachVolumeAndWait(ctx, client, restored[i].RestoredVolumeID, opts.AttachTo, &VolumeAttachOptions{Attempts: opts.Attempts})
+		if err != nil {
+			return restored, err
+		}
+		restored[i].Attachment = attachment
+	}
+
+	return restored, nil
+}
@@ -0,0 +1,233 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// fakeSnapshotSetAPI serves an instance with two volumes, the snapshots and the volumes created from them.
+type fakeSnapshotSetAPI struct {
+	*fakeAPI
+	status    edgecloud.InstanceStatus
+	snapshots map[string]edgecloud.Snapshot
+	volumes   []edgecloud.VolumeCreateRequest
+	// restored are the names of the volumes created from the snapshots by ID.
+	restored map[string]string
+	// attached are the devices of the restored volumes attached to testInstanceID2 by volume ID.
+	attached map[string]string
+	// dataBootable marks the data volume as bootable, as a volume created from an image is.
+	dataBootable bool
+	// failVolume is the volume whose snapshot cannot be created.
+	failVolume string
+}
+
+func newFakeSnapshotSetAPI(t *testing.T, mux *http.ServeMux) *fakeSnapshotSetAPI {
+	t.Helper()
+
+	api := &fakeSnapshotSetAPI{
+		fakeAPI:   newFakeAPI(t, mux),
+		status:    edgecloud.InstanceStatusActive,
+		snapshots: map[string]edgecloud.Snapshot{},
+		restored:  map[string]string{},
+		attached:  map[string]string{},
+	}
+
+	URLInstance := resourcePath("/v1/instances", testResourceID)
+	api.handle(URLInstance, func(w http.ResponseWriter, r *http.Request) {
+		instance := edgecloud.Instance{ID: testResourceID, Name: "db", Status: api.status, VMState: edgecloud.InstanceVMStateActive}
+		if api.status == edgecloud.InstanceStatusShutoff {
+			instance.VMState = edgecloud.InstanceVMStateStopped
+		}
+		writeTestJSON(t, w, instance)
+	})
+	for action, status := range map[string]edgecloud.InstanceStatus{"stop": edgecloud.InstanceStatusShutoff, "start": edgecloud.InstanceStatusActive} {
+		action, status := action, status
+		api.handle(path.Join(URLInstance, action), func(w http.ResponseWriter, r *http.Request) {
+			api.call(action)
+			api.status = status
+			writeTestJSON(t, w, edgecloud.Instance{ID: testResourceID, Status: status})
+		})
+	}
+
+	api.handle(resourcePath("/v1/volumes"), func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var reqBody edgecloud.VolumeCreateRequest
+			api.decode(r, &reqBody)
+			api.volumes = append(api.volumes, reqBody)
+			id := uuid.NewString()
+			api.restored[id] = reqBody.Name
+			api.writeTask(w, map[string]interface{}{"volumes": []string{id}})
+
+			return
+		}
+
+		assert.Equal(t, testResourceID, r.URL.Query().Get("instance_id"))
+		writeTestJSON(t, w, map[string]interface{}{"results": []edgecloud.Volume{
+			{ID: testVolumeID, Name: "data", VolumeType: edgecloud.VolumeTypeSsdHiIops, Size: 100, Bootable: api.dataBootable, Attachments: []edgecloud.Attachment{{ServerID: testResourceID, Device: "/dev/vdb"}}},
+			{ID: testResourceID3, Name: "boot", VolumeType: edgecloud.VolumeTypeStandard, Size: 10, Bootable: true, Attachments: []edgecloud.Attachment{{ServerID: testResourceID, Device: "/dev/vda"}}},
+		}})
+	})
+
+	api.handle(resourcePath("/v1/volumes")+"/", func(w http.ResponseWriter, r *http.Request) {
+		id := path.Base(r.URL.Path)
+		if id == "attach" {
+			id = path.Base(path.Dir(r.URL.Path))
+			var reqBody edgecloud.VolumeAttachRequest
+			api.decode(r, &reqBody)
+			assert.Equal(t, testInstanceID2, reqBody.InstanceID)
+			api.call("attach %s", api.restored[id])
+			api.attached[id] = fmt.Sprintf("/dev/vd%c", 'b'+len(api.attached))
+		}
+
+		volume := edgecloud.Volume{ID: id, Name: api.restored[id]}
+		if device, ok := api.attached[id]; ok {
+			volume.Attachments = []edgecloud.Attachment{{ServerID: testInstanceID2, VolumeID: id, Device: device}}
+		}
+		writeTestJSON(t, w, volume)
+	})
+	api.handle(resourcePath("/v1/instances", testInstanceID2), func(w http.ResponseWriter, r *http.Request) {
+		instance := edgecloud.Instance{ID: testInstanceID2}
+		for id := range api.attached {
+			instance.Volumes = append(instance.Volumes, edgecloud.InstanceVolume{ID: id})
+		}
+		writeTestJSON(t, w, instance)
+	})
+
+	URLSnapshots := resourcePath("/v1/snapshots")
+	api.handle(URLSnapshots, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var reqBody edgecloud.SnapshotCreateRequest
+			api.decode(r, &reqBody)
+			if reqBody.VolumeID == api.failVolume {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			assert.Equal(t, edgecloud.InstanceStatusShutoff, api.status)
+			snapshot := edgecloud.Snapshot{ID: uuid.NewString(), Name: reqBody.Name, VolumeID: reqBody.VolumeID, Status: edgecloud.SnapshotStatusAvailable, Metadata: reqBody.Metadata, Size: 10}
+			if reqBody.VolumeID == testVolumeID {
+				snapshot.Size = 100
+			}
+			api.snapshots[snapshot.ID] = snapshot
+			api.writeTask(w, map[string]interface{}{"snapshots": []string{snapshot.ID}})
+
+			return
+		}
+
+		snapshots := []edgecloud.Snapshot{{ID: testSnapshotID, Metadata: edgecloud.Metadata{SnapshotSetMetadataKey: "other"}}}
+		for _, snapshot := range api.snapshots {
+			snapshots = append(snapshots, snapshot)
+		}
+		writeTestJSON(t, w, map[string]interface{}{"results": snapshots})
+	})
+	api.handle(URLSnapshots+"/", func(w http.ResponseWriter, r *http.Request) {
+		id := path.Base(r.URL.Path)
+		if r.Method == http.MethodDelete {
+			api.call("delete snapshot %s", api.snapshots[id].VolumeID)
+			delete(api.snapshots, id)
+			api.writeTask(w, nil)
+
+			return
+		}
+		writeTestJSON(t, w, api.snapshots[id])
+	})
+
+	return api
+}
+
+func TestCreateSnapshotSet(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeSnapshotSetAPI(t, mux)
+	client := newTestClient(server.URL)
+
+	set, err := CreateSnapshotSet(context.Background(), client, testResourceID, &SnapshotSetOptions{
+		Quiesce:  InstanceActionStop,
+		Metadata: edgecloud.Metadata{"backup": "nightly"},
+		Attempts: &attempts,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stop", "start"}, api.calls)
+	require.Len(t, set.Snapshots, 2)
+	assert.Equal(t, "/dev/vda", set.Snapshots[0].Device)
+	assert.True(t, set.Snapshots[0].Bootable)
+	assert.Equal(t, testVolumeID, set.Snapshots[1].VolumeID)
+
+	snapshot := api.snapshots[set.Snapshots[1].SnapshotID]
+	assert.Equal(t, "db-data", snapshot.Name)
+	assert.Equal(t, set.ID, snapshot.Metadata[SnapshotSetMetadataKey])
+	assert.Equal(t, "nightly", snapshot.Metadata["backup"])
+
+	found, err := SnapshotSetGet(context.Background(), client, set.ID)
+	require.NoError(t, err)
+	assert.Equal(t, set, found)
+
+	restored, err := RestoreSnapshotSet(context.Background(), client, set.ID, &SnapshotSetRestoreOptions{Name: "restore"})
+	require.NoError(t, err)
+	require.Len(t, restored, 2)
+	assert.NotEmpty(t, restored[1].RestoredVolumeID)
+	assert.Equal(t, []edgecloud.VolumeCreateRequest{
+		{Name: "restore-boot", Source: edgecloud.VolumeSourceSnapshot, SnapshotID: set.Snapshots[0].SnapshotID, TypeName: edgecloud.VolumeTypeStandard},
+		{Name: "restore-data", Source: edgecloud.VolumeSourceSnapshot, SnapshotID: set.Snapshots[1].SnapshotID, TypeName: edgecloud.VolumeTypeSsdHiIops},
+	}, api.volumes)
+
+	_, err = SnapshotSetGet(context.Background(), client, "missing")
+	assert.ErrorIs(t, err, ErrSnapshotSetNotFound)
+}
+
+func TestRestoreSnapshotSet_AttachImageVolume(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeSnapshotSetAPI(t, mux)
+	api.dataBootable = true
+	client := newTestClient(server.URL)
+
+	set, err := CreateSnapshotSet(context.Background(), client, testResourceID, &SnapshotSetOptions{Quiesce: InstanceActionStop, Attempts: &attempts})
+	require.NoError(t, err)
+	assert.True(t, set.Snapshots[1].Bootable)
+
+	api.calls = nil
+	restored, err := RestoreSnapshotSet(context.Background(), client, set.ID, &SnapshotSetRestoreOptions{Name: "restore", AttachTo: testInstanceID2, Attempts: &attempts})
+	require.NoError(t, err)
+	require.Len(t, restored, 2)
+	assert.Nil(t, restored[0].Attachment)
+	require.NotNil(t, restored[1].Attachment)
+	assert.Equal(t, testInstanceID2, restored[1].Attachment.InstanceID)
+	assert.Equal(t, "/dev/vdb", restored[1].Attachment.Device)
+	assert.Equal(t, []string{"attach restore-data"}, api.calls)
+}
+
+func TestCreateSnapshotSet_Error(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeSnapshotSetAPI(t, mux)
+	api.failVolume = testResourceID3
+
+	set, err := CreateSnapshotSet(context.Background(), newTestClient(server.URL), testResourceID, &SnapshotSetOptions{
+		Quiesce:  InstanceActionStop,
+		Attempts: &attempts,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, set)
+	assert.Equal(t, []string{"stop", "delete snapshot " + testVolumeID, "start"}, api.calls)
+	assert.Empty(t, api.snapshots)
+	assert.Equal(t, edgecloud.InstanceStatusActive, api.status)
+
+	_, err = CreateSnapshotSet(context.Background(), newTestClient(server.URL), testResourceID, &SnapshotSetOptions{Quiesce: InstanceActionReboot})
+	assert.ErrorIs(t, err, ErrSnapshotSetInvalidQuiesce)
+}
@@ -3,15 +3,22 @@ package util
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avast/retry-go/v4"
 
 	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
 )
 
+// Deprecated: use edgecloud.SnapshotStatusAvailable. The constant is untyped, so it can still be passed to
+// SnapshotsListByStatusAndVolumeID.
 const SnapshotReadyStatus = "available"
 
 var (
-	ErrSnapshotsNotFound = errors.New("no Snapshots were found for the specified search criteria")
-	ErrSnapshotNotReady  = errors.New("snapshot failed to be ready within the allocated time")
+	ErrSnapshotsNotFound  = errors.New("no Snapshots were found for the specified search criteria")
+	ErrSnapshotNotReady   = errors.New("snapshot failed to be ready within the allocated time")
+	ErrSnapshotErrorState = errors.New("the snapshot is in error state")
 )
 
 func SnapshotsListByStatusAndVolumeID(ctx context.Context, client *edgecloud.Client, status, volumeID string) ([]edgecloud.Snapshot, error) {
@@ -23,7 +30,7 @@ func SnapshotsListByStatusAndVolumeID(ctx context.Context, client *edgecloud.Cli
 	}
 
 	for _, snap := range snapList {
-		if snap.Status == status {
+		if snap.Status == edgecloud.SnapshotStatus(status) {
 			snapshots = append(snapshots, snap)
 		}
 	}
@@ -64,12 +71,35 @@ func WaitSnapshotStatusReady(ctx context.Context, client *edgecloud.Client, snap
 				return err
 			}
 
-			if snapshot.Status == SnapshotReadyStatus {
+			if snapshot.Status == edgecloud.SnapshotStatusAvailable {
 				return nil
 			}
 
+			if snapshot.Status.IsError() {
+				return retry.Unrecoverable(fmt.Errorf("%w: snapshot %s is %s", ErrSnapshotErrorState, snapshotID, snapshot.Status))
+			}
+
 			return ErrSnapshotNotReady
 		},
 		attempts,
 	)
 }
+
+// CreateSnapshotAndWait creates a snapshot, waits for the creation task and for the snapshot to become available.
+func CreateSnapshotAndWait(ctx context.Context, client *edgecloud.Client, reqBody *edgecloud.SnapshotCreateRequest, attempts *uint, timeouts ...time.Duration) (string, error) {
+	taskResult, err := ExecuteAndExtractTaskResult(ctx, client.Snapshots.Create, reqBody, client, timeouts...)
+	if err != nil {
+		return "", err
+	}
+
+	if len(taskResult.Snapshots) == 0 {
+		return "", fmt.Errorf("%w: snapshots", ErrTaskResultHasNoResources)
+	}
+
+	snapshotID := taskResult.Snapshots[0]
+	if err = WaitSnapshotStatusReady(ctx, client, snapshotID, attempts); err != nil {
+		return snapshotID, err
+	}
+
+	return snapshotID, nil
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -167,7 +168,7 @@ func TestWaitSnapshotStatusReady(t *testing.T) {
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	expectedResp := edgecloud.Snapshot{ID: testResourceID, Status: SnapshotReadyStatus}
+	expectedResp := edgecloud.Snapshot{ID: testResourceID, Status: edgecloud.SnapshotStatusAvailable}
 	URL := path.Join("/v1/snapshots", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
 	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
 		resp, err := json.Marshal(expectedResp)
@@ -231,3 +232,28 @@ func TestWaitSnapshotStatusReady_SnapshotNotReadyError(t *testing.T) {
 	err := WaitSnapshotStatusReady(context.Background(), client, testResourceID, &attempts)
 	assert.ErrorIs(t, err, ErrSnapshotNotReady)
 }
+
+func TestWaitSnapshotStatusReady_SnapshotErrorState(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var gets atomic.Int32
+	expectedResp := edgecloud.Snapshot{ID: testResourceID, Status: edgecloud.SnapshotStatusError}
+	URL := path.Join("/v1/snapshots", strconv.Itoa(projectID), strconv.Itoa(regionID), testResourceID)
+	mux.HandleFunc(URL, func(w http.ResponseWriter, r *http.Request) {
+		gets.Add(1)
+		resp, err := json.Marshal(expectedResp)
+		if err != nil {
+			t.Fatalf("failed to marshal JSON: %v", err)
+		}
+		_, _ = fmt.Fprint(w, string(resp))
+	})
+
+	client := newTestClient(server.URL)
+
+	err := WaitSnapshotStatusReady(context.Background(), client, testResourceID, &attempts)
+	assert.ErrorIs(t, err, ErrSnapshotErrorState)
+	// the error state is not waited out
+	assert.Equal(t, int32(1), gets.Load())
+}
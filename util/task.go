@@ -23,6 +23,8 @@ var (
 	errTaskWaitTimeout    = errors.New("a timeout occurred")
 	errTaskWithErrorState = errors.New("task with error state")
 	errTaskStateUnknown   = errors.New("unknown task state")
+
+	ErrTaskResultHasNoResources = errors.New("the task has not created the expected resources")
 )
 
 type TaskResult struct {
@@ -152,3 +154,15 @@ func WaitAndGetTaskInfo(ctx context.Context, client *edgecloud.Client, taskID st
 		return nil, edgecloud.NewArgError("task error", errTaskWaitTimeout.Error())
 	}
 }
+
+// nonZeroTimeouts drops unset timeouts so that the default task timeout is used for them.
+func nonZeroTimeouts(timeouts ...time.Duration) []time.Duration {
+	result := make([]time.Duration, 0, len(timeouts))
+	for _, t := range timeouts {
+		if t > 0 {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,25 @@
+package util
+
+import "time"
+
+// apiTimeLayouts are the timestamp layouts the API is known to return.
+var apiTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02 15:04:05",
+}
+
+// parseAPITime parses a timestamp in any of the layouts the API is known to return. Timestamps without
+// a timezone are in UTC.
+func parseAPITime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range apiTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var ErrVolumeMultiAttached = errors.New("the volume is attached to several instances")
+
+// VolumeAttachment describes a volume attached to an instance.
+type VolumeAttachment struct {
+	VolumeID     string
+	InstanceID   string
+	AttachmentID string
+	// Device is the device name assigned to the volume by the instance, for example /dev/vdb.
+	Device string
+	// AttachmentTag is the tag the volume was attached with. The API does not report it back.
+	AttachmentTag string
+}
+
+// VolumeAttachOptions specifies the optional parameters to the volume attach helpers.
+type VolumeAttachOptions struct {
+	// AttachmentTag is passed to the attach request, it names the device inside the guest.
+	AttachmentTag string
+	// Attempts is the number of attempts to wait for the attachment to become visible.
+	Attempts *uint
+}
+
+// AttachVolumeAndWait attaches the volume to the instance and waits until the attachment is visible
+// both on the volume, with the assigned device, and in the volumes of the instance.
+func AttachVolumeAndWait(ctx context.Context, client *edgecloud.Client, volumeID, instanceID string, opts *VolumeAttachOptions) (*VolumeAttachment, error) {
+	if opts == nil {
+		opts = &VolumeAttachOptions{}
+	}
+
+	_, _, err := client.Volumes.Attach(ctx, volumeID, &edgecloud.VolumeAttachRequest{
+		InstanceID:    instanceID,
+		AttachmentTag: opts.AttachmentTag,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var attachment *VolumeAttachment
+	err = WithRetry(
+		func() error {
+			volume, _, err := client.Volumes.Get(ctx, volumeID)
+			if err != nil {
+				return err
+			}
+
+			found := volumeAttachment(volume, instanceID)
+			if found == nil || found.Device == "" {
+				return fmt.Errorf("%w: volume %s, instance %s", ErrVolumesNotAttached, volumeID, instanceID)
+			}
+
+			attached, err := instanceHasVolume(ctx, client, instanceID, volumeID)
+			if err != nil {
+				return err
+			}
+			if !attached {
+				return fmt.Errorf("%w: instance %s does not list volume %s", ErrVolumesNotAttached, instanceID, volumeID)
+			}
+
+			attachment = &VolumeAttachment{
+				VolumeID:      volumeID,
+				InstanceID:    instanceID,
+				AttachmentID:  found.AttachmentID,
+				Device:        found.Device,
+				AttachmentTag: opts.AttachmentTag,
+			}
+
+			return nil
+		},
+		opts.Attempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// DetachVolumeAndWait detaches the volume from the instance and waits until the attachment is gone
+// both from the volume and from the volumes of the instance.
+func DetachVolumeAndWait(ctx context.Context, client *edgecloud.Client, volumeID, instanceID string, attempts *uint) error {
+	if _, _, err := client.Volumes.Detach(ctx, volumeID, &edgecloud.VolumeDetachRequest{InstanceID: instanceID}); err != nil {
+		return err
+	}
+
+	return WithRetry(
+		func() error {
+			volume, _, err := client.Volumes.Get(ctx, volumeID)
+			if err != nil {
+				return err
+			}
+
+			if volumeAttachment(volume, instanceID) != nil {
+				return fmt.Errorf("%w: volume %s, instance %s", ErrVolumesNotDetached, volumeID, instanceID)
+			}
+
+			attached, err := instanceHasVolume(ctx, client, instanceID, volumeID)
+			if err != nil {
+				return err
+			}
+			if attached {
+				return fmt.Errorf("%w: instance %s still lists volume %s", ErrVolumesNotDetached, instanceID, volumeID)
+			}
+
+			return nil
+		},
+		attempts,
+	)
+}
+
+// MoveVolume detaches the volume from the instance it is attached to and attaches it to the target instance.
+// If the volume cannot be attached to the target, it is attached back to the original instance without an
+// attachment tag, since the API does not report the tag it was attached with. An unattached volume is just
+// attached to the target, a volume already attached to the target is left as is.
+func MoveVolume(ctx context.Context, client *edgecloud.Client, volumeID, toInstanceID string, opts *VolumeAttachOptions) (*VolumeAttachment, error) {
+	if opts == nil {
+		opts = &VolumeAttachOptions{}
+	}
+
+	volume, _, err := client.Volumes.Get(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromInstanceID string
+	switch len(volume.Attachments) {
+	case 0:
+	case 1:
+		fromInstanceID = volume.Attachments[0].ServerID
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrVolumeMultiAttached, volumeID)
+	}
+
+	if fromInstanceID != "" && fromInstanceID == toInstanceID {
+		attachment := volume.Attachments[0]
+
+		return &VolumeAttachment{
+			VolumeID:     volumeID,
+			InstanceID:   toInstanceID,
+			AttachmentID: attachment.AttachmentID,
+			Device:       attachment.Device,
+		}, nil
+	}
+
+	if fromInstanceID != "" {
+		if err = DetachVolumeAndWait(ctx, client, volumeID, fromInstanceID, opts.Attempts); err != nil {
+			return nil, err
+		}
+	}
+
+	attachment, err := AttachVolumeAndWait(ctx, client, volumeID, toInstanceID, opts)
+	if err == nil || fromInstanceID == "" {
+		return attachment, err
+	}
+
+	return nil, errors.Join(err, rollbackVolumeMove(ctx, client, volumeID, fromInstanceID, toInstanceID, opts))
+}
+
+// rollbackVolumeMove detaches the volume from the target if the attachment went through after all
+// and attaches it back to the original instance. The tag of the target attachment does not apply to the
+// original instance, so the volume is attached back without a tag.
+func rollbackVolumeMove(ctx context.Context, client *edgecloud.Client, volumeID, fromInstanceID, toInstanceID string, opts *VolumeAttachOptions) error {
+	volume, _, err := client.Volumes.Get(ctx, volumeID)
+	if err != nil {
+		return err
+	}
+
+	if volumeAttachment(volume, toInstanceID) != nil {
+		if err = DetachVolumeAndWait(ctx, client, volumeID, toInstanceID, opts.Attempts); err != nil {
+			return err
+		}
+	}
+
+	_, err = AttachVolumeAndWait(ctx, client, volumeID, fromInstanceID, &VolumeAttachOptions{Attempts: opts.Attempts})
+
+	return err
+}
+
+func volumeAttachment(volume *edgecloud.Volume, instanceID string) *edgecloud.Attachment {
+	for _, attachment := range volume.Attachments {
+		if attachment.ServerID == instanceID {
+			return &attachment
+		}
+	}
+
+	return nil
+}
+
+func instanceHasVolume(ctx context.Context, client *edgecloud.Client, instanceID, volumeID string) (bool, error) {
+	instance, _, err := client.Instances.Get(ctx, instanceID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, volume := range instance.Volumes {
+		if volume.ID == volumeID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// fakeVolumeAttachAPI keeps the attachments of a volume in memory and serves them on the volume and the instances.
+type fakeVolumeAttachAPI struct {
+	*fakeAPI
+	attachments []edgecloud.Attachment
+	// failAttach are the instances the volume cannot be attached to.
+	failAttach map[string]bool
+}
+
+func newFakeVolumeAttachAPI(t *testing.T, mux *http.ServeMux, attachedTo string) *fakeVolumeAttachAPI {
+	t.Helper()
+
+	api := &fakeVolumeAttachAPI{fakeAPI: newFakeAPI(t, mux), failAttach: map[string]bool{}}
+	if attachedTo != "" {
+		api.attachments = []edgecloud.Attachment{{ServerID: attachedTo, VolumeID: testVolumeID, Device: "/dev/vdb"}}
+	}
+
+	URLVolume := resourcePath("/v1/volumes", testVolumeID)
+	api.handle(URLVolume, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Volume{ID: testVolumeID, Attachments: api.attachments})
+	})
+	api.handle(path.Join(URLVolume, "attach"), func(w http.ResponseWriter, r *http.Request) {
+		var reqBody edgecloud.VolumeAttachRequest
+		api.decode(r, &reqBody)
+		api.call("attach %s %s", reqBody.InstanceID, reqBody.AttachmentTag)
+		if api.failAttach[reqBody.InstanceID] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		api.attachments = append(api.attachments, edgecloud.Attachment{
+			ServerID: reqBody.InstanceID,
+			VolumeID: testVolumeID,
+			Device:   fmt.Sprintf("/dev/vd%c", 'b'+len(api.calls)),
+		})
+		writeTestJSON(t, w, edgecloud.Volume{ID: testVolumeID})
+	})
+	api.handle(path.Join(URLVolume, "detach"), func(w http.ResponseWriter, r *http.Request) {
+		var reqBody edgecloud.VolumeDetachRequest
+		api.decode(r, &reqBody)
+		api.call("detach %s", reqBody.InstanceID)
+		for i, attachment := range api.attachments {
+			if attachment.ServerID == reqBody.InstanceID {
+				api.attachments = append(api.attachments[:i], api.attachments[i+1:]...)
+				break
+			}
+		}
+		writeTestJSON(t, w, edgecloud.Volume{ID: testVolumeID})
+	})
+
+	for _, instanceID := range []string{testResourceID, testInstanceID2} {
+		instanceID := instanceID
+		api.handle(resourcePath("/v1/instances", instanceID), func(w http.ResponseWriter, r *http.Request) {
+			instance := edgecloud.Instance{ID: instanceID}
+			for _, attachment := range api.attachments {
+				if attachment.ServerID == instanceID {
+					instance.Volumes = append(instance.Volumes, edgecloud.InstanceVolume{ID: attachment.VolumeID})
+				}
+			}
+			writeTestJSON(t, w, instance)
+		})
+	}
+
+	return api
+}
+
+func TestAttachVolumeAndWait(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeAttachAPI(t, mux, "")
+	client := newTestClient(server.URL)
+
+	attachment, err := AttachVolumeAndWait(context.Background(), client, testVolumeID, testResourceID, &VolumeAttachOptions{AttachmentTag: "data", Attempts: &attempts})
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/vdc", attachment.Device)
+	assert.Equal(t, "data", attachment.AttachmentTag)
+
+	err = DetachVolumeAndWait(context.Background(), client, testVolumeID, testResourceID, &attempts)
+	require.NoError(t, err)
+	assert.Empty(t, api.attachments)
+}
+
+func TestMoveVolume(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeAttachAPI(t, mux, testResourceID)
+
+	attachment, err := MoveVolume(context.Background(), newTestClient(server.URL), testVolumeID, testInstanceID2, &VolumeAttachOptions{AttachmentTag: "data", Attempts: &attempts})
+	require.NoError(t, err)
+	assert.Equal(t, testInstanceID2, attachment.InstanceID)
+	assert.Equal(t, []string{"detach " + testResourceID, "attach " + testInstanceID2 + " data"}, api.calls)
+	require.Len(t, api.attachments, 1)
+	assert.Equal(t, testInstanceID2, api.attachments[0].ServerID)
+}
+
+func TestMoveVolume_Rollback(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeAttachAPI(t, mux, testResourceID)
+	api.failAttach[testInstanceID2] = true
+
+	attachment, err := MoveVolume(context.Background(), newTestClient(server.URL), testVolumeID, testInstanceID2, &VolumeAttachOptions{AttachmentTag: "data", Attempts: &attempts})
+	assert.Error(t, err)
+	assert.Nil(t, attachment)
+	assert.Equal(t, []string{
+		"detach " + testResourceID,
+		"attach " + testInstanceID2 + " data",
+		"attach " + testResourceID + " ",
+	}, api.calls)
+	require.Len(t, api.attachments, 1)
+	assert.Equal(t, testResourceID, api.attachments[0].ServerID)
+}
+
+func TestMoveVolume_SameInstance(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeAttachAPI(t, mux, testResourceID)
+
+	attachment, err := MoveVolume(context.Background(), newTestClient(server.URL), testVolumeID, testResourceID, &VolumeAttachOptions{AttachmentTag: "data", Attempts: &attempts})
+	require.NoError(t, err)
+	assert.Equal(t, testResourceID, attachment.InstanceID)
+	assert.Equal(t, api.attachments[0].Device, attachment.Device)
+	assert.Empty(t, api.calls)
+}
@@ -0,0 +1,195 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var ErrVolumeCopyCrossRegion = errors.New("volumes cannot be copied across regions")
+
+// VolumeCloneOptions specifies the parameters to CloneVolume.
+type VolumeCloneOptions struct {
+	// Name of the new volume. Required.
+	Name string
+	// VolumeType overrides the type of the source volume.
+	VolumeType edgecloud.VolumeType
+	// Size overrides the size of the source volume in GiB, it cannot be smaller.
+	Size int
+	// Metadata overrides or adds metadata keys of the new volume.
+	Metadata edgecloud.Metadata
+	// RemoveMetadataKeys are the metadata keys of the source volume not to copy.
+	RemoveMetadataKeys []string
+	// Target is a client of another project in the same region to create the volume in. The volume is copied
+	// through an image instead of a snapshot then, the image must be visible to the target project.
+	Target *edgecloud.Client
+	// PublishToTarget makes the image available to the target project with PublishImage before the volume
+	// is created from it.
+	PublishToTarget bool
+	// KeepIntermediate keeps the snapshot or the image the volume was created from.
+	KeepIntermediate bool
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for the snapshot and the volume to become available.
+	Attempts *uint
+}
+
+// VolumeCloneResult describes a cloned volume.
+type VolumeCloneResult struct {
+	Volume *edgecloud.Volume
+	// SnapshotID is the snapshot the volume was created from in the same project.
+	SnapshotID string
+	// ImageID is the image the volume was created from in the target project.
+	ImageID string
+}
+
+// CloneVolume creates a copy of the volume with its writable metadata. In the client project the volume is
+// snapshotted and the copy is created from the snapshot, in another project of the same region, see
+// opts.Target, the copy is created from an image of the volume. The snapshot or the image is deleted
+// afterwards, also on failure, unless opts.KeepIntermediate is set. The result is returned with the resources
+// created so far on error.
+func CloneVolume(ctx context.Context, client *edgecloud.Client, volumeID string, opts *VolumeCloneOptions) (result *VolumeCloneResult, err error) {
+	if opts == nil || opts.Name == "" {
+		return nil, edgecloud.NewArgError("opts.Name", "cannot be empty")
+	}
+
+	target := opts.Target
+	if target == nil {
+		target = client
+	}
+
+	if target.Region != client.Region {
+		return nil, fmt.Errorf("%w: from %d to %d", ErrVolumeCopyCrossRegion, client.Region, target.Region)
+	}
+
+	volume, _, err := client.Volumes.Get(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Size != 0 && opts.Size < volume.Size {
+		return nil, edgecloud.NewArgError("opts.Size", fmt.Sprintf("cannot be smaller than the volume size %d", volume.Size))
+	}
+
+	req := &edgecloud.VolumeCreateRequest{
+		Name:     opts.Name,
+		Size:     max(opts.Size, volume.Size),
+		TypeName: volume.VolumeType,
+		Metadata: writableMetadata(volume.Metadata, volume.MetadataDetailed),
+	}
+	if opts.VolumeType != "" {
+		req.TypeName = opts.VolumeType
+	}
+	for _, key := range opts.RemoveMetadataKeys {
+		delete(req.Metadata, key)
+	}
+	for k, v := range opts.Metadata {
+		req.Metadata[k] = v
+	}
+
+	result = &VolumeCloneResult{}
+	if target.Project == client.Project {
+		err = cloneVolumeFromSnapshot(ctx, client, volume, req, opts, result)
+	} else {
+		err = copyVolumeFromImage(ctx, client, target, volume, req, opts, result)
+	}
+
+	return result, err
+}
+
+func cloneVolumeFromSnapshot(ctx context.Context, client *edgecloud.Client, volume *edgecloud.Volume, req *edgecloud.VolumeCreateRequest, opts *VolumeCloneOptions, result *VolumeCloneResult) (err error) {
+	result.SnapshotID, err = CreateSnapshotAndWait(ctx, client, &edgecloud.SnapshotCreateRequest{
+		VolumeID:    volume.ID,
+		Name:        opts.Name,
+		Description: fmt.Sprintf("clone of volume %s", volume.ID),
+	}, opts.Attempts, nonZeroTimeouts(opts.Timeout)...)
+	if result.SnapshotID != "" && !opts.KeepIntermediate {
+		defer func() {
+			task, _, deleteErr := client.Snapshots.Delete(context.WithoutCancel(ctx), result.SnapshotID)
+			if deleteErr == nil {
+				deleteErr = WaitForTaskComplete(context.WithoutCancel(ctx), client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...)
+			}
+			err = errors.Join(err, deleteErr)
+		}()
+	}
+	if err != nil {
+		return err
+	}
+
+	req.Source = edgecloud.VolumeSourceSnapshot
+	req.SnapshotID = result.SnapshotID
+	result.Volume, err = createVolumeAndWait(ctx, client, req, opts)
+
+	return err
+}
+
+func copyVolumeFromImage(ctx context.Context, client, target *edgecloud.Client, volume *edgecloud.Volume, req *edgecloud.VolumeCreateRequest, opts *VolumeCloneOptions, result *VolumeCloneResult) (err error) {
+	taskResult, err := ExecuteAndExtractTaskResult(ctx, client.Images.Create, &edgecloud.ImageCreateRequest{
+		Name:     opts.Name,
+		VolumeID: volume.ID,
+		Source:   imageSourceVolume,
+		Metadata: edgecloud.Metadata{},
+	}, client, nonZeroTimeouts(opts.Timeout)...)
+	if err != nil {
+		return err
+	}
+
+	if len(taskResult.Images) == 0 {
+		return fmt.Errorf("%w: images", ErrTaskResultHasNoResources)
+	}
+
+	result.ImageID = taskResult.Images[0]
+	if !opts.KeepIntermediate {
+		defer func() {
+			task, _, deleteErr := client.Images.Delete(context.WithoutCancel(ctx), result.ImageID)
+			if deleteErr == nil {
+				deleteErr = WaitForTaskComplete(context.WithoutCancel(ctx), client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...)
+			}
+			err = errors.Join(err, deleteErr)
+		}()
+	}
+
+	if opts.PublishToTarget {
+		err = PublishImage(ctx, client, result.ImageID, ImagePublishTarget{
+			EntityType: edgecloud.ProjectType,
+			EntityID:   target.Project,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	req.Source = edgecloud.VolumeSourceImage
+	req.ImageID = result.ImageID
+	result.Volume, err = createVolumeAndWait(ctx, target, req, opts)
+
+	return err
+}
+
+// createVolumeAndWait creates the volume and waits for it to become available. The volume is returned with
+// only its ID if it was created but did not become available.
+func createVolumeAndWait(ctx context.Context, client *edgecloud.Client, req *edgecloud.VolumeCreateRequest, opts *VolumeCloneOptions) (*edgecloud.Volume, error) {
+	taskResult, err := ExecuteAndExtractTaskResult(ctx, client.Volumes.Create, req, client, nonZeroTimeouts(opts.Timeout)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(taskResult.Volumes) == 0 {
+		return nil, fmt.Errorf("%w: volumes", ErrTaskResultHasNoResources)
+	}
+
+	volumeID := taskResult.Volumes[0]
+	if err = waitForVolume(ctx, client, volumeID, func(*edgecloud.Volume) bool { return true }, opts.Attempts); err != nil {
+		return &edgecloud.Volume{ID: volumeID}, err
+	}
+
+	volume, _, err := client.Volumes.Get(ctx, volumeID)
+	if err != nil {
+		return &edgecloud.Volume{ID: volumeID}, err
+	}
+
+	return volume, nil
+}
@@ -0,0 +1,158 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+const testTargetProjectID = 2751
+
+// fakeVolumeCloneAPI serves the source volume and records the volumes created from it and the deleted snapshots and images.
+type fakeVolumeCloneAPI struct {
+	*fakeAPI
+	created map[string]edgecloud.VolumeCreateRequest
+	// status is the status of the created volumes, available if empty.
+	status edgecloud.VolumeStatus
+}
+
+func newFakeVolumeCloneAPI(t *testing.T, mux *http.ServeMux) *fakeVolumeCloneAPI {
+	t.Helper()
+
+	api := &fakeVolumeCloneAPI{fakeAPI: newFakeAPI(t, mux), created: map[string]edgecloud.VolumeCreateRequest{}}
+
+	for _, project := range []int{projectID, testTargetProjectID} {
+		URLVolumes := path.Join("/v1/volumes", strconv.Itoa(project), strconv.Itoa(regionID))
+		api.handle(URLVolumes, func(w http.ResponseWriter, r *http.Request) {
+			var req edgecloud.VolumeCreateRequest
+			api.decode(r, &req)
+			id := uuid.NewString()
+			api.created[id] = req
+			api.writeTask(w, map[string]interface{}{"volumes": []string{id}})
+		})
+		api.handle(URLVolumes+"/", func(w http.ResponseWriter, r *http.Request) {
+			id := path.Base(r.URL.Path)
+			if id == testVolumeID {
+				writeTestJSON(t, w, edgecloud.Volume{
+					ID: testVolumeID, Name: "data", Status: edgecloud.VolumeStatusInUse, Size: 10, VolumeType: edgecloud.VolumeTypeStandard,
+					Metadata:         edgecloud.Metadata{"env": "prod", "task_id": "x"},
+					MetadataDetailed: []edgecloud.MetadataDetailed{{Key: "env", Value: "prod"}, {Key: "task_id", Value: "x", ReadOnly: true}},
+				})
+				return
+			}
+			req, status := api.created[id], api.status
+			if status == "" {
+				status = edgecloud.VolumeStatusAvailable
+			}
+			writeTestJSON(t, w, edgecloud.Volume{ID: id, Name: req.Name, Status: status, Size: req.Size, VolumeType: req.TypeName, Metadata: req.Metadata})
+		})
+	}
+
+	URLSnapshots := resourcePath("/v1/snapshots")
+	api.handle(URLSnapshots, func(w http.ResponseWriter, r *http.Request) {
+		api.writeTask(w, map[string]interface{}{"snapshots": []string{testSnapshotID}})
+	})
+	api.handle(path.Join(URLSnapshots, testSnapshotID), func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			api.call("delete snapshot %s", testSnapshotID)
+			api.writeTask(w, nil)
+			return
+		}
+		writeTestJSON(t, w, edgecloud.Snapshot{ID: testSnapshotID, Status: edgecloud.SnapshotStatusAvailable})
+	})
+
+	URLImages := resourcePath("/v1/images")
+	api.handle(URLImages, func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.ImageCreateRequest
+		api.decode(r, &req)
+		assert.Equal(t, testVolumeID, req.VolumeID)
+		api.writeTask(w, map[string]interface{}{"images": []string{testImageID}})
+	})
+	api.handle(path.Join(URLImages, testImageID), func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		api.call("delete image %s", testImageID)
+		api.writeTask(w, nil)
+	})
+
+	return api
+}
+
+func TestCloneVolume(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeCloneAPI(t, mux)
+
+	result, err := CloneVolume(context.Background(), newTestClient(server.URL), testVolumeID, &VolumeCloneOptions{
+		Name:       "data-clone",
+		VolumeType: edgecloud.VolumeTypeSsdHiIops,
+		Metadata:   edgecloud.Metadata{"clone": "true"},
+		Attempts:   &attempts,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, testSnapshotID, result.SnapshotID)
+	assert.Equal(t, "data-clone", result.Volume.Name)
+	assert.Equal(t, 10, result.Volume.Size)
+	assert.Equal(t, edgecloud.VolumeTypeSsdHiIops, result.Volume.VolumeType)
+	assert.Equal(t, edgecloud.Metadata{"env": "prod", "clone": "true"}, result.Volume.Metadata)
+	assert.Equal(t, edgecloud.VolumeSourceSnapshot, api.created[result.Volume.ID].Source)
+	assert.Equal(t, []string{"delete snapshot " + testSnapshotID}, api.calls)
+}
+
+func TestCloneVolume_ErrorState(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeCloneAPI(t, mux)
+	api.status = edgecloud.VolumeStatusError
+
+	result, err := CloneVolume(context.Background(), newTestClient(server.URL), testVolumeID, &VolumeCloneOptions{Name: "data-clone", Attempts: &attempts})
+	require.ErrorIs(t, err, ErrVolumeErrorState)
+	require.NotNil(t, result.Volume)
+	assert.Contains(t, api.created, result.Volume.ID)
+	assert.Equal(t, []string{"delete snapshot " + testSnapshotID}, api.calls)
+}
+
+func TestCloneVolume_OtherProject(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeCloneAPI(t, mux)
+	target := newTestClient(server.URL)
+	target.Project = testTargetProjectID
+
+	result, err := CloneVolume(context.Background(), newTestClient(server.URL), testVolumeID, &VolumeCloneOptions{
+		Name:     "data-copy",
+		Size:     20,
+		Target:   target,
+		Attempts: &attempts,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, testImageID, result.ImageID)
+	assert.Equal(t, 20, result.Volume.Size)
+	assert.Equal(t, edgecloud.VolumeSourceImage, api.created[result.Volume.ID].Source)
+	assert.Equal(t, testImageID, api.created[result.Volume.ID].ImageID)
+	assert.Equal(t, []string{"delete image " + testImageID}, api.calls)
+}
+
+func TestCloneVolume_OtherRegion(t *testing.T) {
+	target := newTestClient("http://localhost")
+	target.Region = regionID + 1
+
+	_, err := CloneVolume(context.Background(), newTestClient("http://localhost"), testVolumeID, &VolumeCloneOptions{Name: "data-copy", Target: target})
+	require.ErrorIs(t, err, ErrVolumeCopyCrossRegion)
+}
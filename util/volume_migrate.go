@@ -0,0 +1,226 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+var (
+	ErrVolumeMigrationInvalid   = errors.New("invalid volume migration")
+	ErrVolumeTypeNotAvailable   = errors.New("the volume type is not available in the region")
+	ErrVolumeBootableDetach     = errors.New("the volume type change requires detaching the boot volume of the instance")
+	ErrVolumeErrorState         = errors.New("the volume is in error state")
+	ErrVolumeNotInExpectedState = errors.New("the volume has not reached the expected state")
+)
+
+// VolumeTypesRequiringDetach are the volume types a volume can only be changed from or to while detached.
+var VolumeTypesRequiringDetach = map[edgecloud.VolumeType]bool{
+	edgecloud.VolumeTypeSsdLocal: true,
+}
+
+// VolumeMigrateOptions specifies the parameters to MigrateVolume.
+type VolumeMigrateOptions struct {
+	// VolumeType is the new type of the volume, the type is kept if empty.
+	VolumeType edgecloud.VolumeType
+	// Size is the new size of the volume in GiB, the size is kept if 0. Volumes cannot shrink.
+	Size int
+	// Snapshot snapshots the volume before the migration.
+	Snapshot bool
+	// Timeout is the maximum time to wait for each task.
+	Timeout time.Duration
+	// Attempts is the number of attempts to wait for each volume state.
+	Attempts *uint
+}
+
+// VolumeState is the part of a volume a migration changes.
+type VolumeState struct {
+	VolumeType   edgecloud.VolumeType
+	Size         int
+	LimiterStats edgecloud.LimiterStats
+}
+
+// VolumeMigrateResult describes a volume migration.
+type VolumeMigrateResult struct {
+	VolumeID string
+	Before   VolumeState
+	After    VolumeState
+	// SnapshotID is the snapshot taken before the migration, if requested.
+	SnapshotID string
+	// Detached is the attachment of the volume before it was detached for the type change.
+	Detached *edgecloud.Attachment
+	// Reattached is the attachment of the volume after it was attached back.
+	Reattached *VolumeAttachment
+}
+
+// DeviceChanged reports whether the volume was attached back as another device. The API assigns the device,
+// so it can change.
+func (r *VolumeMigrateResult) DeviceChanged() bool {
+	return r.Detached != nil && r.Reattached != nil && r.Detached.Device != r.Reattached.Device
+}
+
+// MigrateVolume changes the type and extends the volume as one operation. The new type must be available in the
+// client region. If the type change requires it, see VolumeTypesRequiringDetach, the volume is detached first and
+// attached back to the same instance afterwards, also on failure. The volume can be snapshotted beforehand.
+// The result is returned with the steps done so far on error.
+func MigrateVolume(ctx context.Context, client *edgecloud.Client, volumeID string, opts *VolumeMigrateOptions) (result *VolumeMigrateResult, err error) {
+	if opts == nil || opts.VolumeType == "" && opts.Size == 0 {
+		return nil, fmt.Errorf("%w: volume type or size is required", ErrVolumeMigrationInvalid)
+	}
+
+	volume, _, err := client.Volumes.Get(ctx, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &VolumeMigrateResult{VolumeID: volumeID, Before: volumeState(volume)}
+
+	changeType := opts.VolumeType != "" && opts.VolumeType != volume.VolumeType
+	extend := opts.Size != 0 && opts.Size != volume.Size
+	detach := changeType && len(volume.Attachments) > 0 &&
+		(VolumeTypesRequiringDetach[volume.VolumeType] || VolumeTypesRequiringDetach[opts.VolumeType])
+
+	if err = validateVolumeMigration(ctx, client, volume, opts, changeType, detach); err != nil {
+		return nil, err
+	}
+
+	if !changeType && !extend {
+		result.After = result.Before
+		return result, nil
+	}
+
+	if opts.Snapshot {
+		result.SnapshotID, err = CreateSnapshotAndWait(ctx, client, &edgecloud.SnapshotCreateRequest{
+			VolumeID:    volumeID,
+			Name:        fmt.Sprintf("%s-migration-%d", volume.Name, time.Now().Unix()),
+			Description: "taken before the volume migration",
+		}, opts.Attempts, nonZeroTimeouts(opts.Timeout)...)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if detach {
+		attachment := volume.Attachments[0]
+		if err = DetachVolumeAndWait(ctx, client, volumeID, attachment.ServerID, opts.Attempts); err != nil {
+			return result, err
+		}
+		result.Detached = &attachment
+
+		defer func() {
+			var attachErr error
+			result.Reattached, attachErr = AttachVolumeAndWait(context.WithoutCancel(ctx), client, volumeID, attachment.ServerID, &VolumeAttachOptions{Attempts: opts.Attempts})
+			err = errors.Join(err, attachErr)
+			if attachErr == nil {
+				err = errors.Join(err, updateVolumeMigrateResult(context.WithoutCancel(ctx), client, result))
+			}
+		}()
+	}
+
+	if changeType {
+		if _, _, err = client.Volumes.ChangeType(ctx, volumeID, &edgecloud.VolumeChangeTypeRequest{VolumeType: opts.VolumeType}); err != nil {
+			return result, err
+		}
+
+		if err = waitForVolume(ctx, client, volumeID, func(v *edgecloud.Volume) bool { return v.VolumeType == opts.VolumeType }, opts.Attempts); err != nil {
+			return result, err
+		}
+	}
+
+	if extend {
+		task, _, err := client.Volumes.Extend(ctx, volumeID, &edgecloud.VolumeExtendSizeRequest{Size: opts.Size})
+		if err != nil {
+			return result, err
+		}
+
+		if err = WaitForTaskComplete(ctx, client, task.Tasks[0], nonZeroTimeouts(opts.Timeout)...); err != nil {
+			return result, err
+		}
+
+		if err = waitForVolume(ctx, client, volumeID, func(v *edgecloud.Volume) bool { return v.Size == opts.Size }, opts.Attempts); err != nil {
+			return result, err
+		}
+	}
+
+	return result, updateVolumeMigrateResult(ctx, client, result)
+}
+
+func validateVolumeMigration(ctx context.Context, client *edgecloud.Client, volume *edgecloud.Volume, opts *VolumeMigrateOptions, changeType, detach bool) error {
+	if volume.Status != edgecloud.VolumeStatusAvailable && volume.Status != edgecloud.VolumeStatusInUse {
+		return fmt.Errorf("%w: volume %s is %s", ErrVolumeMigrationInvalid, volume.ID, volume.Status)
+	}
+
+	if opts.Size != 0 && opts.Size < volume.Size {
+		return fmt.Errorf("%w: volume %s cannot shrink from %d to %d GiB", ErrVolumeMigrationInvalid, volume.ID, volume.Size, opts.Size)
+	}
+
+	if detach {
+		if len(volume.Attachments) > 1 {
+			return fmt.Errorf("%w: %s", ErrVolumeMultiAttached, volume.ID)
+		}
+		if len(volume.Attachments) == 1 && instanceBootDevices[volume.Attachments[0].Device] {
+			return fmt.Errorf("%w: %s from %s to %s", ErrVolumeBootableDetach, volume.ID, volume.VolumeType, opts.VolumeType)
+		}
+	}
+
+	if !changeType {
+		return nil
+	}
+
+	region, _, err := client.Regions.Get(ctx, strconv.Itoa(client.Region), nil)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(region.AvailableVolumeTypes, string(opts.VolumeType)) {
+		return fmt.Errorf("%w: %s in region %d", ErrVolumeTypeNotAvailable, opts.VolumeType, client.Region)
+	}
+
+	return nil
+}
+
+// updateVolumeMigrateResult sets the state of the volume after the migration.
+func updateVolumeMigrateResult(ctx context.Context, client *edgecloud.Client, result *VolumeMigrateResult) error {
+	volume, _, err := client.Volumes.Get(ctx, result.VolumeID)
+	if err != nil {
+		return err
+	}
+	result.After = volumeState(volume)
+
+	return nil
+}
+
+func volumeState(volume *edgecloud.Volume) VolumeState {
+	return VolumeState{VolumeType: volume.VolumeType, Size: volume.Size, LimiterStats: volume.LimiterStats}
+}
+
+// waitForVolume waits until the volume is available or in use and satisfies the condition. A volume in error
+// state is not waited for.
+func waitForVolume(ctx context.Context, client *edgecloud.Client, volumeID string, condition func(*edgecloud.Volume) bool, attempts *uint) error {
+	return WithRetry(
+		func() error {
+			volume, _, err := client.Volumes.Get(ctx, volumeID)
+			if err != nil {
+				return err
+			}
+
+			if volume.Status.IsError() {
+				return retry.Unrecoverable(fmt.Errorf("%w: volume %s is %s", ErrVolumeErrorState, volumeID, volume.Status))
+			}
+
+			if (volume.Status == edgecloud.VolumeStatusAvailable || volume.Status == edgecloud.VolumeStatusInUse) && condition(volume) {
+				return nil
+			}
+
+			return fmt.Errorf("%w: volume %s is %s", ErrVolumeNotInExpectedState, volumeID, volume.Status)
+		},
+		attempts,
+	)
+}
@@ -0,0 +1,182 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	edgecloud "github.com/Edge-Center/edgecentercloud-go/v2"
+)
+
+// fakeVolumeMigrateAPI keeps a volume in memory and applies the retype, extend, attach and detach requests to it.
+type fakeVolumeMigrateAPI struct {
+	*fakeAPI
+	volume edgecloud.Volume
+	// retypeStatus is the status of the volume after the retype request, the status is kept if empty.
+	retypeStatus edgecloud.VolumeStatus
+	gets         int
+}
+
+func newFakeVolumeMigrateAPI(t *testing.T, mux *http.ServeMux, volume edgecloud.Volume) *fakeVolumeMigrateAPI {
+	t.Helper()
+
+	api := &fakeVolumeMigrateAPI{fakeAPI: newFakeAPI(t, mux), volume: volume}
+
+	URLVolume := resourcePath("/v1/volumes", testVolumeID)
+	api.handle(URLVolume, func(w http.ResponseWriter, r *http.Request) {
+		api.gets++
+		writeTestJSON(t, w, api.volume)
+	})
+	api.handle(path.Join(URLVolume, "retype"), func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.VolumeChangeTypeRequest
+		api.decode(r, &req)
+		api.call("retype %s", req.VolumeType)
+		api.volume.VolumeType = req.VolumeType
+		api.volume.LimiterStats.IopsBaseLimit *= 10
+		if api.retypeStatus != "" {
+			api.volume.Status = api.retypeStatus
+		}
+		writeTestJSON(t, w, api.volume)
+	})
+	api.handle(path.Join(URLVolume, "extend"), func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.VolumeExtendSizeRequest
+		api.decode(r, &req)
+		api.call("extend %d", req.Size)
+		api.volume.Size = req.Size
+		api.writeTask(w, nil)
+	})
+	api.handle(path.Join(URLVolume, "attach"), func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.VolumeAttachRequest
+		api.decode(r, &req)
+		api.call("attach %s", req.InstanceID)
+		api.volume.Status = edgecloud.VolumeStatusInUse
+		api.volume.Attachments = []edgecloud.Attachment{{ServerID: req.InstanceID, VolumeID: testVolumeID, Device: "/dev/vdc"}}
+		writeTestJSON(t, w, api.volume)
+	})
+	api.handle(path.Join(URLVolume, "detach"), func(w http.ResponseWriter, r *http.Request) {
+		var req edgecloud.VolumeDetachRequest
+		api.decode(r, &req)
+		api.call("detach %s", req.InstanceID)
+		api.volume.Status = edgecloud.VolumeStatusAvailable
+		api.volume.Attachments = nil
+		writeTestJSON(t, w, api.volume)
+	})
+	api.handle(resourcePath("/v1/instances", testResourceID), func(w http.ResponseWriter, r *http.Request) {
+		instance := edgecloud.Instance{ID: testResourceID}
+		if len(api.volume.Attachments) > 0 {
+			instance.Volumes = []edgecloud.InstanceVolume{{ID: testVolumeID}}
+		}
+		writeTestJSON(t, w, instance)
+	})
+	api.handle(path.Join("/v1/regions", strconv.Itoa(regionID)), func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(t, w, edgecloud.Region{ID: regionID, AvailableVolumeTypes: []string{"standard", "ssd_hiiops", "ssd_local"}})
+	})
+
+	return api
+}
+
+func TestMigrateVolume_Detach(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeMigrateAPI(t, mux, edgecloud.Volume{
+		ID:         testVolumeID,
+		Status:     edgecloud.VolumeStatusInUse,
+		Size:       10,
+		VolumeType: edgecloud.VolumeTypeSsdLocal,
+		// a data volume created from an image is bootable
+		Bootable:     true,
+		Attachments:  []edgecloud.Attachment{{ServerID: testResourceID, VolumeID: testVolumeID, Device: "/dev/vdb"}},
+		LimiterStats: edgecloud.LimiterStats{IopsBaseLimit: 100},
+	})
+
+	result, err := MigrateVolume(context.Background(), newTestClient(server.URL), testVolumeID, &VolumeMigrateOptions{
+		VolumeType: edgecloud.VolumeTypeSsdHiIops,
+		Size:       20,
+		Attempts:   &attempts,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"detach " + testResourceID, "retype ssd_hiiops", "extend 20", "attach " + testResourceID}, api.calls)
+	assert.Equal(t, VolumeState{VolumeType: edgecloud.VolumeTypeSsdLocal, Size: 10, LimiterStats: edgecloud.LimiterStats{IopsBaseLimit: 100}}, result.Before)
+	assert.Equal(t, VolumeState{VolumeType: edgecloud.VolumeTypeSsdHiIops, Size: 20, LimiterStats: edgecloud.LimiterStats{IopsBaseLimit: 1000}}, result.After)
+	assert.True(t, result.DeviceChanged())
+}
+
+func TestMigrateVolume_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		volume edgecloud.Volume
+		opts   VolumeMigrateOptions
+		err    error
+	}{
+		{
+			name:   "not available type",
+			volume: edgecloud.Volume{Status: edgecloud.VolumeStatusAvailable, Size: 10, VolumeType: edgecloud.VolumeTypeStandard},
+			opts:   VolumeMigrateOptions{VolumeType: edgecloud.VolumeTypeCold},
+			err:    ErrVolumeTypeNotAvailable,
+		},
+		{
+			name:   "shrink",
+			volume: edgecloud.Volume{Status: edgecloud.VolumeStatusAvailable, Size: 10, VolumeType: edgecloud.VolumeTypeStandard},
+			opts:   VolumeMigrateOptions{Size: 5},
+			err:    ErrVolumeMigrationInvalid,
+		},
+		{
+			name:   "error status",
+			volume: edgecloud.Volume{Status: edgecloud.VolumeStatusError, Size: 10, VolumeType: edgecloud.VolumeTypeStandard},
+			opts:   VolumeMigrateOptions{Size: 20},
+			err:    ErrVolumeMigrationInvalid,
+		},
+		{
+			name: "boot volume detach",
+			volume: edgecloud.Volume{
+				Status: edgecloud.VolumeStatusInUse, Size: 10, VolumeType: edgecloud.VolumeTypeStandard, Bootable: true,
+				Attachments: []edgecloud.Attachment{{ServerID: testResourceID, Device: "/dev/vda"}},
+			},
+			opts: VolumeMigrateOptions{VolumeType: edgecloud.VolumeTypeSsdLocal},
+			err:  ErrVolumeBootableDetach,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			tt.volume.ID = testVolumeID
+			api := newFakeVolumeMigrateAPI(t, mux, tt.volume)
+			tt.opts.Attempts = &attempts
+
+			_, err := MigrateVolume(context.Background(), newTestClient(server.URL), testVolumeID, &tt.opts)
+			require.ErrorIs(t, err, tt.err)
+			assert.Empty(t, api.calls)
+		})
+	}
+}
+
+func TestMigrateVolume_ErrorState(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	api := newFakeVolumeMigrateAPI(t, mux, edgecloud.Volume{ID: testVolumeID, Status: edgecloud.VolumeStatusAvailable, Size: 10, VolumeType: edgecloud.VolumeTypeStandard})
+	api.retypeStatus = edgecloud.VolumeStatusError
+
+	waitAttempts := uint(5)
+	_, err := MigrateVolume(context.Background(), newTestClient(server.URL), testVolumeID, &VolumeMigrateOptions{
+		VolumeType: edgecloud.VolumeTypeSsdHiIops,
+		Attempts:   &waitAttempts,
+	})
+	require.ErrorIs(t, err, ErrVolumeErrorState)
+	// the volume is read before the migration and once while waiting for the new type
+	assert.Equal(t, 2, api.gets)
+}
@@ -68,7 +68,7 @@ var _ VolumesService = &VolumesServiceOp{}
 type Volume struct {
 	ID                  string              `json:"id"`
 	Name                string              `json:"name"`
-	Status              string              `json:"status"` // todo: need to implement volume status type
+	Status              VolumeStatus        `json:"status"`
 	Size                int                 `json:"size"`
 	CreatedAt           string              `json:"created_at"`
 	UpdatedAt           string              `json:"updated_at"`